@@ -18,9 +18,17 @@ import (
 	pprof "github.com/go-chi/chi/v5/middleware"
 	"github.com/jayjaytrn/URLShortener/config"
 	"github.com/jayjaytrn/URLShortener/internal/auth"
+	"github.com/jayjaytrn/URLShortener/internal/blocklist"
+	"github.com/jayjaytrn/URLShortener/internal/bots"
+	"github.com/jayjaytrn/URLShortener/internal/clicks"
 	"github.com/jayjaytrn/URLShortener/internal/db"
 	"github.com/jayjaytrn/URLShortener/internal/handlers"
 	"github.com/jayjaytrn/URLShortener/internal/middleware"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+	"github.com/jayjaytrn/URLShortener/internal/ratelimit"
+	"github.com/jayjaytrn/URLShortener/internal/threat"
+	"github.com/jayjaytrn/URLShortener/internal/urlshort"
+	"github.com/jayjaytrn/URLShortener/internal/webhooks"
 	"github.com/jayjaytrn/URLShortener/logging"
 	pb "github.com/jayjaytrn/URLShortener/proto"
 	"go.uber.org/zap"
@@ -54,17 +62,119 @@ func main() {
 
 	ctx := context.Background()
 
-	authManager := auth.NewManager()
-
 	cfg := config.GetConfig()
+	if err := urlshort.ValidateRedirectType(cfg.RedirectType); err != nil {
+		logger.Fatalw("invalid default redirect type", "error", err)
+	}
 
 	s := db.GetStorage(cfg, logger)
 	defer s.Close(ctx)
 
+	// Without configured keys, tokens are signed with a key generated once and kept in storage
+	authManager, err := auth.Load(ctx, auth.Options{
+		Keys:          cfg.AuthKeys,
+		KeysPath:      cfg.AuthKeysPath,
+		Secrets:       s,
+		TokenTTL:      time.Duration(cfg.AuthTokenTTL),
+		RefreshWindow: time.Duration(cfg.AuthRefreshWindow),
+	})
+	if err != nil {
+		logger.Fatalw("failed to load signing keys", "error", err)
+	}
+
+	// Tokens are checked against the revoked sessions held in memory, not in storage per request
+	if err = db.SyncRevocations(ctx, s, authManager); err != nil {
+		logger.Fatalw("failed to load revoked tokens", "error", err)
+	}
+
+	hookPolicy, err := policy.Load(cfg.WebhookPolicyPath)
+	if err != nil {
+		logger.Fatalw("failed to load webhook target policy", "error", err)
+	}
+
+	// Changes made through the wrapped storage are reported to the webhooks of link owners
+	hooks := webhooks.New(s, webhooks.Options{
+		BaseURL:        cfg.BaseURL,
+		MaxAttempts:    cfg.WebhookMaxAttempts,
+		RetryBackoff:   time.Duration(cfg.WebhookRetryBackoff),
+		Timeout:        time.Duration(cfg.WebhookTimeout),
+		ExpiryInterval: time.Duration(cfg.WebhookExpiryInterval),
+		Policy:         hookPolicy,
+	}, logger)
+	s = hooks.Wrap(s)
+
+	bl, err := blocklist.Load(cfg.BlocklistPath)
+	if err != nil {
+		logger.Fatalw("failed to load blocklist", "error", err)
+	}
+
+	pol, err := policy.Load(cfg.PolicyPath)
+	if err != nil {
+		logger.Fatalw("failed to load destination policy", "error", err)
+	}
+
+	botList, err := bots.Load(cfg.BotPatternsPath)
+	if err != nil {
+		logger.Fatalw("failed to load bot patterns", "error", err)
+	}
+
+	threats, err := threat.Load(cfg.ThreatListPath)
+	if err != nil {
+		logger.Fatalw("failed to load threat list", "error", err)
+	}
+
+	loopGuard, err := urlshort.NewLoopGuard(cfg, s, pol)
+	if err != nil {
+		logger.Fatalw("failed to initialize redirect loop guard", "error", err)
+	}
+
+	// Flag existing links whose destinations were listed after they were created
+	flagListed := func() {
+		n, err := threat.FlagListed(ctx, s, threats)
+		if err != nil {
+			logger.Errorw("failed to flag listed URLs", "error", err)
+			return
+		}
+		logger.Infow("threat list applied", "flagged", n)
+	}
+	flagListed()
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	go threats.Watch(watchCtx, time.Duration(cfg.ThreatListReloadInterval), flagListed, func(err error) {
+		logger.Errorw("failed to reload threat list", "error", err)
+	})
+	go db.SweepExpired(watchCtx, s, time.Duration(cfg.ExpirySweepInterval), time.Duration(cfg.ExpiredRetention), logger)
+	go hooks.WatchExpiry(watchCtx)
+	go db.WatchRevocations(watchCtx, s, authManager, time.Duration(cfg.RevocationSyncInterval), logger)
+
+	// Unique visitor counts and IP hashes only match across runs with the same salt
+	ipSalt, err := clicks.LoadSalt(ctx, s, cfg.ClickIPSalt)
+	if err != nil {
+		logger.Fatalw("failed to load click IP salt", "error", err)
+	}
+
+	tracker := clicks.New(s, clicks.Options{
+		QueueSize:     cfg.ClickQueueSize,
+		BatchSize:     cfg.ClickBatchSize,
+		FlushInterval: time.Duration(cfg.ClickFlushInterval),
+		IPSalt:        ipSalt,
+		CountryHeader: cfg.CountryHeader,
+		Bots:          botList,
+	}, logger)
+
 	h := handlers.Handler{
 		Config:      cfg,
 		Storage:     s,
 		AuthManager: authManager,
+		Blocklist:   bl,
+		Policy:      pol,
+		Threats:     threats,
+		LoopGuard:   loopGuard,
+
+		PasswordLimiter: ratelimit.New(cfg.PasswordAttempts, time.Duration(cfg.PasswordAttemptWindow)),
+		Clicks:          tracker,
+		Webhooks:        hooks,
 	}
 
 	r := initRouter(h, authManager, s, logger)
@@ -77,9 +187,47 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
+	// SIGHUP reloads runtime lists without restarting the server
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			if err := bl.Reload(); err != nil {
+				logger.Errorw("failed to reload blocklist", "error", err)
+			} else {
+				logger.Infow("blocklist reloaded")
+			}
+			if err := pol.Reload(); err != nil {
+				logger.Errorw("failed to reload destination policy", "error", err)
+			} else {
+				logger.Infow("destination policy reloaded")
+			}
+			if err := hookPolicy.Reload(); err != nil {
+				logger.Errorw("failed to reload webhook target policy", "error", err)
+			} else {
+				logger.Infow("webhook target policy reloaded")
+			}
+			if err := botList.Reload(); err != nil {
+				logger.Errorw("failed to reload bot patterns", "error", err)
+			} else {
+				logger.Infow("bot patterns reloaded")
+			}
+			if err := authManager.Reload(); err != nil {
+				logger.Errorw("failed to reload signing keys", "error", err)
+			} else {
+				logger.Infow("signing keys reloaded")
+			}
+			if err := threats.Reload(); err != nil {
+				logger.Errorw("failed to reload threat list", "error", err)
+			} else {
+				flagListed()
+			}
+		}
+	}()
+
 	// gRPC сервер
 	grpcServer := grpc.NewServer()
-	pb.RegisterURLShortenerServer(grpcServer, handlers.NewURLShortener(s, authManager, cfg))
+	pb.RegisterURLShortenerServer(grpcServer, handlers.NewURLShortener(h))
 
 	// Register reflection service on gRPC server.
 	reflection.Register(grpcServer)
@@ -124,6 +272,14 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Errorw("server shutdown error", "error", err)
 	}
+	// Handlers have returned, so no more clicks are recorded: write the queued ones
+	if err := tracker.Close(shutdownCtx); err != nil {
+		logger.Errorw("failed to drain click events", "error", err, "stats", tracker.Stats())
+	}
+	// Deliveries that are not sent in time stay pending and are resumed on the next start
+	if err := hooks.Close(shutdownCtx); err != nil {
+		logger.Errorw("failed to finish webhook deliveries", "error", err)
+	}
 
 	logger.Infow("server gracefully stopped")
 }
@@ -175,16 +331,40 @@ func initRouter(h handlers.Handler, authManager *auth.Manager, storage db.Shorte
 		},
 	)
 
-	r.Get(`/{id}`,
+	urlReturner := func(w http.ResponseWriter, r *http.Request) {
+		middleware.Conveyor(
+			http.HandlerFunc(h.URLReturner),
+			logger,
+			middleware.WithLogging,
+			middleware.WriteWithCompression,
+			func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+				return middleware.WithAuth(next, authManager, storage, logger)
+			},
+		).ServeHTTP(w, r)
+	}
+	r.Get(`/{id}`, urlReturner)
+	r.Head(`/{id}`, urlReturner)
+	// chi prefers the static segment, so /{id}/qr is never treated as a path suffix
+	r.Get(`/{id}/qr`,
 		func(w http.ResponseWriter, r *http.Request) {
 			middleware.Conveyor(
-				http.HandlerFunc(h.URLReturner),
+				http.HandlerFunc(h.QRCode),
+				logger,
+				middleware.WithLogging,
+			).ServeHTTP(w, r)
+		},
+	)
+	// Links with path suffixes append the rest of the path to their destination
+	r.Get(`/{id}/*`, urlReturner)
+	r.Head(`/{id}/*`, urlReturner)
+
+	r.Post(`/{id}`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.URLUnlock),
 				logger,
 				middleware.WithLogging,
 				middleware.WriteWithCompression,
-				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
-					return middleware.WithAuth(next, authManager, storage, logger)
-				},
 			).ServeHTTP(w, r)
 		},
 	)
@@ -228,6 +408,176 @@ func initRouter(h handlers.Handler, authManager *auth.Manager, storage db.Shorte
 		},
 	)
 
+	r.Patch(`/api/user/urls/{id}`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.UpdateURL),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				middleware.ReadWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Get(`/api/user/urls/{id}/history`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.URLHistory),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Get(`/api/user/urls/{id}/stats`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.URLStats),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Post(`/api/user/urls/{id}/rollback`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.RollbackURL),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				middleware.ReadWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Get(`/api/user/export/clicks`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.ExportClicks),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Get(`/api/user/export/links`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.ExportLinks),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Post(`/api/user/webhooks`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.CreateWebhook),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				middleware.ReadWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Get(`/api/user/webhooks`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.ListWebhooks),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Delete(`/api/user/webhooks/{id}`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.DeleteWebhook),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Get(`/api/user/webhooks/{id}/deliveries`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.WebhookDeliveries),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Post(`/api/user/webhooks/{id}/deliveries/{delivery}/retry`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.RetryDelivery),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Delete(`/api/user/session`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.Logout),
+				logger,
+				middleware.WithLogging,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
 	r.Get(`/api/internal/stats`,
 		func(w http.ResponseWriter, r *http.Request) {
 			middleware.Conveyor(
@@ -241,5 +591,47 @@ func initRouter(h handlers.Handler, authManager *auth.Manager, storage db.Shorte
 		},
 	)
 
+	r.Get(`/api/internal/export/clicks`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.AdminExportClicks),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Get(`/api/internal/export/links`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.AdminExportLinks),
+				logger,
+				middleware.WithLogging,
+				middleware.WriteWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
+	r.Post(`/api/internal/tokens/revoke`,
+		func(w http.ResponseWriter, r *http.Request) {
+			middleware.Conveyor(
+				http.HandlerFunc(h.RevokeTokens),
+				logger,
+				middleware.WithLogging,
+				middleware.ReadWithCompression,
+				func(next http.Handler, _ *zap.SugaredLogger) http.Handler {
+					return middleware.WithAuth(next, authManager, storage, logger)
+				},
+			).ServeHTTP(w, r)
+		},
+	)
+
 	return r
 }
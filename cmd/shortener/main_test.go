@@ -1,19 +1,46 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/jayjaytrn/URLShortener/internal/middleware"
 
 	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/analytics"
+	"github.com/jayjaytrn/URLShortener/internal/auth"
+	"github.com/jayjaytrn/URLShortener/internal/clicks"
 	"github.com/jayjaytrn/URLShortener/internal/db/filestorage"
+	"github.com/jayjaytrn/URLShortener/internal/db/memorystorage"
 	"github.com/jayjaytrn/URLShortener/internal/handlers"
+	"github.com/jayjaytrn/URLShortener/internal/hll"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+	"github.com/jayjaytrn/URLShortener/internal/ratelimit"
+	"github.com/jayjaytrn/URLShortener/internal/rules"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+	"github.com/jayjaytrn/URLShortener/internal/urlshort"
+	"github.com/jayjaytrn/URLShortener/internal/webhooks"
+	"github.com/jayjaytrn/URLShortener/logging"
+	pb "github.com/jayjaytrn/URLShortener/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	grpcmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 func Test_urlWaiter(t *testing.T) {
@@ -146,7 +173,7 @@ func Test_urlReturner(t *testing.T) {
 			path:           "/test",
 			expectedCode:   http.StatusBadRequest,
 			expectedHeader: "",
-			expectedBody:   "only GET method is allowed\n",
+			expectedBody:   "only GET and HEAD methods are allowed\n",
 		},
 		{
 			name:           "Non-existent Short URL",
@@ -201,3 +228,1247 @@ func Test_urlReturner(t *testing.T) {
 		})
 	}
 }
+
+func Test_urlReturnerHead(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	left := int64(1)
+	storage.Put(types.URLData{ShortURL: "limited", OriginalURL: "https://practicum.yandex.ru/", ClicksLeft: &left})
+
+	tracker := clicks.New(storage, clicks.Options{QueueSize: 10, BatchSize: 1}, nil)
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+		Clicks:  tracker,
+	}
+	r := initRouter(handler, auth.NewManager(), storage, logging.GetSugaredLogger())
+
+	// A check of the link is answered like a visit without using up its only click
+	for _, method := range []string{http.MethodHead, http.MethodHead, http.MethodGet} {
+		req := httptest.NewRequest(method, "/limited", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusTemporaryRedirect || w.Header().Get("Location") != "https://practicum.yandex.ru/" {
+			t.Fatalf("expected %v to redirect, got %v", method, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/limited", nil))
+	if w.Code != http.StatusGone {
+		t.Errorf("expected the click to be used up by GET only, got %v", w.Code)
+	}
+
+	tracker.Close(context.Background())
+	bots := 0
+	storage.ForEachClick(context.Background(), "limited", time.Unix(0, 0), time.Now().Add(time.Minute), func(e types.ClickEvent) error {
+		if e.Bot {
+			bots++
+		}
+		return nil
+	})
+	if bots != 2 {
+		t.Errorf("expected HEAD requests to be recorded as bot visits, got %v", bots)
+	}
+}
+
+func Test_urlReturnerClickLimit(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	clicks := int64(3)
+	storage.Put(types.URLData{ShortURL: "limited", OriginalURL: "https://practicum.yandex.ru/", ClicksLeft: &clicks})
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+
+	// Concurrent hits must not redirect more often than allowed
+	var redirects atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler.URLReturner(w, httptest.NewRequest(http.MethodGet, "/limited", nil))
+			switch w.Code {
+			case http.StatusTemporaryRedirect:
+				redirects.Add(1)
+			case http.StatusGone:
+			default:
+				t.Errorf("unexpected status %v", w.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := redirects.Load(); got != int32(clicks) {
+		t.Errorf("expected %d redirects, got %d", clicks, got)
+	}
+
+	urls, err := storage.GetURLsByUserID("")
+	if err != nil || len(urls) != 1 || urls[0].ClicksLeft == nil || *urls[0].ClicksLeft != 0 {
+		t.Errorf("expected listing with no clicks left, got %v (%v)", urls, err)
+	}
+}
+
+func Test_urlUnlock(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+		LinkUnlockTTL: config.Duration(time.Minute),
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	urlData := types.URLData{ShortURL: "secret", OriginalURL: "https://practicum.yandex.ru/"}
+	if err := urlshort.ApplyCreateOptions(&urlData, types.CreateOptions{Password: "letmein"}, time.Now()); err != nil {
+		t.Fatalf("failed to apply options: %v", err)
+	}
+	storage.Put(urlData)
+
+	handler := handlers.Handler{
+		Storage:         storage,
+		Config:          cfg,
+		AuthManager:     auth.NewManager(),
+		PasswordLimiter: ratelimit.New(2, time.Minute),
+	}
+
+	unlock := func(password string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/secret", strings.NewReader("password="+password))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.URLUnlock(w, req)
+		return w.Result()
+	}
+	visit := func(cookies ...*http.Cookie) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler.URLReturner(w, req)
+		return w.Result()
+	}
+
+	if res := visit(); res.StatusCode != http.StatusOK || res.Header.Get("Location") != "" {
+		t.Errorf("expected password form, got %v", res.StatusCode)
+	}
+	if res := unlock("wrong"); res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected %v for wrong password, got %v", http.StatusForbidden, res.StatusCode)
+	}
+
+	res := unlock("letmein")
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected %v for correct password, got %v", http.StatusSeeOther, res.StatusCode)
+	}
+	if res = visit(res.Cookies()...); res.StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("expected redirect with unlock cookie, got %v", res.StatusCode)
+	}
+
+	unlock("wrong")
+	unlock("wrong")
+	if res = unlock("letmein"); res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected %v after repeated failures, got %v", http.StatusTooManyRequests, res.StatusCode)
+	}
+}
+
+func Test_urlUnlockConcurrent(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+		LinkUnlockTTL: config.Duration(time.Minute),
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	urlData := types.URLData{ShortURL: "secret", OriginalURL: "https://practicum.yandex.ru/"}
+	if err := urlshort.ApplyCreateOptions(&urlData, types.CreateOptions{Password: "letmein"}, time.Now()); err != nil {
+		t.Fatalf("failed to apply options: %v", err)
+	}
+	storage.Put(urlData)
+
+	attempts := 2
+	handler := handlers.Handler{
+		Storage:         storage,
+		Config:          cfg,
+		AuthManager:     auth.NewManager(),
+		PasswordLimiter: ratelimit.New(attempts, time.Minute),
+	}
+
+	// Parallel guesses must not be compared more often than allowed
+	var compared atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/secret", strings.NewReader("password=wrong"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			handler.URLUnlock(w, req)
+			switch w.Code {
+			case http.StatusForbidden:
+				compared.Add(1)
+			case http.StatusTooManyRequests:
+			default:
+				t.Errorf("unexpected status %v", w.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := compared.Load(); got != int32(attempts) {
+		t.Errorf("expected %d compared passwords, got %d", attempts, got)
+	}
+}
+
+func Test_urlListingPassword(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress:   "localhost:8080",
+		BaseURL:         "http://localhost:8080",
+		FileStoragePath: filepath.Join(t.TempDir(), "storage.json"),
+		StorageType:     "file",
+	}
+
+	storage, err := filestorage.NewManager(cfg)
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	urlData := types.URLData{ShortURL: "secret", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"}
+	if err = urlshort.ApplyCreateOptions(&urlData, types.CreateOptions{Password: "letmein"}, time.Now()); err != nil {
+		t.Fatalf("failed to apply options: %v", err)
+	}
+	storage.Put(urlData)
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	ctx := context.WithValue(req.Context(), middleware.UserIDKey, "owner")
+	w := httptest.NewRecorder()
+	handler.Urls(w, req.WithContext(ctx))
+
+	var listing []map[string]any
+	json.Unmarshal(w.Body.Bytes(), &listing)
+	if len(listing) != 1 || listing[0]["protected"] != true {
+		t.Fatalf("expected the link to be listed as protected, got %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), urlData.PasswordHash) || listing[0]["password_hash"] != nil {
+		t.Errorf("expected the listing not to contain the password hash, got %s", w.Body.String())
+	}
+	storage.Close(context.Background())
+
+	// The hash is still persisted, so the link stays protected after a restart
+	reopened, err := filestorage.NewManager(cfg)
+	if err != nil {
+		t.Fatalf("failed to reopen storage: %v", err)
+	}
+	defer reopened.Close(context.Background())
+	if stored, err := reopened.Get("secret"); err != nil || stored.PasswordHash != urlData.PasswordHash {
+		t.Errorf("expected the password hash to be persisted, got %q (%v)", stored.PasswordHash, err)
+	}
+}
+
+func Test_urlRedirectOptions(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress:    "localhost:8080",
+		BaseURL:          "http://localhost:8080",
+		StorageType:      "memory",
+		RedirectType:     http.StatusTemporaryRedirect,
+		RedirectCacheTTL: -1,
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	day := int64(86400)
+	storage.Put(types.URLData{ShortURL: "default", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+	storage.Put(types.URLData{
+		ShortURL:    "permanent",
+		OriginalURL: "https://practicum.yandex.ru/",
+		UserID:      "owner",
+		Options:     &types.LinkOptions{RedirectType: http.StatusMovedPermanently, CacheTTL: &day},
+	})
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+
+	update := func(id, userID, body string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/api/user/urls/"+id, strings.NewReader(body))
+		ctx := context.WithValue(req.Context(), middleware.UserIDKey, userID)
+		w := httptest.NewRecorder()
+		handler.UpdateURL(w, req.WithContext(ctx))
+		return w.Code
+	}
+
+	tests := []struct {
+		name          string
+		id            string
+		update        string
+		updateCode    int
+		expectedCode  int
+		expectedCache string
+	}{
+		{name: "Server default", id: "default", expectedCode: http.StatusTemporaryRedirect},
+		{name: "Permanent link", id: "permanent", expectedCode: http.StatusMovedPermanently, expectedCache: "public, max-age=86400"},
+		{name: "Edited to tracked link", id: "permanent", update: `{"redirect_type":302,"cache_ttl":0}`, updateCode: http.StatusOK, expectedCode: http.StatusFound, expectedCache: "no-store"},
+		{name: "Reset to defaults", id: "permanent", update: `{"redirect_type":0,"cache_ttl":null}`, updateCode: http.StatusOK, expectedCode: http.StatusTemporaryRedirect},
+		{name: "Invalid redirect type", id: "default", update: `{"redirect_type":200}`, updateCode: http.StatusBadRequest, expectedCode: http.StatusTemporaryRedirect},
+		{name: "Unknown field", id: "default", update: `{"colour":"red"}`, updateCode: http.StatusBadRequest, expectedCode: http.StatusTemporaryRedirect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.update != "" {
+				if code := update(tt.id, "owner", tt.update); code != tt.updateCode {
+					t.Errorf("expected update status %v, got %v", tt.updateCode, code)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			handler.URLReturner(w, httptest.NewRequest(http.MethodGet, "/"+tt.id, nil))
+			if w.Code != tt.expectedCode {
+				t.Errorf("expected status %v, got %v", tt.expectedCode, w.Code)
+			}
+			if cache := w.Header().Get("Cache-Control"); cache != tt.expectedCache {
+				t.Errorf("expected Cache-Control %q, got %q", tt.expectedCache, cache)
+			}
+		})
+	}
+
+	if code := update("permanent", "intruder", `{"redirect_type":301}`); code != http.StatusNotFound {
+		t.Errorf("expected %v when editing another user's URL, got %v", http.StatusNotFound, code)
+	}
+}
+
+func Test_urlPreview(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	clicks := int64(1)
+	storage.Put(types.URLData{ShortURL: "plain", OriginalURL: "https://practicum.yandex.ru/", ClicksLeft: &clicks})
+	storage.Put(types.URLData{
+		ShortURL:    "gated",
+		OriginalURL: "https://practicum.yandex.ru/",
+		Options:     &types.LinkOptions{Interstitial: true},
+	})
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+
+	tests := []struct {
+		name             string
+		target           string
+		expectedCode     int
+		expectedLocation string
+		expectedContinue string
+	}{
+		{name: "Plus suffix", target: "/plain+", expectedCode: http.StatusOK, expectedContinue: `href="/plain"`},
+		{name: "Query parameter", target: "/plain?preview=1", expectedCode: http.StatusOK, expectedContinue: `href="/plain"`},
+		{name: "Preview keeps the click", target: "/plain", expectedCode: http.StatusTemporaryRedirect, expectedLocation: "https://practicum.yandex.ru/"},
+		{name: "Interstitial link", target: "/gated", expectedCode: http.StatusOK, expectedContinue: `href="https://practicum.yandex.ru/"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.URLReturner(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.expectedCode {
+				t.Errorf("expected status %v, got %v", tt.expectedCode, w.Code)
+			}
+			if location := w.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("expected Location %q, got %q", tt.expectedLocation, location)
+			}
+			if tt.expectedContinue != "" && !strings.Contains(w.Body.String(), tt.expectedContinue) {
+				t.Errorf("expected page to contain %s, got %s", tt.expectedContinue, w.Body.String())
+			}
+		})
+	}
+
+	cfg.AlwaysInterstitial = true
+	w := httptest.NewRecorder()
+	handler.URLReturner(w, httptest.NewRequest(http.MethodGet, "/plain", nil))
+	if w.Code != http.StatusGone {
+		t.Errorf("expected %v for a used up link in interstitial mode, got %v", http.StatusGone, w.Code)
+	}
+}
+
+func Test_qrCode(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/"})
+	storage.Put(types.URLData{ShortURL: "deleted", OriginalURL: "https://practicum.yandex.ru/", DeletedFlag: true})
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+	r := initRouter(handler, auth.NewManager(), storage, logging.GetSugaredLogger())
+
+	tests := []struct {
+		name                string
+		target              string
+		expectedCode        int
+		expectedContentType string
+	}{
+		{name: "PNG by default", target: "/abcd1234/qr", expectedCode: http.StatusOK, expectedContentType: "image/png"},
+		{name: "SVG", target: "/abcd1234/qr?format=svg&size=128&ecc=H&margin=1&fg=003366", expectedCode: http.StatusOK, expectedContentType: "image/svg+xml"},
+		{name: "Invalid options", target: "/abcd1234/qr?size=1", expectedCode: http.StatusBadRequest},
+		{name: "Deleted link", target: "/deleted/qr", expectedCode: http.StatusGone},
+		{name: "Unknown link", target: "/missing/qr", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.expectedCode {
+				t.Errorf("expected status %v, got %v", tt.expectedCode, w.Code)
+			}
+			if tt.expectedContentType != "" && w.Header().Get("Content-Type") != tt.expectedContentType {
+				t.Errorf("expected Content-Type %q, got %q", tt.expectedContentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func Test_urlHistory(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+
+	call := func(fn http.HandlerFunc, method, target, userID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		ctx := context.WithValue(req.Context(), middleware.UserIDKey, userID)
+		w := httptest.NewRecorder()
+		fn(w, req.WithContext(ctx))
+		return w
+	}
+
+	steps := []struct {
+		name             string
+		fn               http.HandlerFunc
+		method           string
+		target           string
+		userID           string
+		body             string
+		expectedCode     int
+		expectedOriginal string
+		expectedVersions int
+	}{
+		{name: "Change destination", fn: handler.UpdateURL, method: http.MethodPatch, target: "/api/user/urls/abcd1234", userID: "owner",
+			body: `{"url":"https://example.com/fixed"}`, expectedCode: http.StatusOK, expectedOriginal: "https://example.com/fixed", expectedVersions: 2},
+		{name: "Invalid destination", fn: handler.UpdateURL, method: http.MethodPatch, target: "/api/user/urls/abcd1234", userID: "owner",
+			body: `{"url":"not a url"}`, expectedCode: http.StatusForbidden, expectedOriginal: "https://example.com/fixed", expectedVersions: 2},
+		{name: "Options only", fn: handler.UpdateURL, method: http.MethodPatch, target: "/api/user/urls/abcd1234", userID: "owner",
+			body: `{"redirect_type":302}`, expectedCode: http.StatusOK, expectedOriginal: "https://example.com/fixed", expectedVersions: 3},
+		{name: "Rollback", fn: handler.RollbackURL, method: http.MethodPost, target: "/api/user/urls/abcd1234/rollback", userID: "owner",
+			body: `{"version":1}`, expectedCode: http.StatusOK, expectedOriginal: "https://practicum.yandex.ru/", expectedVersions: 4},
+		{name: "Unknown revision", fn: handler.RollbackURL, method: http.MethodPost, target: "/api/user/urls/abcd1234/rollback", userID: "owner",
+			body: `{"version":9}`, expectedCode: http.StatusBadRequest, expectedOriginal: "https://practicum.yandex.ru/", expectedVersions: 4},
+		{name: "Another user", fn: handler.RollbackURL, method: http.MethodPost, target: "/api/user/urls/abcd1234/rollback", userID: "intruder",
+			body: `{"version":2}`, expectedCode: http.StatusNotFound, expectedOriginal: "https://practicum.yandex.ru/", expectedVersions: 4},
+	}
+
+	for _, s := range steps {
+		w := call(s.fn, s.method, s.target, s.userID, s.body)
+		if w.Code != s.expectedCode {
+			t.Errorf("%s: expected status %v, got %v", s.name, s.expectedCode, w.Code)
+		}
+
+		urlData, _ := storage.Get("abcd1234")
+		if urlData.OriginalURL != s.expectedOriginal {
+			t.Errorf("%s: expected destination %q, got %q", s.name, s.expectedOriginal, urlData.OriginalURL)
+		}
+
+		w = call(handler.URLHistory, http.MethodGet, "/api/user/urls/abcd1234/history", "owner", "")
+		var history []types.Revision
+		json.Unmarshal(w.Body.Bytes(), &history)
+		if len(history) != s.expectedVersions {
+			t.Errorf("%s: expected %d revisions, got %d", s.name, s.expectedVersions, len(history))
+		}
+	}
+}
+
+func Test_urlActivationWindow(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+		RedirectType:  http.StatusTemporaryRedirect,
+	}
+
+	now := time.Now()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "upcoming", OriginalURL: "https://practicum.yandex.ru/", Options: &types.LinkOptions{NotBefore: &later}})
+	storage.Put(types.URLData{ShortURL: "running", OriginalURL: "https://practicum.yandex.ru/", Options: &types.LinkOptions{NotBefore: &earlier, NotAfter: &later}})
+	storage.Put(types.URLData{ShortURL: "ended", OriginalURL: "https://practicum.yandex.ru/", Options: &types.LinkOptions{NotAfter: &earlier}})
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+
+	tests := []struct {
+		name         string
+		id           string
+		response     string
+		expectedCode int
+		expectedPage bool
+	}{
+		{name: "Before the window", id: "upcoming", expectedCode: http.StatusNotFound},
+		{name: "Inside the window", id: "running", expectedCode: http.StatusTemporaryRedirect},
+		{name: "After the window", id: "ended", expectedCode: http.StatusGone},
+		{name: "Page before the window", id: "upcoming", response: "page", expectedCode: http.StatusNotFound, expectedPage: true},
+		{name: "Page after the window", id: "ended", response: "page", expectedCode: http.StatusGone, expectedPage: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.InactiveResponse = tt.response
+			w := httptest.NewRecorder()
+			handler.URLReturner(w, httptest.NewRequest(http.MethodGet, "/"+tt.id, nil))
+			if w.Code != tt.expectedCode {
+				t.Errorf("expected status %v, got %v", tt.expectedCode, w.Code)
+			}
+			if page := strings.HasPrefix(w.Header().Get("Content-Type"), "text/html"); page != tt.expectedPage {
+				t.Errorf("expected HTML page %v, got Content-Type %q", tt.expectedPage, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func Test_urlStats(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+	}
+
+	now := time.Now().UTC()
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+	storage.PutClicks(context.Background(), []types.ClickEvent{
+		{ShortURL: "abcd1234", Time: now.Add(-2 * time.Hour), IPHash: "a"},
+		{ShortURL: "abcd1234", Time: now.Add(-time.Hour), IPHash: "a"},
+		{ShortURL: "abcd1234", Time: now.Add(-time.Hour), IPHash: "b", Referrer: "https://example.com/"},
+		{ShortURL: "other", Time: now.Add(-time.Hour), IPHash: "c"},
+		{ShortURL: "abcd1234", Time: now.Add(-time.Hour), UserAgent: "Twitterbot/1.0", Bot: true},
+	})
+	visitors := hll.New()
+	visitors.Add("a")
+	visitors.Add("b")
+	storage.MergeVisitors(context.Background(), []types.VisitorSketch{{ShortURL: "abcd1234", Day: now, Sketch: visitors}})
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		userID         string
+		expectedCode   int
+		expectedTotal  int64
+		expectedUnique int64
+	}{
+		{name: "Owner", target: "/api/user/urls/abcd1234/stats", userID: "owner", expectedCode: http.StatusOK, expectedTotal: 3, expectedUnique: 2},
+		{name: "Hourly range", target: "/api/user/urls/abcd1234/stats?interval=hour&from=" + now.Add(-90*time.Minute).Format(time.RFC3339),
+			userID: "owner", expectedCode: http.StatusOK, expectedTotal: 2, expectedUnique: 2},
+		{name: "Bots included", target: "/api/user/urls/abcd1234/stats?include_bots=true", userID: "owner", expectedCode: http.StatusOK, expectedTotal: 4, expectedUnique: 2},
+		{name: "Bad bot toggle", target: "/api/user/urls/abcd1234/stats?include_bots=maybe", userID: "owner", expectedCode: http.StatusBadRequest},
+		{name: "Bad interval", target: "/api/user/urls/abcd1234/stats?interval=year", userID: "owner", expectedCode: http.StatusBadRequest},
+		{name: "Another user", target: "/api/user/urls/abcd1234/stats", userID: "intruder", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			ctx := context.WithValue(req.Context(), middleware.UserIDKey, tt.userID)
+			w := httptest.NewRecorder()
+			handler.URLStats(w, req.WithContext(ctx))
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("expected status %v, got %v", tt.expectedCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var report analytics.Report
+			json.Unmarshal(w.Body.Bytes(), &report)
+			if report.TotalClicks != tt.expectedTotal || report.UniqueClicks != tt.expectedUnique {
+				t.Errorf("expected %d clicks from %d visitors, got %d from %d",
+					tt.expectedTotal, tt.expectedUnique, report.TotalClicks, report.UniqueClicks)
+			}
+		})
+	}
+}
+
+func Test_stats(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+		TrustedSubnet: "192.0.2.0/24",
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+	storage.Put(types.URLData{ShortURL: "efgh5678", OriginalURL: "https://example.com/", UserID: "owner"})
+	storage.PutClicks(context.Background(), []types.ClickEvent{{ShortURL: "abcd1234", Time: time.Now()}})
+
+	deletions := make(chan string, 1)
+	deletions <- "efgh5678"
+	close(deletions)
+	storage.BatchDelete(deletions, "owner")
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+
+	tests := []struct {
+		name         string
+		realIP       string
+		expectedCode int
+	}{
+		{name: "Trusted client", realIP: "192.0.2.10", expectedCode: http.StatusOK},
+		{name: "Untrusted client", realIP: "198.51.100.1", expectedCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			w := httptest.NewRecorder()
+			handler.Stats(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("expected status %v, got %v", tt.expectedCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var stats struct {
+				types.Stats
+				Backend struct {
+					Type    string `json:"type"`
+					Healthy bool   `json:"healthy"`
+				} `json:"backend"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &stats)
+			if stats.Urls != 1 || stats.Active != 1 || stats.Deleted != 1 || stats.Users != 1 || stats.Redirects != 1 {
+				t.Errorf("unexpected stats %+v", stats.Stats)
+			}
+			if stats.Backend.Type != "memory" || !stats.Backend.Healthy {
+				t.Errorf("unexpected backend health %+v", stats.Backend)
+			}
+		})
+	}
+}
+
+func Test_export(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+		TrustedSubnet: "192.0.2.0/24",
+	}
+
+	now := time.Now().UTC()
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+	storage.Put(types.URLData{ShortURL: "efgh5678", OriginalURL: "https://example.com/", UserID: "other"})
+	storage.Put(types.URLData{ShortURL: "ijkl9012", OriginalURL: "https://example.org/", UserID: "owner", DeletedFlag: true})
+	storage.PutClicks(context.Background(), []types.ClickEvent{
+		{ShortURL: "abcd1234", Time: now.Add(-2 * time.Hour), IPHash: "a"},
+		{ShortURL: "abcd1234", Time: now.Add(-time.Hour), IPHash: "b"},
+		{ShortURL: "efgh5678", Time: now.Add(-time.Hour), IPHash: "c"},
+		{ShortURL: "ijkl9012", Time: now.Add(-time.Hour), IPHash: "d"},
+	})
+
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		target        string
+		accept        string
+		realIP        string
+		userID        string
+		expectedCode  int
+		expectedType  string
+		expectedLines int
+	}{
+		{name: "User clicks as CSV", handler: handler.ExportClicks, target: "/api/user/export/clicks",
+			expectedCode: http.StatusOK, expectedType: "text/csv", expectedLines: 3},
+		{name: "User clicks as NDJSON", handler: handler.ExportClicks, target: "/api/user/export/clicks", accept: "application/x-ndjson",
+			expectedCode: http.StatusOK, expectedType: "application/x-ndjson", expectedLines: 2},
+		{name: "User clicks in range", handler: handler.ExportClicks, target: "/api/user/export/clicks?format=ndjson&from=" + now.Add(-90*time.Minute).Format(time.RFC3339),
+			expectedCode: http.StatusOK, expectedType: "application/x-ndjson", expectedLines: 1},
+		{name: "User links", handler: handler.ExportLinks, target: "/api/user/export/links",
+			expectedCode: http.StatusOK, expectedType: "text/csv", expectedLines: 2},
+		{name: "Bad format", handler: handler.ExportClicks, target: "/api/user/export/clicks?format=xml", expectedCode: http.StatusBadRequest},
+		{name: "Bad range", handler: handler.ExportClicks, target: "/api/user/export/clicks?from=" + now.Format(time.RFC3339) + "&to=" + now.Add(-time.Hour).Format(time.RFC3339),
+			expectedCode: http.StatusBadRequest},
+		{name: "User without links", handler: handler.ExportClicks, target: "/api/user/export/clicks", userID: "stranger",
+			expectedCode: http.StatusOK, expectedType: "text/csv", expectedLines: 1},
+		{name: "Admin links", handler: handler.AdminExportLinks, target: "/api/internal/export/links", realIP: "192.0.2.10",
+			expectedCode: http.StatusOK, expectedType: "text/csv", expectedLines: 4},
+		{name: "Admin clicks", handler: handler.AdminExportClicks, target: "/api/internal/export/clicks?format=ndjson", realIP: "192.0.2.10",
+			expectedCode: http.StatusOK, expectedType: "application/x-ndjson", expectedLines: 4},
+		{name: "Admin clicks from outside", handler: handler.AdminExportClicks, target: "/api/internal/export/clicks", realIP: "198.51.100.1",
+			expectedCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("Accept", tt.accept)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			userID := tt.userID
+			if userID == "" {
+				userID = "owner"
+			}
+			ctx := context.WithValue(req.Context(), middleware.UserIDKey, userID)
+			w := httptest.NewRecorder()
+			tt.handler(w, req.WithContext(ctx))
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("expected status %v, got %v", tt.expectedCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.expectedType {
+				t.Errorf("expected content type %q, got %q", tt.expectedType, contentType)
+			}
+			if lines := strings.Count(w.Body.String(), "\n"); lines != tt.expectedLines {
+				t.Errorf("expected %d lines, got %d:\n%s", tt.expectedLines, lines, w.Body.String())
+			}
+		})
+	}
+}
+
+func Test_exportCompression(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+	handler := handlers.Handler{
+		Storage: storage,
+		Config:  cfg,
+	}
+
+	logger := logging.GetSugaredLogger()
+	h := middleware.Conveyor(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), middleware.UserIDKey, "owner")
+			handler.ExportLinks(w, r.WithContext(ctx))
+		}),
+		logger,
+		middleware.WithLogging,
+		middleware.WriteWithCompression,
+	)
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+	}{
+		{name: "Accept header", target: "/api/user/export/links", accept: "text/csv"},
+		{name: "Format parameter", target: "/api/user/export/links?format=csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("Accept", tt.accept)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Header().Get("Content-Encoding") != "gzip" {
+				t.Fatalf("expected a gzip response, got headers %v", w.Header())
+			}
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			body, _ := io.ReadAll(zr)
+			if !strings.HasPrefix(string(body), "short_url,original_url") || !strings.Contains(string(body), "abcd1234") {
+				t.Errorf("unexpected export %q", body)
+			}
+		})
+	}
+}
+
+func Test_webhooks(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+	}
+
+	received := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(webhooks.HeaderEvent)
+	}))
+	defer receiver.Close()
+
+	storage, _ := memorystorage.NewManager(cfg)
+	loopback, _ := policy.New(policy.File{Allow: policy.Rules{CIDRs: []string{"127.0.0.0/8"}}})
+	dispatcher := webhooks.New(storage, webhooks.Options{BaseURL: cfg.BaseURL, Policy: loopback}, nil)
+	defer dispatcher.Close(context.Background())
+	handler := handlers.Handler{
+		Storage:  dispatcher.Wrap(storage),
+		Config:   cfg,
+		Webhooks: dispatcher,
+	}
+
+	call := func(h http.HandlerFunc, method, target, body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		ctx := context.WithValue(req.Context(), middleware.UserIDKey, userID)
+		w := httptest.NewRecorder()
+		h(w, req.WithContext(ctx))
+		return w
+	}
+
+	w := call(handler.CreateWebhook, http.MethodPost, "/api/user/webhooks", `{"url":"ftp://example.com/"}`, "owner")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %v for an invalid URL, got %v", http.StatusBadRequest, w.Code)
+	}
+
+	w = call(handler.CreateWebhook, http.MethodPost, "/api/user/webhooks", `{"url":"`+receiver.URL+`","events":["link.created"]}`, "owner")
+	var hook types.Webhook
+	json.Unmarshal(w.Body.Bytes(), &hook)
+	if w.Code != http.StatusCreated || hook.ID == "" || hook.Secret == "" {
+		t.Fatalf("unexpected response %v %s", w.Code, w.Body.String())
+	}
+
+	w = call(handler.ListWebhooks, http.MethodGet, "/api/user/webhooks", "", "owner")
+	var hooks []types.Webhook
+	json.Unmarshal(w.Body.Bytes(), &hooks)
+	if len(hooks) != 1 || hooks[0].ID != hook.ID || hooks[0].Secret != "" {
+		t.Errorf("expected the webhook without its secret, got %s", w.Body.String())
+	}
+
+	call(handler.Shorten, http.MethodPost, "/api/shorten", `{"url":"https://practicum.yandex.ru/"}`, "owner")
+	select {
+	case event := <-received:
+		if event != types.EventLinkCreated {
+			t.Errorf("unexpected event %q", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event received")
+	}
+
+	var deliveries []types.Delivery
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		w = call(handler.WebhookDeliveries, http.MethodGet, "/api/user/webhooks/"+hook.ID+"/deliveries?status=delivered", "", "owner")
+		json.Unmarshal(w.Body.Bytes(), &deliveries)
+		if len(deliveries) > 0 {
+			break
+		}
+	}
+	if len(deliveries) != 1 || deliveries[0].Attempts != 1 || deliveries[0].ResponseCode != http.StatusOK {
+		t.Errorf("expected a delivery in the log, got %s", w.Body.String())
+	}
+
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		method       string
+		target       string
+		userID       string
+		expectedCode int
+	}{
+		{name: "Log of another user", handler: handler.WebhookDeliveries, method: http.MethodGet,
+			target: "/api/user/webhooks/" + hook.ID + "/deliveries", userID: "intruder", expectedCode: http.StatusNotFound},
+		{name: "Bad status", handler: handler.WebhookDeliveries, method: http.MethodGet,
+			target: "/api/user/webhooks/" + hook.ID + "/deliveries?status=lost", userID: "owner", expectedCode: http.StatusBadRequest},
+		{name: "Retry of a delivered event", handler: handler.RetryDelivery, method: http.MethodPost,
+			target: "/api/user/webhooks/" + hook.ID + "/deliveries/" + deliveries[0].ID + "/retry", userID: "owner", expectedCode: http.StatusNotFound},
+		{name: "Delete by another user", handler: handler.DeleteWebhook, method: http.MethodDelete,
+			target: "/api/user/webhooks/" + hook.ID, userID: "intruder", expectedCode: http.StatusNotFound},
+		{name: "Delete", handler: handler.DeleteWebhook, method: http.MethodDelete,
+			target: "/api/user/webhooks/" + hook.ID, userID: "owner", expectedCode: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := call(tt.handler, tt.method, tt.target, "", tt.userID); w.Code != tt.expectedCode {
+				t.Errorf("expected status %v, got %v", tt.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func Test_sessions(t *testing.T) {
+	cfg := &config.Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080",
+		StorageType:   "memory",
+		TrustedSubnet: "192.168.1.0/24",
+	}
+
+	storage, _ := memorystorage.NewManager(cfg)
+	authManager, err := auth.Load(context.Background(), auth.Options{Keys: []string{"main:secret"}, TokenTTL: 2 * time.Hour, RefreshWindow: 90 * time.Minute})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := handlers.Handler{
+		Storage:     storage,
+		Config:      cfg,
+		AuthManager: authManager,
+	}
+	r := initRouter(handler, authManager, storage, logging.GetSugaredLogger())
+
+	// call sends a request with the given auth cookie and returns the response
+	// with the last auth cookie it sets
+	call := func(method, target, token, body string) (*httptest.ResponseRecorder, *http.Cookie) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Real-IP", "192.168.1.10")
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "Authorization", Value: token})
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var cookie *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "Authorization" {
+				cookie = c
+			}
+		}
+		return w, cookie
+	}
+
+	w, cookie := call(http.MethodDelete, "/api/user/session", "", "")
+	if w.Code != http.StatusUnauthorized || cookie == nil || cookie.MaxAge != int((2*time.Hour).Seconds()) {
+		t.Fatalf("expected a new cookie with the token lifetime, got %v %+v", w.Code, cookie)
+	}
+	session := cookie.Value
+
+	// A token with less than the refresh window left is reissued for the same session
+	short, _ := auth.Load(context.Background(), auth.Options{Keys: []string{"main:secret"}, TokenTTL: time.Hour, RefreshWindow: time.Minute})
+	old, _ := short.BuildJWTStringWithNewID("user")
+	_, cookie = call(http.MethodGet, "/api/user/urls", old, "")
+	if cookie == nil {
+		t.Fatalf("expected a token close to its expiry to be refreshed")
+	}
+	oldClaims, _ := authManager.ParseToken(old)
+	refreshed, err := authManager.ParseToken(cookie.Value)
+	if err != nil || refreshed.UserID != "user" || refreshed.ID != oldClaims.ID || !refreshed.ExpiresAt.After(oldClaims.ExpiresAt.Time) {
+		t.Errorf("expected the refreshed token to extend the session, got %+v, %v", refreshed, err)
+	}
+	if _, cookie = call(http.MethodGet, "/api/user/urls", session, ""); cookie != nil {
+		t.Errorf("expected a fresh token not to be reissued")
+	}
+
+	w, cookie = call(http.MethodDelete, "/api/user/session", session, "")
+	if w.Code != http.StatusNoContent || cookie == nil || cookie.MaxAge >= 0 {
+		t.Fatalf("expected the session to end and the cookie to be removed, got %v %+v", w.Code, cookie)
+	}
+	w, cookie = call(http.MethodDelete, "/api/user/session", session, "")
+	if w.Code != http.StatusUnauthorized || cookie == nil || cookie.Value == session {
+		t.Errorf("expected a revoked token to be replaced, got %v %+v", w.Code, cookie)
+	}
+
+	w, _ = call(http.MethodPost, "/api/internal/tokens/revoke", "", `[""]`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %v for an empty token ID, got %v", http.StatusBadRequest, w.Code)
+	}
+	w, _ = call(http.MethodPost, "/api/internal/tokens/revoke", "", `["`+oldClaims.ID+`"]`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %v, got %v", http.StatusNoContent, w.Code)
+	}
+	if _, cookie = call(http.MethodGet, "/api/user/urls", old, ""); cookie == nil || cookie.Value == old {
+		t.Errorf("expected every token of a revoked session to be rejected")
+	}
+}
+
+func Test_grpcURLReturnerUnavailable(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	storage, _ := memorystorage.NewManager(cfg)
+
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	noClicks := int64(0)
+	for _, urlData := range []types.URLData{
+		{ShortURL: "deleted", OriginalURL: "https://example.com/1", DeletedFlag: true},
+		{ShortURL: "expired", OriginalURL: "https://example.com/2", ExpiresAt: &past},
+		{ShortURL: "exhausted", OriginalURL: "https://example.com/3", ClicksLeft: &noClicks},
+		{ShortURL: "ended", OriginalURL: "https://example.com/4", Options: &types.LinkOptions{NotAfter: &past}},
+		{ShortURL: "upcoming", OriginalURL: "https://example.com/5", Options: &types.LinkOptions{NotBefore: &future}},
+	} {
+		storage.Put(urlData)
+	}
+	server := handlers.NewURLShortener(handlers.Handler{Storage: storage, Config: cfg})
+
+	tests := []struct {
+		id     string
+		code   codes.Code
+		reason string
+	}{
+		{id: "deleted", code: codes.FailedPrecondition, reason: "deleted"},
+		{id: "expired", code: codes.FailedPrecondition, reason: "expired"},
+		{id: "exhausted", code: codes.FailedPrecondition, reason: "no_clicks_left"},
+		{id: "ended", code: codes.FailedPrecondition, reason: "no_longer_active"},
+		{id: "upcoming", code: codes.NotFound},
+		{id: "unknown", code: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			_, err := server.URLReturner(context.Background(), &pb.URLReturnerRequest{ShortUrl: "/" + tt.id})
+			st := status.Convert(err)
+			if st.Code() != tt.code {
+				t.Fatalf("expected code %v, got %v: %v", tt.code, st.Code(), err)
+			}
+			var reason string
+			for _, detail := range st.Details() {
+				if info, ok := detail.(*errdetails.ErrorInfo); ok {
+					reason = info.Reason
+				}
+			}
+			if reason != tt.reason {
+				t.Errorf("expected reason %q, got %q", tt.reason, reason)
+			}
+		})
+	}
+}
+
+func Test_grpcURLReturnerClicks(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/"})
+
+	tracker := clicks.New(storage, clicks.Options{QueueSize: 10, BatchSize: 1, IPSalt: "salt"}, nil)
+	server := handlers.NewURLShortener(handlers.Handler{Storage: storage, Config: cfg, Clicks: tracker})
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000}})
+	ctx = grpcmetadata.NewIncomingContext(ctx, grpcmetadata.Pairs("user-agent", "Mozilla/5.0", "referer", "https://example.com/", "accept-language", "en"))
+	if _, err := server.URLReturner(ctx, &pb.URLReturnerRequest{ShortUrl: "/abcd1234"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tracker.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var events []types.ClickEvent
+	storage.ForEachClick(context.Background(), "abcd1234", time.Unix(0, 0), time.Now().Add(time.Minute), func(e types.ClickEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	if len(events) != 1 {
+		t.Fatalf("expected the call to be recorded once, got %v events", len(events))
+	}
+	e := events[0]
+	if e.IPHash != tracker.HashIP("192.0.2.1") || e.UserAgent != "Mozilla/5.0" || e.Referrer != "https://example.com/" || e.Language != "en" {
+		t.Errorf("expected the event to be built from the peer and metadata, got %+v", e)
+	}
+}
+
+func Test_grpcURLStatsOwner(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+
+	authManager := auth.NewManager()
+	server := handlers.NewURLShortener(handlers.Handler{Storage: storage, Config: cfg, AuthManager: authManager})
+	ownerToken, _ := authManager.BuildJWTStringWithNewID("owner")
+	intruderToken, _ := authManager.BuildJWTStringWithNewID("intruder")
+
+	tests := []struct {
+		name  string
+		token string
+		code  codes.Code
+	}{
+		{name: "Owner", token: ownerToken, code: codes.OK},
+		{name: "Bearer", token: "Bearer " + ownerToken, code: codes.OK},
+		{name: "Another user", token: intruderToken, code: codes.NotFound},
+		{name: "Invalid token", token: "invalid", code: codes.Unauthenticated},
+		{name: "No token", code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = grpcmetadata.NewIncomingContext(ctx, grpcmetadata.Pairs("authorization", tt.token))
+			}
+			// The user ID of the request is ignored in favour of the token
+			_, err := server.URLStats(ctx, &pb.URLStatsRequest{UserId: "owner", ShortUrl: "/abcd1234"})
+			if code := status.Code(err); code != tt.code {
+				t.Errorf("expected code %v, got %v: %v", tt.code, code, err)
+			}
+		})
+	}
+}
+
+func Test_grpcUpdateURLOwner(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+
+	authManager := auth.NewManager()
+	server := handlers.NewURLShortener(handlers.Handler{Storage: storage, Config: cfg, AuthManager: authManager})
+	withToken := func(userID string) context.Context {
+		token, _ := authManager.BuildJWTStringWithNewID(userID)
+		return grpcmetadata.NewIncomingContext(context.Background(), grpcmetadata.Pairs("authorization", token))
+	}
+	url := "https://example.com/"
+
+	// The user ID of the requests is ignored in favour of the token
+	if _, err := server.UpdateURL(context.Background(), &pb.UpdateURLRequest{UserId: "owner", ShortUrl: "abcd1234", Url: &url}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected an update without a token to be refused, got %v", err)
+	}
+	if _, err := server.UpdateURL(withToken("intruder"), &pb.UpdateURLRequest{UserId: "owner", ShortUrl: "abcd1234", Url: &url}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected an update by another user to be refused, got %v", err)
+	}
+	if _, err := server.RollbackURL(withToken("intruder"), &pb.RollbackURLRequest{UserId: "owner", ShortUrl: "abcd1234", Version: 1}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected a rollback by another user to be refused, got %v", err)
+	}
+	if _, err := server.URLHistory(withToken("intruder"), &pb.URLHistoryRequest{UserId: "owner", ShortUrl: "abcd1234"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected the history to be hidden from another user, got %v", err)
+	}
+	if original, _ := storage.GetOriginal("abcd1234"); original != "https://practicum.yandex.ru/" {
+		t.Fatalf("expected the destination to be kept, got %q", original)
+	}
+
+	if _, err := server.UpdateURL(withToken("owner"), &pb.UpdateURLRequest{UserId: "intruder", ShortUrl: "abcd1234", Url: &url}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	history, err := server.URLHistory(withToken("owner"), &pb.URLHistoryRequest{ShortUrl: "abcd1234"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	revisions := history.GetRevisions()
+	if len(revisions) != 2 || revisions[1].GetChangedBy() != "owner" {
+		t.Errorf("expected the change to be recorded for the token user, got %v", revisions)
+	}
+}
+
+func Test_grpcURLReturnerRules(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://example.com/", Options: &types.LinkOptions{
+		Rules: []types.RedirectRule{{UserAgent: "ios", URL: "https://apps.apple.com/app"}},
+	}})
+	server := handlers.NewURLShortener(handlers.Handler{Storage: storage, Config: cfg})
+
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{name: "Matching rule", userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", want: "https://apps.apple.com/app"},
+		{name: "No matching rule", userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", want: "https://example.com/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := grpcmetadata.NewIncomingContext(context.Background(), grpcmetadata.Pairs("user-agent", tt.userAgent))
+			resp, err := server.URLReturner(ctx, &pb.URLReturnerRequest{ShortUrl: "/abcd1234"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.GetOriginalUrl() != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, resp.GetOriginalUrl())
+			}
+		})
+	}
+}
+
+func Test_grpcURLReturnerVariants(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080", SplitVisitorHeader: "X-Visitor-Id"}
+	storage, _ := memorystorage.NewManager(cfg)
+	variants := []types.Variant{{URL: "https://example.com/a", Weight: 1}, {URL: "https://example.com/b", Weight: 1}}
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://example.com/", Options: &types.LinkOptions{Variants: variants}, Assignments: make([]int64, len(variants))})
+	server := handlers.NewURLShortener(handlers.Handler{Storage: storage, Config: cfg})
+
+	want := make([]int64, len(variants))
+	for i := 0; i < 20; i++ {
+		visitor := strconv.Itoa(i)
+		ctx := grpcmetadata.NewIncomingContext(context.Background(), grpcmetadata.Pairs("x-visitor-id", visitor))
+		resp, err := server.URLReturner(ctx, &pb.URLReturnerRequest{ShortUrl: "/abcd1234"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Visitors are assigned like HTTP visitors without a cookie
+		v := rules.ChooseVariant(variants, "abcd1234|"+visitor)
+		if resp.GetOriginalUrl() != variants[v].URL {
+			t.Errorf("expected visitor %v to get %q, got %q", visitor, variants[v].URL, resp.GetOriginalUrl())
+		}
+		want[v]++
+	}
+
+	urlData, _ := storage.Get("abcd1234")
+	if !slices.Equal(urlData.Assignments, want) {
+		t.Errorf("expected assignments %v, got %v", want, urlData.Assignments)
+	}
+}
+
+func Test_grpcURLReturnerUTM(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	storage, _ := memorystorage.NewManager(cfg)
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://example.com/?id=1", Options: &types.LinkOptions{
+		UTM: map[string]string{"utm_source": "newsletter", "utm_campaign": "{code}"},
+	}})
+	server := handlers.NewURLShortener(handlers.Handler{Storage: storage, Config: cfg})
+
+	resp, err := server.URLReturner(context.Background(), &pb.URLReturnerRequest{ShortUrl: "/abcd1234"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "https://example.com/?id=1&utm_campaign=abcd1234&utm_source=newsletter"
+	if resp.GetOriginalUrl() != want {
+		t.Errorf("expected %q, got %q", want, resp.GetOriginalUrl())
+	}
+}
@@ -4,11 +4,61 @@ import (
 	"encoding/json"
 	"flag"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/jayjaytrn/URLShortener/logging"
 )
 
+// Duration is a time.Duration that is parsed from strings such as "5m" in flags,
+// environment variables and the JSON config file.
+type Duration time.Duration
+
+// UnmarshalText parses a duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText formats the duration as a string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Default values for settings that are not zero by default.
+const (
+	defaultThreatListReloadInterval = Duration(5 * time.Minute)
+	defaultSelfReferenceMode        = "reject"
+	defaultMaxRedirectChain         = 3
+	defaultExpirySweepInterval      = Duration(time.Hour)
+	defaultExpiredRetention         = Duration(24 * time.Hour)
+	defaultLinkUnlockTTL            = Duration(10 * time.Minute)
+	defaultPasswordAttempts         = 5
+	defaultPasswordAttemptWindow    = Duration(15 * time.Minute)
+	defaultRedirectType             = 307
+	defaultRedirectCacheTTL         = -1
+	defaultInactiveResponse         = "status"
+	defaultClickQueueSize           = 10000
+	defaultClickBatchSize           = 500
+	defaultClickFlushInterval       = Duration(time.Second)
+	defaultWebhookMaxAttempts       = 8
+	defaultWebhookRetryBackoff      = Duration(30 * time.Second)
+	defaultWebhookTimeout           = Duration(10 * time.Second)
+	defaultWebhookExpiryInterval    = Duration(time.Minute)
+	defaultAuthTokenTTL             = Duration(30 * 24 * time.Hour)
+	defaultAuthRefreshWindow        = Duration(7 * 24 * time.Hour)
+	defaultRevocationSyncInterval   = Duration(30 * time.Second)
+)
+
+// defaultKnownShorteners lists public shortener domains followed when checking for redirect chains.
+var defaultKnownShorteners = []string{"bit.ly", "t.co", "tinyurl.com", "goo.gl", "ow.ly", "is.gd", "buff.ly", "rebrand.ly", "cutt.ly"}
+
 // Config stores configuration settings for the URL shortener service.
 type Config struct {
 	ServerAddress   string `env:"SERVER_ADDRESS,required" json:"server_address"` // Server address to listen on
@@ -18,6 +68,56 @@ type Config struct {
 	StorageType     string // Storage type: memory, file, or postgres (не загружается из JSON)
 	EnableHTTPS     bool   `env:"ENABLE_HTTPS" json:"enable_https"`     // Enable HTTPS
 	TrustedSubnet   string `env:"TRUSTED_SUBNET" json:"trusted_subnet"` // CIDR trusted subnet
+	BlocklistPath   string `env:"BLOCKLIST_PATH" json:"blocklist_path"` // Path to the short code word blocklist
+	StripFragment   bool   `env:"STRIP_FRAGMENT" json:"strip_fragment"` // Drop URL fragments when normalising
+	SortQuery       bool   `env:"SORT_QUERY" json:"sort_query"`         // Sort query parameters when normalising
+	PolicyPath      string `env:"POLICY_PATH" json:"policy_path"`       // Path to the destination allow/deny policy
+
+	ThreatListPath           string   `env:"THREAT_LIST_PATH" json:"threat_list_path"`                       // Path to the hashed malicious URL list
+	ThreatListReloadInterval Duration `env:"THREAT_LIST_RELOAD_INTERVAL" json:"threat_list_reload_interval"` // How often the threat list file is checked for changes
+	ThreatCheckRedirects     bool     `env:"THREAT_CHECK_REDIRECTS" json:"threat_check_redirects"`           // Check destinations against the threat list on every redirect
+
+	AliasHosts        []string `env:"ALIAS_HOSTS" json:"alias_hosts"`                 // Additional hosts that serve the same short links as BaseURL
+	SelfReferenceMode string   `env:"SELF_REFERENCE_MODE" json:"self_reference_mode"` // How links to the service itself are handled: reject or flatten
+	KnownShorteners   []string `env:"KNOWN_SHORTENERS" json:"known_shorteners"`       // Shortener domains followed to detect redirect chains
+	MaxRedirectChain  int      `env:"MAX_REDIRECT_CHAIN" json:"max_redirect_chain"`   // Maximum number of redirects followed when resolving a destination
+
+	ExpirySweepInterval Duration `env:"EXPIRY_SWEEP_INTERVAL" json:"expiry_sweep_interval"` // How often expired links are removed; 0 disables the sweeper
+	ExpiredRetention    Duration `env:"EXPIRED_RETENTION" json:"expired_retention"`         // How long expired links are kept before removal
+
+	LinkUnlockTTL         Duration `env:"LINK_UNLOCK_TTL" json:"link_unlock_ttl"`                 // How long a password-protected link stays unlocked after a correct password
+	PasswordAttempts      int      `env:"PASSWORD_ATTEMPTS" json:"password_attempts"`             // Wrong passwords allowed per link and IP within PasswordAttemptWindow
+	PasswordAttemptWindow Duration `env:"PASSWORD_ATTEMPT_WINDOW" json:"password_attempt_window"` // Window in which wrong password attempts are counted
+
+	RedirectType     int `env:"REDIRECT_TYPE" json:"redirect_type"`           // Default redirect status for links without their own: 301, 302, 307 or 308
+	RedirectCacheTTL int `env:"REDIRECT_CACHE_TTL" json:"redirect_cache_ttl"` // Default redirect cache lifetime in seconds; 0 sends no-store, -1 sends no Cache-Control header
+
+	SplitVisitorHeader string `env:"SPLIT_VISITOR_HEADER" json:"split_visitor_header"` // Request header with a stable visitor ID used for A/B split assignment
+
+	AlwaysInterstitial bool `env:"ALWAYS_INTERSTITIAL" json:"always_interstitial"` // Show the preview page instead of redirecting for every link
+
+	InactiveResponse string `env:"INACTIVE_RESPONSE" json:"inactive_response"` // Response for links outside their activation window: status or page
+
+	ClickQueueSize     int      `env:"CLICK_QUEUE_SIZE" json:"click_queue_size"`         // Number of click events buffered before new ones are dropped; 0 disables click tracking
+	ClickBatchSize     int      `env:"CLICK_BATCH_SIZE" json:"click_batch_size"`         // Maximum number of click events written to storage at once
+	ClickFlushInterval Duration `env:"CLICK_FLUSH_INTERVAL" json:"click_flush_interval"` // How often buffered click events are written to storage
+	ClickIPSalt        string   `env:"CLICK_IP_SALT" json:"click_ip_salt"`               // Secret mixed into visitor IP hashes; a random one is generated and kept in storage if empty
+	CountryHeader      string   `env:"COUNTRY_HEADER" json:"country_header"`             // Request header with the visitor country set by a trusted proxy, e.g. CF-IPCountry
+	BotPatternsPath    string   `env:"BOT_PATTERNS_PATH" json:"bot_patterns_path"`       // Path to the list of User-Agent patterns of bots and link unfurlers
+
+	WebhookMaxAttempts    int      `env:"WEBHOOK_MAX_ATTEMPTS" json:"webhook_max_attempts"`       // Attempts of a webhook delivery before it is kept as a dead letter
+	WebhookRetryBackoff   Duration `env:"WEBHOOK_RETRY_BACKOFF" json:"webhook_retry_backoff"`     // Delay before the first retry of a webhook delivery; it doubles with every attempt
+	WebhookTimeout        Duration `env:"WEBHOOK_TIMEOUT" json:"webhook_timeout"`                 // Time limit of a single webhook delivery attempt
+	WebhookExpiryInterval Duration `env:"WEBHOOK_EXPIRY_INTERVAL" json:"webhook_expiry_interval"` // How often links are checked for expiry to notify webhooks
+	WebhookPolicyPath     string   `env:"WEBHOOK_POLICY_PATH" json:"webhook_policy_path"`         // Path to the allow/deny policy of webhook targets; private addresses are denied if empty
+
+	AuthKeys     []string `env:"AUTH_KEYS" json:"auth_keys"`           // JWT signing keys written as kid:secret, the first of which signs new tokens; a key generated once and kept in storage is used if empty
+	AuthKeysPath string   `env:"AUTH_KEYS_PATH" json:"auth_keys_path"` // Path to a file with one JWT signing key per line, reloaded on SIGHUP
+
+	AuthTokenTTL      Duration `env:"AUTH_TOKEN_TTL" json:"auth_token_ttl"`           // Lifetime of the token in the auth cookie
+	AuthRefreshWindow Duration `env:"AUTH_REFRESH_WINDOW" json:"auth_refresh_window"` // How long before its expiry the auth cookie is reissued on a request
+
+	RevocationSyncInterval Duration `env:"REVOCATION_SYNC_INTERVAL" json:"revocation_sync_interval"` // How often revoked sessions are loaded from storage; sessions revoked by other instances are accepted until then
 }
 
 // GetConfig initializes and returns the application configuration.
@@ -38,6 +138,54 @@ func GetConfig() *Config {
 	flag.StringVar(&config.DatabaseDSN, "d", "", "database DSN")
 	flag.BoolVar(&config.EnableHTTPS, "s", false, "enable https")
 	flag.StringVar(&config.TrustedSubnet, "t", "", "trusted subnet in CIDR format")
+	flag.StringVar(&config.BlocklistPath, "blocklist", "", "path to short code word blocklist")
+	flag.BoolVar(&config.StripFragment, "strip-fragment", false, "drop URL fragments when normalising")
+	flag.BoolVar(&config.SortQuery, "sort-query", false, "sort query parameters when normalising")
+	flag.StringVar(&config.PolicyPath, "policy", "", "path to destination allow/deny policy")
+	flag.StringVar(&config.ThreatListPath, "threat-list", "", "path to hashed malicious URL list")
+	flag.TextVar(&config.ThreatListReloadInterval, "threat-list-reload", defaultThreatListReloadInterval, "threat list reload check interval")
+	flag.BoolVar(&config.ThreatCheckRedirects, "threat-check-redirects", false, "check destinations against the threat list on redirect")
+	flag.Func("alias-hosts", "comma-separated hosts that serve the same short links", func(s string) error {
+		config.AliasHosts = splitList(s)
+		return nil
+	})
+	flag.StringVar(&config.SelfReferenceMode, "self-reference", defaultSelfReferenceMode, "handling of links to the service itself: reject or flatten")
+	config.KnownShorteners = defaultKnownShorteners
+	flag.Func("known-shorteners", "comma-separated shortener domains followed to detect redirect chains", func(s string) error {
+		config.KnownShorteners = splitList(s)
+		return nil
+	})
+	flag.IntVar(&config.MaxRedirectChain, "max-redirect-chain", defaultMaxRedirectChain, "maximum number of redirects followed when resolving a destination")
+	flag.TextVar(&config.ExpirySweepInterval, "expiry-sweep", defaultExpirySweepInterval, "interval between removals of expired links, 0 disables")
+	flag.TextVar(&config.ExpiredRetention, "expired-retention", defaultExpiredRetention, "how long expired links are kept before removal")
+	flag.TextVar(&config.LinkUnlockTTL, "link-unlock-ttl", defaultLinkUnlockTTL, "how long a password-protected link stays unlocked")
+	flag.IntVar(&config.PasswordAttempts, "password-attempts", defaultPasswordAttempts, "wrong passwords allowed per link and IP within the attempt window")
+	flag.TextVar(&config.PasswordAttemptWindow, "password-attempt-window", defaultPasswordAttemptWindow, "window in which wrong password attempts are counted")
+	flag.IntVar(&config.RedirectType, "redirect-type", defaultRedirectType, "default redirect status: 301, 302, 307 or 308")
+	flag.IntVar(&config.RedirectCacheTTL, "redirect-cache-ttl", defaultRedirectCacheTTL, "default redirect cache lifetime in seconds, 0 for no-store, -1 for no Cache-Control header")
+	flag.StringVar(&config.SplitVisitorHeader, "split-visitor-header", "", "request header with a stable visitor ID used for A/B split assignment")
+	flag.BoolVar(&config.AlwaysInterstitial, "always-interstitial", false, "show the preview page instead of redirecting for every link")
+	flag.StringVar(&config.InactiveResponse, "inactive-response", defaultInactiveResponse, "response for links outside their activation window: status or page")
+	flag.IntVar(&config.ClickQueueSize, "click-queue-size", defaultClickQueueSize, "number of click events buffered before new ones are dropped, 0 disables click tracking")
+	flag.IntVar(&config.ClickBatchSize, "click-batch-size", defaultClickBatchSize, "maximum number of click events written to storage at once")
+	flag.TextVar(&config.ClickFlushInterval, "click-flush-interval", defaultClickFlushInterval, "how often buffered click events are written to storage")
+	flag.StringVar(&config.ClickIPSalt, "click-ip-salt", "", "secret mixed into visitor IP hashes")
+	flag.StringVar(&config.CountryHeader, "country-header", "", "request header with the visitor country set by a trusted proxy")
+	flag.StringVar(&config.BotPatternsPath, "bot-patterns", "", "path to User-Agent patterns of bots and link unfurlers")
+	flag.IntVar(&config.WebhookMaxAttempts, "webhook-max-attempts", defaultWebhookMaxAttempts, "attempts of a webhook delivery before it is kept as a dead letter")
+	flag.TextVar(&config.WebhookRetryBackoff, "webhook-retry-backoff", defaultWebhookRetryBackoff, "delay before the first retry of a webhook delivery, doubled with every attempt")
+	flag.TextVar(&config.WebhookTimeout, "webhook-timeout", defaultWebhookTimeout, "time limit of a single webhook delivery attempt")
+	flag.TextVar(&config.WebhookExpiryInterval, "webhook-expiry-interval", defaultWebhookExpiryInterval, "how often links are checked for expiry to notify webhooks")
+	flag.StringVar(&config.WebhookPolicyPath, "webhook-policy", "", "path to allow/deny policy of webhook targets")
+
+	flag.Func("auth-keys", "comma-separated JWT signing keys written as kid:secret, the first of which is primary", func(s string) error {
+		config.AuthKeys = splitList(s)
+		return nil
+	})
+	flag.StringVar(&config.AuthKeysPath, "auth-keys-file", "", "path to JWT signing keys, one kid:secret per line")
+	flag.TextVar(&config.AuthTokenTTL, "auth-token-ttl", defaultAuthTokenTTL, "lifetime of the token in the auth cookie")
+	flag.TextVar(&config.AuthRefreshWindow, "auth-refresh-window", defaultAuthRefreshWindow, "how long before its expiry the auth cookie is reissued")
+	flag.TextVar(&config.RevocationSyncInterval, "revocation-sync", defaultRevocationSyncInterval, "interval between loads of revoked sessions from storage, 0 disables")
 
 	flag.Parse()
 
@@ -67,6 +215,117 @@ func GetConfig() *Config {
 			if config.TrustedSubnet == "" {
 				config.TrustedSubnet = jsonConfig.TrustedSubnet
 			}
+			if config.BlocklistPath == "" {
+				config.BlocklistPath = jsonConfig.BlocklistPath
+			}
+			if !config.StripFragment {
+				config.StripFragment = jsonConfig.StripFragment
+			}
+			if !config.SortQuery {
+				config.SortQuery = jsonConfig.SortQuery
+			}
+			if config.PolicyPath == "" {
+				config.PolicyPath = jsonConfig.PolicyPath
+			}
+			if config.ThreatListPath == "" {
+				config.ThreatListPath = jsonConfig.ThreatListPath
+			}
+			if config.ThreatListReloadInterval == defaultThreatListReloadInterval && jsonConfig.ThreatListReloadInterval != 0 {
+				config.ThreatListReloadInterval = jsonConfig.ThreatListReloadInterval
+			}
+			if !config.ThreatCheckRedirects {
+				config.ThreatCheckRedirects = jsonConfig.ThreatCheckRedirects
+			}
+			if len(config.AliasHosts) == 0 {
+				config.AliasHosts = jsonConfig.AliasHosts
+			}
+			if config.SelfReferenceMode == defaultSelfReferenceMode && jsonConfig.SelfReferenceMode != "" {
+				config.SelfReferenceMode = jsonConfig.SelfReferenceMode
+			}
+			if slices.Equal(config.KnownShorteners, defaultKnownShorteners) && len(jsonConfig.KnownShorteners) > 0 {
+				config.KnownShorteners = jsonConfig.KnownShorteners
+			}
+			if config.MaxRedirectChain == defaultMaxRedirectChain && jsonConfig.MaxRedirectChain != 0 {
+				config.MaxRedirectChain = jsonConfig.MaxRedirectChain
+			}
+			if config.ExpirySweepInterval == defaultExpirySweepInterval && jsonConfig.ExpirySweepInterval != 0 {
+				config.ExpirySweepInterval = jsonConfig.ExpirySweepInterval
+			}
+			if config.ExpiredRetention == defaultExpiredRetention && jsonConfig.ExpiredRetention != 0 {
+				config.ExpiredRetention = jsonConfig.ExpiredRetention
+			}
+			if config.LinkUnlockTTL == defaultLinkUnlockTTL && jsonConfig.LinkUnlockTTL != 0 {
+				config.LinkUnlockTTL = jsonConfig.LinkUnlockTTL
+			}
+			if config.PasswordAttempts == defaultPasswordAttempts && jsonConfig.PasswordAttempts != 0 {
+				config.PasswordAttempts = jsonConfig.PasswordAttempts
+			}
+			if config.PasswordAttemptWindow == defaultPasswordAttemptWindow && jsonConfig.PasswordAttemptWindow != 0 {
+				config.PasswordAttemptWindow = jsonConfig.PasswordAttemptWindow
+			}
+			if config.RedirectType == defaultRedirectType && jsonConfig.RedirectType != 0 {
+				config.RedirectType = jsonConfig.RedirectType
+			}
+			if config.RedirectCacheTTL == defaultRedirectCacheTTL {
+				config.RedirectCacheTTL = jsonConfig.RedirectCacheTTL
+			}
+			if config.SplitVisitorHeader == "" {
+				config.SplitVisitorHeader = jsonConfig.SplitVisitorHeader
+			}
+			if !config.AlwaysInterstitial {
+				config.AlwaysInterstitial = jsonConfig.AlwaysInterstitial
+			}
+			if config.InactiveResponse == defaultInactiveResponse && jsonConfig.InactiveResponse != "" {
+				config.InactiveResponse = jsonConfig.InactiveResponse
+			}
+			if config.ClickQueueSize == defaultClickQueueSize && jsonConfig.ClickQueueSize != 0 {
+				config.ClickQueueSize = jsonConfig.ClickQueueSize
+			}
+			if config.ClickBatchSize == defaultClickBatchSize && jsonConfig.ClickBatchSize != 0 {
+				config.ClickBatchSize = jsonConfig.ClickBatchSize
+			}
+			if config.ClickFlushInterval == defaultClickFlushInterval && jsonConfig.ClickFlushInterval != 0 {
+				config.ClickFlushInterval = jsonConfig.ClickFlushInterval
+			}
+			if config.ClickIPSalt == "" {
+				config.ClickIPSalt = jsonConfig.ClickIPSalt
+			}
+			if config.CountryHeader == "" {
+				config.CountryHeader = jsonConfig.CountryHeader
+			}
+			if config.BotPatternsPath == "" {
+				config.BotPatternsPath = jsonConfig.BotPatternsPath
+			}
+			if config.WebhookMaxAttempts == defaultWebhookMaxAttempts && jsonConfig.WebhookMaxAttempts != 0 {
+				config.WebhookMaxAttempts = jsonConfig.WebhookMaxAttempts
+			}
+			if config.WebhookRetryBackoff == defaultWebhookRetryBackoff && jsonConfig.WebhookRetryBackoff != 0 {
+				config.WebhookRetryBackoff = jsonConfig.WebhookRetryBackoff
+			}
+			if config.WebhookTimeout == defaultWebhookTimeout && jsonConfig.WebhookTimeout != 0 {
+				config.WebhookTimeout = jsonConfig.WebhookTimeout
+			}
+			if config.WebhookExpiryInterval == defaultWebhookExpiryInterval && jsonConfig.WebhookExpiryInterval != 0 {
+				config.WebhookExpiryInterval = jsonConfig.WebhookExpiryInterval
+			}
+			if config.WebhookPolicyPath == "" {
+				config.WebhookPolicyPath = jsonConfig.WebhookPolicyPath
+			}
+			if len(config.AuthKeys) == 0 {
+				config.AuthKeys = jsonConfig.AuthKeys
+			}
+			if config.AuthKeysPath == "" {
+				config.AuthKeysPath = jsonConfig.AuthKeysPath
+			}
+			if config.AuthTokenTTL == defaultAuthTokenTTL && jsonConfig.AuthTokenTTL != 0 {
+				config.AuthTokenTTL = jsonConfig.AuthTokenTTL
+			}
+			if config.AuthRefreshWindow == defaultAuthRefreshWindow && jsonConfig.AuthRefreshWindow != 0 {
+				config.AuthRefreshWindow = jsonConfig.AuthRefreshWindow
+			}
+			if config.RevocationSyncInterval == defaultRevocationSyncInterval && jsonConfig.RevocationSyncInterval != 0 {
+				config.RevocationSyncInterval = jsonConfig.RevocationSyncInterval
+			}
 		}
 	}
 	if err != nil {
@@ -88,6 +347,17 @@ func GetConfig() *Config {
 	return config
 }
 
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func loadFromJSON(filePath string) (*Config, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -95,7 +365,8 @@ func loadFromJSON(filePath string) (*Config, error) {
 	}
 	defer file.Close()
 
-	var cfg Config
+	// Zero is a meaningful cache lifetime, so an absent key must keep the default
+	cfg := Config{RedirectCacheTTL: defaultRedirectCacheTTL}
 	decoder := json.NewDecoder(file)
 	if err = decoder.Decode(&cfg); err != nil {
 		return nil, err
@@ -0,0 +1,261 @@
+// Package analytics aggregates the click events of a short link into reports.
+//
+// Unique visitors are not counted from the events but estimated from the daily
+// HyperLogLog sketches of the link, see package hll for their error bounds. As sketches
+// cover whole UTC days, the unique count of a report covers every day overlapping its range.
+// The bounds hold as long as the IP salt stays the same: visitors are added to sketches by
+// their salted fingerprint, so each change of the salt counts every returning visitor again.
+// A salt that is not configured is generated once and kept in storage, see clicks.LoadSalt.
+//
+// Visits tagged as made by bots are left out of reports unless the query includes them.
+// Unique counts never include bots.
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/hll"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+	"github.com/jayjaytrn/URLShortener/internal/useragent"
+)
+
+// Bucket sizes of the time series.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// Limits of a report.
+const (
+	defaultRange = 30 * 24 * time.Hour
+	maxBuckets   = 2000
+	topSize      = 10
+)
+
+// directReferrer names visits without a Referer header in the referrer ranking.
+const directReferrer = "direct"
+
+// Query selects the events of a report and the buckets of its time series.
+type Query struct {
+	From     time.Time // From is the inclusive start of the range
+	To       time.Time // To is the exclusive end of the range
+	Interval string    // Interval is the bucket size: IntervalHour, IntervalDay or IntervalWeek
+
+	IncludeBots bool // IncludeBots counts the visits of bots like those of people
+}
+
+// ParseQuery builds a query from RFC 3339 range bounds and an interval name.
+// Missing values default to the last 30 days in daily buckets, ending at now.
+func ParseQuery(from, to, interval string, now time.Time) (Query, error) {
+	q := Query{To: now.UTC(), Interval: IntervalDay}
+
+	var err error
+	if to != "" {
+		if q.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return q, errors.New("to must be an RFC 3339 timestamp")
+		}
+	}
+	q.From = q.To.Add(-defaultRange)
+	if from != "" {
+		if q.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return q, errors.New("from must be an RFC 3339 timestamp")
+		}
+	}
+	if !q.From.Before(q.To) {
+		return q, errors.New("from must be before to")
+	}
+
+	if interval != "" {
+		q.Interval = strings.ToLower(interval)
+	}
+	switch q.Interval {
+	case IntervalHour, IntervalDay, IntervalWeek:
+	default:
+		return q, errors.New("interval must be hour, day or week")
+	}
+
+	if n := len(q.buckets()); n > maxBuckets {
+		return q, fmt.Errorf("the range holds %d buckets, at most %d are allowed", n, maxBuckets)
+	}
+	return q, nil
+}
+
+// truncate returns the start of the bucket that holds t.
+func (q Query) truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch q.Interval {
+	case IntervalHour:
+		return t.Truncate(time.Hour)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// next returns the start of the bucket after the one starting at t.
+func (q Query) next(t time.Time) time.Time {
+	switch q.Interval {
+	case IntervalHour:
+		return t.Add(time.Hour)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// buckets returns the starts of all buckets overlapping the range, stopping past maxBuckets.
+func (q Query) buckets() []time.Time {
+	var starts []time.Time
+	for t := q.truncate(q.From); t.Before(q.To) && len(starts) <= maxBuckets; t = q.next(t) {
+		starts = append(starts, t)
+	}
+	return starts
+}
+
+// Bucket is one point of the time series.
+type Bucket struct {
+	Start  time.Time `json:"start"`  // Start is the beginning of the bucket
+	Clicks int64     `json:"clicks"` // Clicks is the number of visits in the bucket
+}
+
+// Count is an entry of a ranking.
+type Count struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Report summarises the visits of a link over the range of a query.
+type Report struct {
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Interval     string    `json:"interval"`
+	IncludeBots  bool      `json:"include_bots"`
+	TotalClicks  int64     `json:"total_clicks"`
+	UniqueClicks int64     `json:"unique_clicks"` // UniqueClicks estimates the distinct visitors of the days overlapping the range
+	Series       []Bucket  `json:"series"`
+	TopReferrers []Count   `json:"top_referrers"` // TopReferrers ranks referring hosts; visits without one count as "direct"
+	TopAgents    []Count   `json:"top_user_agents"`
+	TopCountries []Count   `json:"top_countries"`
+	BotClicks    int64     `json:"bot_clicks"` // BotClicks is the number of visits of bots in the range, whether or not they are counted
+}
+
+// Aggregator builds a report from events added one by one.
+type Aggregator struct {
+	query     Query
+	total     int64
+	bots      int64
+	visitors  *hll.Sketch
+	series    map[time.Time]int64
+	referrers map[string]int64
+	agents    map[string]int64
+	countries map[string]int64
+}
+
+// NewAggregator creates an aggregator for the range of q.
+func NewAggregator(q Query) *Aggregator {
+	return &Aggregator{
+		query:     q,
+		visitors:  hll.New(),
+		series:    make(map[time.Time]int64),
+		referrers: make(map[string]int64),
+		agents:    make(map[string]int64),
+		countries: make(map[string]int64),
+	}
+}
+
+// Add counts an event. Events outside the range of the query are ignored, and so are
+// events of bots unless the query includes them.
+func (a *Aggregator) Add(e types.ClickEvent) error {
+	if e.Time.Before(a.query.From) || !e.Time.Before(a.query.To) {
+		return nil
+	}
+	if e.Bot {
+		a.bots++
+		if !a.query.IncludeBots {
+			return nil
+		}
+	}
+
+	a.total++
+	a.series[a.query.truncate(e.Time)]++
+	a.referrers[referrerHost(e.Referrer)]++
+	a.agents[agentFamily(e.UserAgent)]++
+	if e.Country != "" {
+		a.countries[e.Country]++
+	}
+	return nil
+}
+
+// AddVisitors merges a visitor sketch into the unique count of the report.
+func (a *Aggregator) AddVisitors(s *hll.Sketch) {
+	a.visitors.Merge(s)
+}
+
+// Report returns the report of the events added so far.
+func (a *Aggregator) Report() Report {
+	r := Report{
+		From:         a.query.From,
+		To:           a.query.To,
+		Interval:     a.query.Interval,
+		IncludeBots:  a.query.IncludeBots,
+		TotalClicks:  a.total,
+		UniqueClicks: int64(a.visitors.Estimate()),
+		TopReferrers: top(a.referrers),
+		TopAgents:    top(a.agents),
+		TopCountries: top(a.countries),
+		BotClicks:    a.bots,
+	}
+	for _, start := range a.query.buckets() {
+		r.Series = append(r.Series, Bucket{Start: start, Clicks: a.series[start]})
+	}
+	return r
+}
+
+// referrerHost returns the host of a referrer, or directReferrer for visits without one.
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return directReferrer
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return referrer
+	}
+	return strings.ToLower(strings.TrimPrefix(u.Hostname(), "www."))
+}
+
+// agentFamily returns the browser family of a User-Agent header, or "bot" for crawlers.
+func agentFamily(ua string) string {
+	agent := useragent.Parse(ua)
+	if agent.Bot() {
+		return useragent.DeviceBot
+	}
+	return agent.Browser
+}
+
+// top returns the most frequent values of counts, most frequent first.
+func top(counts map[string]int64) []Count {
+	ranking := make([]Count, 0, len(counts))
+	for value, clicks := range counts {
+		ranking = append(ranking, Count{Value: value, Clicks: clicks})
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Clicks != ranking[j].Clicks {
+			return ranking[i].Clicks > ranking[j].Clicks
+		}
+		return ranking[i].Value < ranking[j].Value
+	})
+	if len(ranking) > topSize {
+		ranking = ranking[:topSize]
+	}
+	return ranking
+}
@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/hll"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+func TestParseQuery(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		interval string
+		wantFrom time.Time
+		wantErr  bool
+	}{
+		{name: "Defaults", wantFrom: now.Add(-30 * 24 * time.Hour)},
+		{name: "Explicit range", from: "2024-03-01T00:00:00Z", to: "2024-03-02T00:00:00Z", interval: "hour", wantFrom: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "Reversed range", from: "2024-03-02T00:00:00Z", to: "2024-03-01T00:00:00Z", wantErr: true},
+		{name: "Bad timestamp", from: "yesterday", wantErr: true},
+		{name: "Unknown interval", interval: "month", wantErr: true},
+		{name: "Too many buckets", from: "2000-01-01T00:00:00Z", interval: "hour", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuery(tt.from, tt.to, tt.interval, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !q.From.Equal(tt.wantFrom) {
+				t.Errorf("expected from %v, got %v", tt.wantFrom, q.From)
+			}
+		})
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	// 2024-03-04 is a Monday
+	q, _ := ParseQuery("2024-03-04T00:00:00Z", "2024-03-18T00:00:00Z", "week", time.Now())
+	a := NewAggregator(q)
+
+	chrome := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	events := []types.ClickEvent{
+		{Time: time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC), IPHash: "a", Referrer: "https://www.example.com/page", UserAgent: chrome, Country: "DE"},
+		{Time: time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC), IPHash: "a", UserAgent: chrome, Country: "DE"},
+		{Time: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), IPHash: "b", UserAgent: "Googlebot/2.1", Country: "US"},
+		{Time: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC), IPHash: "c"},
+		{Time: time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC), UserAgent: "Slackbot-LinkExpanding 1.0", Bot: true},
+	}
+	for _, e := range events {
+		a.Add(e)
+	}
+	visitors := hll.New()
+	visitors.Add("a")
+	visitors.Add("b")
+	a.AddVisitors(visitors)
+
+	r := a.Report()
+	if r.TotalClicks != 3 || r.UniqueClicks != 2 || r.BotClicks != 1 {
+		t.Errorf("expected 3 clicks from 2 visitors and 1 bot visit, got %d from %d and %d", r.TotalClicks, r.UniqueClicks, r.BotClicks)
+	}
+	if len(r.Series) != 2 || r.Series[0].Clicks != 2 || r.Series[1].Clicks != 1 {
+		t.Errorf("unexpected weekly series %+v", r.Series)
+	}
+	if len(r.TopReferrers) != 2 || r.TopReferrers[0] != (Count{Value: "direct", Clicks: 2}) || r.TopReferrers[1].Value != "example.com" {
+		t.Errorf("unexpected referrers %+v", r.TopReferrers)
+	}
+	if len(r.TopAgents) != 2 || r.TopAgents[0] != (Count{Value: "chrome", Clicks: 2}) || r.TopAgents[1].Value != "bot" {
+		t.Errorf("unexpected user agents %+v", r.TopAgents)
+	}
+	if len(r.TopCountries) != 2 || r.TopCountries[0] != (Count{Value: "DE", Clicks: 2}) {
+		t.Errorf("unexpected countries %+v", r.TopCountries)
+	}
+}
+
+func TestAggregatorIncludeBots(t *testing.T) {
+	q, _ := ParseQuery("2024-03-04T00:00:00Z", "2024-03-05T00:00:00Z", "day", time.Now())
+	events := []types.ClickEvent{
+		{Time: time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{Time: time.Date(2024, 3, 4, 11, 0, 0, 0, time.UTC), Bot: true},
+	}
+
+	tests := []struct {
+		name        string
+		includeBots bool
+		want        int64
+	}{
+		{name: "Bots excluded", includeBots: false, want: 1},
+		{name: "Bots included", includeBots: true, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q.IncludeBots = tt.includeBots
+			a := NewAggregator(q)
+			for _, e := range events {
+				a.Add(e)
+			}
+			if r := a.Report(); r.TotalClicks != tt.want || r.Series[0].Clicks != tt.want || r.BotClicks != 1 {
+				t.Errorf("expected %d clicks, got %+v", tt.want, r)
+			}
+		})
+	}
+}
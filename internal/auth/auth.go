@@ -1,71 +1,428 @@
 package auth
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// SecretKey is the signing key for JWT authentication.
-const SecretKey = "supersecretkey"
+// linkAudience marks tokens that unlock a password-protected link.
+const linkAudience = "link-unlock"
+
+// issuer is the issuer of the tokens of the service.
+const issuer = "url-shortener"
+
+// Default lifetimes of the tokens that identify users.
+const (
+	DefaultTokenTTL      = 30 * 24 * time.Hour
+	DefaultRefreshWindow = 7 * 24 * time.Hour
+)
+
+// ephemeralKeyID identifies the random key of a manager without configured keys.
+const ephemeralKeyID = "ephemeral"
+
+// storedKeyID identifies the key generated on first use and kept in a SecretStore.
+const storedKeyID = "stored"
+
+// signingKeySecret is the name of the generated signing key in a SecretStore.
+const signingKeySecret = "signing_key"
+
+// errNoKeyID is returned by the key lookup for tokens issued before key IDs were introduced.
+var errNoKeyID = errors.New("token has no key ID")
+
+// Key is a signing key identified by the "kid" header of the tokens it signs.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseKey parses a key written as "kid:secret".
+func ParseKey(s string) (Key, error) {
+	id, secret, ok := strings.Cut(strings.TrimSpace(s), ":")
+	id = strings.TrimSpace(id)
+	if !ok || id == "" || secret == "" {
+		return Key{}, fmt.Errorf("key must be written as kid:secret")
+	}
+	return Key{ID: id, Secret: []byte(secret)}, nil
+}
 
 // Manager handles JWT token creation and parsing.
-type Manager struct{}
+//
+// It holds a reloadable keyset: new tokens are signed with the primary key, the first one,
+// while tokens signed with any key of the set are accepted, so keys can be rotated
+// without invalidating the tokens already issued.
+//
+// It also holds the revoked sessions, so that tokens are checked against them without a storage
+// lookup per request; the set is kept in sync with storage by SyncRevoked.
+type Manager struct {
+	mu   sync.RWMutex
+	path string
+	keys []Key
+
+	ttl           time.Duration
+	refreshWindow time.Duration
+
+	revokedMu sync.RWMutex
+	revoked   map[string]time.Time
+}
+
+// SecretStore keeps the secrets generated by the service across runs.
+type SecretStore interface {
+	LoadOrStoreSecret(ctx context.Context, name, value string) (string, error)
+}
+
+// Options configures an authentication manager.
+type Options struct {
+	Keys          []string      // Keys are signing keys written as "kid:secret", the first of which is primary
+	KeysPath      string        // KeysPath is a file with one key per line, used instead of Keys
+	Secrets       SecretStore   // Secrets keeps a generated key across restarts when no keys are configured; without it the key is random per run
+	TokenTTL      time.Duration // TokenTTL is the lifetime of a user token; DefaultTokenTTL is used when it is zero
+	RefreshWindow time.Duration // RefreshWindow is how long before its expiry a user token is reissued; DefaultRefreshWindow is used when it is zero
+}
 
 // Claims represents the JWT claims, including the user ID.
+//
+// The token ID identifies the session the token belongs to and is kept when the token is refreshed,
+// so revoking it ends every copy of the session.
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID string // Unique identifier for the user
 }
 
-// NewManager creates a new instance of the authentication manager.
-func NewManager() *Manager {
-	return &Manager{}
+// NewManager creates an authentication manager with the given keys, the first of which is primary,
+// and the default token lifetimes. Without keys a random one is generated, so the tokens it signs
+// are not valid after a restart.
+func NewManager(keys ...Key) *Manager {
+	if len(keys) == 0 {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		keys = []Key{{ID: ephemeralKeyID, Secret: secret}}
+	}
+	return &Manager{
+		keys:          keys,
+		ttl:           DefaultTokenTTL,
+		refreshWindow: DefaultRefreshWindow,
+		revoked:       make(map[string]time.Time),
+	}
+}
+
+// Load creates an authentication manager from opts. The keys are written as "kid:secret", in the
+// file one per line, where empty lines and lines starting with '#' are ignored; the first key is
+// primary. Without keys the one generated by an earlier run and kept in opts.Secrets is used,
+// which is generated on first use, so issued tokens survive restarts; without a store a random
+// key is used, as with NewManager.
+func Load(ctx context.Context, opts Options) (*Manager, error) {
+	if opts.TokenTTL < 0 || opts.RefreshWindow < 0 {
+		return nil, fmt.Errorf("token lifetimes must not be negative")
+	}
+	if len(opts.Keys) > 0 && opts.KeysPath != "" {
+		return nil, fmt.Errorf("signing keys and a signing key file are mutually exclusive")
+	}
+
+	var m *Manager
+	switch {
+	case opts.KeysPath != "":
+		m = NewManager()
+		m.path = opts.KeysPath
+		if err := m.Reload(); err != nil {
+			return nil, err
+		}
+	case len(opts.Keys) > 0:
+		keys, err := parseKeys(opts.Keys)
+		if err != nil {
+			return nil, err
+		}
+		m = NewManager(keys...)
+	case opts.Secrets != nil:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		stored, err := opts.Secrets.LoadOrStoreSecret(ctx, signingKeySecret, hex.EncodeToString(secret))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the generated signing key: %w", err)
+		}
+		m = NewManager(Key{ID: storedKeyID, Secret: []byte(stored)})
+	default:
+		m = NewManager()
+	}
+
+	if opts.TokenTTL > 0 {
+		m.ttl = opts.TokenTTL
+	}
+	if opts.RefreshWindow > 0 {
+		m.refreshWindow = opts.RefreshWindow
+	}
+	if m.refreshWindow >= m.ttl {
+		return nil, fmt.Errorf("refresh window must be shorter than the token lifetime")
+	}
+	return m, nil
+}
+
+// TokenTTL returns the lifetime of user tokens. A session revoked now may still hold a token
+// that expires that long from now.
+func (m *Manager) TokenTTL() time.Duration {
+	return m.ttl
 }
 
-// BuildJWTStringWithNewID generates a JWT token string for a given user ID.
+// Reload re-reads the keys from the file the manager was loaded from.
+// On error the previously loaded keys are kept.
+func (m *Manager) Reload() error {
+	if m.path == "" {
+		return nil
+	}
+
+	lines, err := readLines(m.path)
+	if err != nil {
+		return fmt.Errorf("failed to read signing keys: %w", err)
+	}
+	keys, err := parseKeys(lines)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+	return nil
+}
+
+// BuildJWTStringWithNewID generates a JWT token string for a given user ID that starts a new session.
 //
-// It signs the token using the HS256 algorithm and returns the encoded token string.
+// It signs the token using the HS256 algorithm and the primary key and returns the encoded token string.
 func (m *Manager) BuildJWTStringWithNewID(userID string) (string, error) {
-	// создаём новый токен с алгоритмом подписи HS256 и утверждениями — Claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-		UserID: userID,
-	})
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return m.issue(userID, hex.EncodeToString(id), time.Now())
+}
+
+// Refresh reissues the token of claims with a full lifetime, keeping its user and session.
+func (m *Manager) Refresh(claims *Claims) (string, error) {
+	return m.issue(claims.UserID, claims.ID, time.Now())
+}
+
+// NeedsRefresh reports whether the token of claims expires within the refresh window.
+func (m *Manager) NeedsRefresh(claims *Claims, now time.Time) bool {
+	return claims.ExpiresAt.Time.Sub(now) < m.refreshWindow
+}
 
-	// создаём строку токена
-	tokenString, err := token.SignedString([]byte(SecretKey))
+// ParseToken parses a user token, verifying its signature and requiring an unexpired token
+// of this service with a session and a user.
+func (m *Manager) ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := m.parse(tokenString, claims)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("token error: %w", err)
+	}
+
+	if !token.Valid {
+		return nil, fmt.Errorf("token is not valid: %w", err)
+	}
+
+	switch {
+	case claims.ExpiresAt == nil || claims.IssuedAt == nil:
+		return nil, fmt.Errorf("token has no lifetime")
+	case !claims.VerifyIssuer(issuer, true):
+		return nil, fmt.Errorf("token has an unknown issuer")
+	case claims.ID == "":
+		return nil, fmt.Errorf("token has no ID")
+	case claims.UserID == "":
+		return nil, fmt.Errorf("token is valid but userID is missing")
+	case m.Revoked(claims.ID):
+		return nil, fmt.Errorf("token has been revoked")
 	}
 
-	// возвращаем строку токена
-	return tokenString, nil
+	return claims, nil
+}
+
+// Revoke marks the session with the given token ID as revoked until the given moment,
+// after which its tokens have expired anyway.
+func (m *Manager) Revoke(tokenID string, until time.Time) {
+	m.revokedMu.Lock()
+	defer m.revokedMu.Unlock()
+
+	if until.After(m.revoked[tokenID]) {
+		m.revoked[tokenID] = until
+	}
+}
+
+// SyncRevoked adds the revocations loaded from storage, which include those made by other
+// instances, and forgets the ones that have lapsed. Revocations are never undone, so the ones
+// made since the storage was read are kept.
+func (m *Manager) SyncRevoked(revoked map[string]time.Time) {
+	m.revokedMu.Lock()
+	defer m.revokedMu.Unlock()
+
+	now := time.Now()
+	for id, until := range m.revoked {
+		if !until.After(now) {
+			delete(m.revoked, id)
+		}
+	}
+	for id, until := range revoked {
+		if until.After(now) && until.After(m.revoked[id]) {
+			m.revoked[id] = until
+		}
+	}
+}
+
+// Revoked reports whether the session with the given token ID has been revoked.
+func (m *Manager) Revoked(tokenID string) bool {
+	m.revokedMu.RLock()
+	defer m.revokedMu.RUnlock()
+
+	until, ok := m.revoked[tokenID]
+	return ok && until.After(time.Now())
 }
 
 // GetUserIDFromJWTString parses a JWT token string and extracts the user ID.
 //
 // It verifies the token's signature and validates its claims.
 func (m *Manager) GetUserIDFromJWTString(tokenString string) (string, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims,
-		func(t *jwt.Token) (interface{}, error) {
+	claims, err := m.ParseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserID, nil
+}
+
+// BuildLinkToken generates a token that unlocks the password-protected short URL for ttl.
+func (m *Manager) BuildLinkToken(shortURL string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return m.sign(jwt.RegisteredClaims{
+		Subject:   shortURL,
+		Audience:  jwt.ClaimStrings{linkAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	})
+}
+
+// ValidLinkToken reports whether tokenString is an unexpired token issued by BuildLinkToken for shortURL.
+func (m *Manager) ValidLinkToken(tokenString, shortURL string) bool {
+	claims := &jwt.RegisteredClaims{}
+	token, err := m.parse(tokenString, claims)
+	if err != nil || !token.Valid {
+		return false
+	}
+	return claims.Subject == shortURL && claims.VerifyAudience(linkAudience, true)
+}
+
+// issue signs a user token of the session that expires a token lifetime after now.
+func (m *Manager) issue(userID, sessionID string, now time.Time) (string, error) {
+	return m.sign(Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			ID:        sessionID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+		},
+		UserID: userID,
+	})
+}
+
+// sign signs claims with the primary key and names the key in the "kid" header.
+func (m *Manager) sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	primary := m.keys[0]
+	m.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = primary.ID
+	return token.SignedString(primary.Secret)
+}
+
+// parse verifies tokenString with the key named in its "kid" header and decodes it into claims.
+// Tokens without a key ID are checked against every key.
+func (m *Manager) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	m.mu.RLock()
+	keys := m.keys
+	m.mu.RUnlock()
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		id, _ := t.Header["kid"].(string)
+		if id == "" {
+			return nil, errNoKeyID
+		}
+		for _, key := range keys {
+			if key.ID == id {
+				return key.Secret, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key ID: %q", id)
+	})
+	if !errors.Is(err, errNoKeyID) {
+		return token, err
+	}
+
+	for _, key := range keys {
+		token, err = jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
 			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 			}
-			return []byte(SecretKey), nil
+			return key.Secret, nil
 		})
-	if err != nil {
-		return "", fmt.Errorf("token error: %w", err)
+		if err == nil {
+			return token, nil
+		}
 	}
+	return token, err
+}
 
-	if !token.Valid {
-		return "", fmt.Errorf("token is not valid: %w", err)
+// parseKeys parses keys written as "kid:secret", rejecting an empty set and repeated key IDs.
+func parseKeys(lines []string) ([]Key, error) {
+	keys := make([]Key, 0, len(lines))
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		key, err := ParseKey(line)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate key ID: %q", key.ID)
+		}
+		seen[key.ID] = true
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys")
 	}
+	return keys, nil
+}
 
-	if claims.UserID == "" {
-		return "", fmt.Errorf("token is valid but userID is missing")
+// readLines reads the non-empty lines of a file that do not start with '#'.
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	fmt.Println("Token is valid: " + claims.UserID)
-	return claims.UserID, nil
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
 }
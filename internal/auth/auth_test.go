@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestRotation(t *testing.T) {
+	old := Key{ID: "2024", Secret: []byte("old secret")}
+	current := Key{ID: "2025", Secret: []byte("new secret")}
+
+	issued, err := NewManager(old).BuildJWTStringWithNewID("user")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rotated := NewManager(current, old)
+	if userID, err := rotated.GetUserIDFromJWTString(issued); err != nil || userID != "user" {
+		t.Errorf("expected a token of a listed key to stay valid, got %q, %v", userID, err)
+	}
+
+	fresh, _ := rotated.BuildJWTStringWithNewID("user")
+	token, _, _ := jwt.NewParser().ParseUnverified(fresh, &Claims{})
+	if token.Header["kid"] != current.ID {
+		t.Errorf("expected new tokens to be signed with the primary key, got kid %v", token.Header["kid"])
+	}
+
+	if _, err = NewManager(current).GetUserIDFromJWTString(issued); err == nil {
+		t.Errorf("expected a token of a removed key to be rejected")
+	}
+}
+
+func TestVerification(t *testing.T) {
+	key := Key{ID: "main", Secret: []byte("secret")}
+	m := NewManager(key)
+
+	now := time.Now()
+	valid := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			ID:        "session",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		UserID: "user",
+	}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+	endless := valid
+	endless.ExpiresAt = nil
+	foreign := valid
+	foreign.Issuer = "other"
+	anonymous := valid
+	anonymous.ID = ""
+
+	sign := func(kid string, secret []byte, claims Claims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, _ := token.SignedString(secret)
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "Signed", token: sign("main", key.Secret, valid)},
+		{name: "Without key ID", token: sign("", key.Secret, valid)},
+		{name: "Without key ID and unknown secret", token: sign("", []byte("other"), valid), wantErr: true},
+		{name: "Unknown key ID", token: sign("other", key.Secret, valid), wantErr: true},
+		{name: "Wrong secret", token: sign("main", []byte("other"), valid), wantErr: true},
+		{name: "Expired", token: sign("main", key.Secret, expired), wantErr: true},
+		{name: "Without expiry", token: sign("main", key.Secret, endless), wantErr: true},
+		{name: "Other issuer", token: sign("main", key.Secret, foreign), wantErr: true},
+		{name: "Without ID", token: sign("main", key.Secret, anonymous), wantErr: true},
+		{name: "Malformed", token: "not a token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.GetUserIDFromJWTString(tt.token); (err != nil) != tt.wantErr {
+				t.Errorf("unexpected result: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	m, err := Load(context.Background(), Options{TokenTTL: time.Hour, RefreshWindow: 10 * time.Minute})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	token, _ := m.BuildJWTStringWithNewID("user")
+	claims, err := m.ParseToken(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	if m.NeedsRefresh(claims, now) {
+		t.Errorf("expected a fresh token not to need a refresh")
+	}
+	if !m.NeedsRefresh(claims, now.Add(55*time.Minute)) {
+		t.Errorf("expected a token close to its expiry to need a refresh")
+	}
+
+	refreshed, err := m.Refresh(claims)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := m.ParseToken(refreshed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.UserID != "user" || got.ID != claims.ID {
+		t.Errorf("expected the refreshed token to keep the user and the session, got %+v", got)
+	}
+
+	other, _ := m.BuildJWTStringWithNewID("user")
+	if otherClaims, _ := m.ParseToken(other); otherClaims.ID == claims.ID {
+		t.Errorf("expected a new session to get a new ID")
+	}
+	if _, err = Load(context.Background(), Options{TokenTTL: time.Hour, RefreshWindow: time.Hour}); err == nil {
+		t.Errorf("expected a refresh window as long as the token lifetime to be refused")
+	}
+}
+
+func TestRevoked(t *testing.T) {
+	m := NewManager()
+	token, _ := m.BuildJWTStringWithNewID("user")
+	claims, err := m.ParseToken(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	m.Revoke(claims.ID, now.Add(time.Hour))
+	if _, err = m.ParseToken(token); err == nil || !strings.Contains(err.Error(), "revoked") {
+		t.Errorf("expected a token of a revoked session to be rejected, got %v", err)
+	}
+
+	// Storage may not hold the local revocation yet, which must be kept,
+	// while revocations by other instances are added and lapsed ones are dropped
+	other, _ := m.BuildJWTStringWithNewID("other")
+	otherClaims, _ := m.ParseToken(other)
+	m.Revoke("lapsing", now.Add(time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+	m.SyncRevoked(map[string]time.Time{otherClaims.ID: now.Add(time.Hour), "lapsed": now.Add(-time.Minute)})
+
+	if !m.Revoked(claims.ID) || !m.Revoked(otherClaims.ID) {
+		t.Errorf("expected local and synced revocations to be kept")
+	}
+	if m.Revoked("lapsing") || m.Revoked("lapsed") {
+		t.Errorf("expected lapsed revocations to be dropped")
+	}
+	if len(m.revoked) != 2 {
+		t.Errorf("expected lapsed revocations to be forgotten, got %v", m.revoked)
+	}
+}
+
+func TestLinkToken(t *testing.T) {
+	m := NewManager()
+	token, err := m.BuildLinkToken("abcd1234", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !m.ValidLinkToken(token, "abcd1234") {
+		t.Errorf("expected the token to unlock its link")
+	}
+	if m.ValidLinkToken(token, "efgh5678") {
+		t.Errorf("expected the token not to unlock another link")
+	}
+	if NewManager().ValidLinkToken(token, "abcd1234") {
+		t.Errorf("expected random keys of different managers to differ")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("# current key first\n2025:new:secret\n\n2024:old secret\n"), 0600)
+
+	tests := []struct {
+		name    string
+		keys    []string
+		path    string
+		primary string
+		wantErr bool
+	}{
+		{name: "Keys", keys: []string{"a:one", "b:two"}, primary: "a"},
+		{name: "File", path: path, primary: "2025"},
+		{name: "Random", primary: ephemeralKeyID},
+		{name: "Both", keys: []string{"a:one"}, path: path, wantErr: true},
+		{name: "Duplicate ID", keys: []string{"a:one", "a:two"}, wantErr: true},
+		{name: "Missing secret", keys: []string{"a:"}, wantErr: true},
+		{name: "Missing file", path: filepath.Join(t.TempDir(), "missing"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Load(context.Background(), Options{Keys: tt.keys, KeysPath: tt.path})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && m.keys[0].ID != tt.primary {
+				t.Errorf("expected primary key %q, got %q", tt.primary, m.keys[0].ID)
+			}
+		})
+	}
+}
+
+// secrets is a SecretStore kept in memory.
+type secrets map[string]string
+
+func (s secrets) LoadOrStoreSecret(_ context.Context, name, value string) (string, error) {
+	if stored, ok := s[name]; ok {
+		return stored, nil
+	}
+	s[name] = value
+	return value, nil
+}
+
+func TestLoadStoredKey(t *testing.T) {
+	store := secrets{}
+	m, err := Load(context.Background(), Options{Secrets: store})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issued, _ := m.BuildJWTStringWithNewID("user")
+
+	// A restart loads the key generated by the first run, so its tokens stay valid
+	restarted, err := Load(context.Background(), Options{Secrets: store})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if userID, err := restarted.GetUserIDFromJWTString(issued); err != nil || userID != "user" {
+		t.Errorf("expected the token to survive a restart, got %q, %v", userID, err)
+	}
+
+	// Configured keys take precedence over the stored one
+	configured, err := Load(context.Background(), Options{Keys: []string{"a:one"}, Secrets: store})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if configured.keys[0].ID != "a" {
+		t.Errorf("expected the configured key to be primary, got %q", configured.keys[0].ID)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("old:old secret\n"), 0600)
+	m, err := Load(context.Background(), Options{KeysPath: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	issued, _ := m.BuildJWTStringWithNewID("user")
+
+	// Rotation adds the new key in front and keeps the old one until its tokens are gone
+	os.WriteFile(path, []byte("new:new secret\nold:old secret\n"), 0600)
+	if err = m.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = m.GetUserIDFromJWTString(issued); err != nil {
+		t.Errorf("expected the old token to stay valid: %v", err)
+	}
+
+	os.WriteFile(path, []byte("new:\n"), 0600)
+	if err = m.Reload(); err == nil {
+		t.Errorf("expected an invalid key file to be refused")
+	}
+	if m.keys[0].ID != "new" {
+		t.Errorf("expected the previous keys to be kept, got %q", m.keys[0].ID)
+	}
+}
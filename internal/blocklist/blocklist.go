@@ -0,0 +1,149 @@
+// Package blocklist filters short codes that spell offensive or brand-sensitive words.
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultWords is used when no blocklist file is configured.
+var defaultWords = []string{
+	"fuck", "shit", "cunt", "dick", "cock", "piss", "slut", "whore",
+	"bitch", "nazi", "porn", "rape", "fag", "nigg", "kike", "spic",
+}
+
+// minWordLength is the length of the shortest word that is blocked. Shorter words would match
+// most random codes and make code generation fail.
+const minWordLength = 3
+
+// leetReplacer maps common leetspeak substitutions to their letters.
+// The digit 1 is handled separately because it may stand for both "i" and "l".
+var leetReplacer = strings.NewReplacer(
+	"0", "o",
+	"3", "e",
+	"4", "a",
+	"5", "s",
+	"7", "t",
+	"8", "b",
+	"9", "g",
+	"@", "a",
+	"$", "s",
+	"!", "i",
+)
+
+// List is a reloadable set of blocked words.
+type List struct {
+	mu    sync.RWMutex
+	path  string
+	words []string
+}
+
+// New creates a list from the given words.
+func New(words []string) *List {
+	l := &List{}
+	l.set(words)
+	return l
+}
+
+// Load creates a list from a file with one word per line.
+// Empty lines and lines starting with '#' are ignored.
+// If path is empty, the built-in default words are used.
+func Load(path string) (*List, error) {
+	l := &List{path: path}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload re-reads the words from the file the list was loaded from.
+// On error the previously loaded words are kept.
+func (l *List) Reload() error {
+	if l.path == "" {
+		l.set(defaultWords)
+		return nil
+	}
+
+	words, err := readWords(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	l.set(words)
+	return nil
+}
+
+// Contains reports whether s contains a blocked word once leetspeak is normalised.
+// A nil list blocks nothing.
+func (l *List) Contains(s string) bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, form := range Normalize(s) {
+		for _, w := range l.words {
+			if strings.Contains(form, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Normalize lowercases s and undoes leetspeak substitutions.
+// It returns every plausible reading of s.
+func Normalize(s string) []string {
+	base := leetReplacer.Replace(strings.ToLower(s))
+	if !strings.Contains(base, "1") {
+		return []string{base}
+	}
+	return []string{
+		strings.ReplaceAll(base, "1", "i"),
+		strings.ReplaceAll(base, "1", "l"),
+	}
+}
+
+// set replaces the words of the list. Words shorter than minWordLength are ignored.
+func (l *List) set(words []string) {
+	normalized := make([]string, 0, len(words))
+	for _, w := range words {
+		for _, form := range Normalize(strings.TrimSpace(w)) {
+			if len(form) >= minWordLength {
+				normalized = append(normalized, form)
+			}
+		}
+	}
+
+	l.mu.Lock()
+	l.words = normalized
+	l.mu.Unlock()
+}
+
+// readWords reads blocked words from a file.
+func readWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
@@ -0,0 +1,64 @@
+package blocklist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestList_Contains(t *testing.T) {
+	l := New([]string{"badword", "acme", "xy"})
+
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{name: "Clean code", code: "aB3xY9qZ", want: false},
+		{name: "Plain match", code: "xxBadWord", want: true},
+		{name: "Leetspeak match", code: "b4dw0rdQ", want: true},
+		{name: "One as l", code: "zzAcme1x", want: true},
+		{name: "Symbols", code: "@cm3", want: true},
+		{name: "Short word ignored", code: "aaxyaa", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.Contains(tt.code); got != tt.want {
+				t.Errorf("Contains(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestList_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# comment\nfoo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load blocklist: %v", err)
+	}
+	if !l.Contains("xfoox") || l.Contains("xbarx") {
+		t.Fatalf("unexpected initial contents")
+	}
+
+	if err = os.WriteFile(path, []byte("bar\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Reload(); err != nil {
+		t.Fatalf("failed to reload blocklist: %v", err)
+	}
+	if l.Contains("xfoox") || !l.Contains("xbarx") {
+		t.Errorf("reload did not replace words")
+	}
+}
+
+func TestList_Nil(t *testing.T) {
+	var l *List
+	if l.Contains("anything") {
+		t.Errorf("nil list must not block")
+	}
+}
@@ -0,0 +1,157 @@
+// Package bots tells visits made by crawlers and link unfurlers apart from visits made by people.
+package bots
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jayjaytrn/URLShortener/internal/useragent"
+)
+
+// defaultPatterns is used when no pattern file is configured. It lists the User-Agent
+// tokens of the link unfurlers of chat apps and social networks, which fetch every link
+// posted to them, and of common crawlers and monitoring services.
+var defaultPatterns = []string{
+	// Link unfurlers
+	"slackbot", "slack-imgproxy", "twitterbot", "facebookexternalhit", "facebot", "discordbot",
+	"telegrambot", "whatsapp", "linkedinbot", "skypeuripreview", "redditbot", "pinterestbot",
+	"embedly", "iframely", "vkshare", "viber", "mastodon", "bitlybot", "google-pagerenderer",
+	"microsoft preview", "outlook-ios", "snap url preview",
+	// Crawlers and scanners
+	"googlebot", "bingbot", "yandexbot", "baiduspider", "duckduckbot", "applebot", "ahrefsbot",
+	"semrushbot", "mj12bot", "petalbot", "bytespider", "gptbot", "ccbot", "barracuda",
+	"proofpoint", "mimecast", "safelinks",
+	// Monitoring and HTTP libraries
+	"uptimerobot", "pingdom", "statuscake", "okhttp", "axios/", "node-fetch", "java/", "libwww-perl",
+	"httpclient", "scrapy", "phantomjs", "lighthouse",
+}
+
+// prefetchHeaders are request headers, with their values, that browsers send when they
+// fetch a page speculatively rather than because the user opened it.
+var prefetchHeaders = map[string][]string{
+	"Purpose":     {"prefetch", "preview"},
+	"Sec-Purpose": {"prefetch", "prerender"},
+	"X-Purpose":   {"preview"},
+	"X-Moz":       {"prefetch"},
+}
+
+// List is a reloadable set of User-Agent patterns of bots.
+type List struct {
+	mu       sync.RWMutex
+	path     string
+	patterns []string
+}
+
+// New creates a list from the given patterns.
+func New(patterns []string) *List {
+	l := &List{}
+	l.set(patterns)
+	return l
+}
+
+// Load creates a list from a file with one pattern per line. A pattern matches any
+// User-Agent that contains it, ignoring case. Empty lines and lines starting with '#' are ignored.
+// If path is empty, the built-in default patterns are used.
+func Load(path string) (*List, error) {
+	l := &List{path: path}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload re-reads the patterns from the file the list was loaded from.
+// On error the previously loaded patterns are kept.
+func (l *List) Reload() error {
+	if l.path == "" {
+		l.set(defaultPatterns)
+		return nil
+	}
+
+	patterns, err := readPatterns(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read bot patterns: %w", err)
+	}
+	l.set(patterns)
+	return nil
+}
+
+// Bot reports whether req was made by a bot rather than by a person following the link.
+// Besides the patterns of the list, HEAD requests, speculative prefetches and clients the
+// useragent package recognises as automated count as bots. A nil list only applies the heuristics.
+func (l *List) Bot(req *http.Request) bool {
+	if req.Method == http.MethodHead {
+		return true
+	}
+	for header, values := range prefetchHeaders {
+		v := strings.ToLower(req.Header.Get(header))
+		for _, value := range values {
+			if v != "" && strings.Contains(v, value) {
+				return true
+			}
+		}
+	}
+
+	ua := req.UserAgent()
+	return useragent.Parse(ua).Bot() || l.Matches(ua)
+}
+
+// Matches reports whether the User-Agent header value matches a pattern of the list.
+func (l *List) Matches(ua string) bool {
+	if l == nil {
+		return false
+	}
+
+	ua = strings.ToLower(ua)
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, p := range l.patterns {
+		if strings.Contains(ua, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// set replaces the patterns of the list.
+func (l *List) set(patterns []string) {
+	normalized := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			normalized = append(normalized, p)
+		}
+	}
+
+	l.mu.Lock()
+	l.patterns = normalized
+	l.mu.Unlock()
+}
+
+// readPatterns reads bot patterns from a file.
+func readPatterns(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
@@ -0,0 +1,65 @@
+package bots
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+func TestBot(t *testing.T) {
+	list, _ := Load("")
+
+	tests := []struct {
+		name    string
+		method  string
+		ua      string
+		headers map[string]string
+		want    bool
+	}{
+		{name: "Browser", method: http.MethodGet, ua: chrome, want: false},
+		{name: "Slack unfurler", method: http.MethodGet, ua: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", want: true},
+		{name: "iMessage preview", method: http.MethodGet, ua: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_11_1) AppleWebKit/601.2.4 (KHTML, like Gecko) Version/9.0.1 Safari/601.2.4 facebookexternalhit/1.1 Facebot Twitterbot/1.0", want: true},
+		{name: "WhatsApp", method: http.MethodGet, ua: "WhatsApp/2.23.20.0", want: true},
+		{name: "Empty User-Agent", method: http.MethodGet, ua: "", want: true},
+		{name: "HEAD request", method: http.MethodHead, ua: chrome, want: true},
+		{name: "Chrome prefetch", method: http.MethodGet, ua: chrome, headers: map[string]string{"Sec-Purpose": "prefetch;prerender"}, want: true},
+		{name: "Firefox prefetch", method: http.MethodGet, ua: chrome, headers: map[string]string{"X-Moz": "prefetch"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/abcd1234", nil)
+			req.Header.Set("User-Agent", tt.ua)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := list.Bot(req); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bots.txt")
+	os.WriteFile(path, []byte("# in-house monitor\nAcmeProbe\n"), 0644)
+
+	list, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !list.Matches("acmeprobe/2.0") {
+		t.Errorf("expected the pattern from the file to match")
+	}
+	if list.Matches("Slackbot-LinkExpanding 1.0") {
+		t.Errorf("expected the file to replace the default patterns")
+	}
+
+	if _, err = Load(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,336 @@
+// Package clicks records visits of short links in the background.
+package clicks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/bots"
+	"github.com/jayjaytrn/URLShortener/internal/hll"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+	"go.uber.org/zap"
+)
+
+// maxFieldLength bounds the length of the header values stored with an event.
+const maxFieldLength = 1024
+
+// maxCountryLength bounds the length of country codes; longer header values are ignored.
+const maxCountryLength = 8
+
+// flushTimeout bounds the time a single batch write may take.
+const flushTimeout = 10 * time.Second
+
+// saltSecret names the generated secret of visitor IP hashes in storage.
+const saltSecret = "click_ip_salt"
+
+// Writer stores batches of click events and the visitor sketches built from them.
+type Writer interface {
+	PutClicks(ctx context.Context, events []types.ClickEvent) error
+	MergeVisitors(ctx context.Context, sketches []types.VisitorSketch) error
+}
+
+// SecretStore keeps the secrets generated by the service across runs.
+type SecretStore interface {
+	LoadOrStoreSecret(ctx context.Context, name, value string) (string, error)
+}
+
+// LoadSalt returns the secret of visitor IP hashes: salt when it is set, otherwise the one
+// generated by an earlier run and kept in store, which is generated on first use.
+//
+// The salt must not change between runs: visitor fingerprints depend on it, so a new salt
+// counts returning visitors again in unique counts and changes the IP hashes of their events.
+func LoadSalt(ctx context.Context, store SecretStore, salt string) (string, error) {
+	if salt != "" {
+		return salt, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return store.LoadOrStoreSecret(ctx, saltSecret, hex.EncodeToString(key))
+}
+
+// Stats describes the work done by a tracker.
+type Stats struct {
+	Queued  int   `json:"queued"`  // Queued is the number of events waiting to be written
+	Written int64 `json:"written"` // Written is the number of events stored
+	Dropped int64 `json:"dropped"` // Dropped is the number of events discarded because the queue was full
+	Failed  int64 `json:"failed"`  // Failed is the number of events lost to storage errors
+}
+
+// Options configure a tracker.
+type Options struct {
+	QueueSize     int           // QueueSize is the number of buffered events; tracking is off when it is not positive
+	BatchSize     int           // BatchSize is the maximum number of events written at once
+	FlushInterval time.Duration // FlushInterval is how often a partial batch is written
+	IPSalt        string        // IPSalt is the secret of visitor IP hashes; a random one is used when it is empty, see LoadSalt
+	CountryHeader string        // CountryHeader is the request header holding the visitor country, if any
+	Bots          *bots.List    // Bots tags the visits of crawlers and link unfurlers; nil applies the heuristics only
+}
+
+// Tracker queues click events and writes them to storage in batches from a single goroutine,
+// so that recording a visit never waits for storage.
+type Tracker struct {
+	writer Writer
+	opts   Options
+	salt   []byte
+	logger *zap.SugaredLogger
+
+	mu     sync.RWMutex // mu guards closed against sends on the closed queue
+	closed bool
+	queue  chan types.ClickEvent
+	done   chan struct{}
+
+	written atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// New starts a tracker that writes queued events to writer. A nil tracker is returned
+// when the queue size is not positive, which disables tracking.
+func New(writer Writer, opts Options, logger *zap.SugaredLogger) *Tracker {
+	if opts.QueueSize <= 0 {
+		return nil
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+
+	key := []byte(opts.IPSalt)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+
+	t := &Tracker{
+		writer: writer,
+		opts:   opts,
+		salt:   key,
+		logger: logger,
+		queue:  make(chan types.ClickEvent, opts.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Record queues the visit of shortURL described by req. It never blocks: when the queue
+// is full the event is dropped and counted. A nil tracker records nothing.
+func (t *Tracker) Record(shortURL string, req *http.Request, now time.Time) {
+	if t == nil {
+		return
+	}
+	ip := remoteIP(req)
+	t.Track(types.ClickEvent{
+		ShortURL:  shortURL,
+		Time:      now.UTC(),
+		Referrer:  truncate(req.Referer()),
+		UserAgent: truncate(req.UserAgent()),
+		IPHash:    t.HashIP(ip),
+		Language:  truncate(req.Header.Get("Accept-Language")),
+		Country:   t.country(req),
+		Bot:       t.opts.Bots.Bot(req),
+		Visitor:   t.fingerprint(ip, req.UserAgent()),
+	})
+}
+
+// Track queues an event without blocking and reports whether it was accepted.
+func (t *Tracker) Track(event types.ClickEvent) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		t.dropped.Add(1)
+		return false
+	}
+	select {
+	case t.queue <- event:
+		return true
+	default:
+		t.dropped.Add(1)
+		return false
+	}
+}
+
+// HashIP returns the salted hash of a visitor IP, so that visitors can be told apart
+// without storing their addresses.
+func (t *Tracker) HashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, t.salt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fingerprint identifies a visitor for unique counts by the salted hash of its IP and browser,
+// which tells apart visitors sharing an address.
+func (t *Tracker) fingerprint(ip, userAgent string) string {
+	if ip == "" {
+		return ""
+	}
+	return t.HashIP(ip + "\x00" + userAgent)
+}
+
+// Stats returns the counters of the tracker.
+func (t *Tracker) Stats() Stats {
+	if t == nil {
+		return Stats{}
+	}
+	return Stats{
+		Queued:  len(t.queue),
+		Written: t.written.Load(),
+		Dropped: t.dropped.Load(),
+		Failed:  t.failed.Load(),
+	}
+}
+
+// Close stops accepting events and waits until the queued ones are written or ctx is done.
+func (t *Tracker) Close(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects queued events into batches until the queue is closed and drained.
+func (t *Tracker) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]types.ClickEvent, 0, t.opts.BatchSize)
+	for {
+		select {
+		case event, ok := <-t.queue:
+			if !ok {
+				t.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= t.opts.BatchSize {
+				t.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			t.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush writes a batch of events to storage.
+func (t *Tracker) flush(batch []types.ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	if err := t.writer.PutClicks(ctx, batch); err != nil {
+		t.failed.Add(int64(len(batch)))
+		if t.logger != nil {
+			t.logger.Errorw("failed to store click events", "count", len(batch), "error", err)
+		}
+	} else {
+		t.written.Add(int64(len(batch)))
+	}
+
+	// Sketches are kept even when the events are lost, unique counts do not depend on them
+	if sketches := visitorSketches(batch); len(sketches) > 0 {
+		if err := t.writer.MergeVisitors(ctx, sketches); err != nil && t.logger != nil {
+			t.logger.Errorw("failed to store visitor sketches", "count", len(sketches), "error", err)
+		}
+	}
+}
+
+// visitorSketches builds the daily visitor sketches of each link of a batch and of all links together.
+// Visits of bots are left out.
+func visitorSketches(batch []types.ClickEvent) []types.VisitorSketch {
+	type key struct {
+		shortURL string
+		day      time.Time
+	}
+	index := make(map[key]int)
+	var sketches []types.VisitorSketch
+	add := func(shortURL string, day time.Time, visitor string) {
+		i, ok := index[key{shortURL, day}]
+		if !ok {
+			i = len(sketches)
+			index[key{shortURL, day}] = i
+			sketches = append(sketches, types.VisitorSketch{ShortURL: shortURL, Day: day, Sketch: hll.New()})
+		}
+		sketches[i].Sketch.Add(visitor)
+	}
+
+	for _, e := range batch {
+		if e.Visitor == "" || e.Bot {
+			continue
+		}
+		day := types.VisitorDay(e.Time)
+		add(e.ShortURL, day, e.Visitor)
+		add("", day, e.Visitor)
+	}
+	return sketches
+}
+
+// country returns the visitor country reported by the configured header as an upper-case code.
+func (t *Tracker) country(req *http.Request) string {
+	if t.opts.CountryHeader == "" {
+		return ""
+	}
+	country := strings.ToUpper(strings.TrimSpace(req.Header.Get(t.opts.CountryHeader)))
+	if len(country) > maxCountryLength {
+		return ""
+	}
+	return country
+}
+
+// remoteIP returns the address of the client that sent req.
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// truncate shortens header values that are too long to be worth storing.
+func truncate(s string) string {
+	if len(s) > maxFieldLength {
+		// A cut inside a multi-byte character would be rejected by the database
+		return strings.ToValidUTF8(s[:maxFieldLength], "")
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package clicks
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/db/filestorage"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// recorder is a Writer that keeps the batches it receives.
+type recorder struct {
+	mu       sync.Mutex
+	batches  [][]types.ClickEvent
+	sketches []types.VisitorSketch
+	block    chan struct{}
+}
+
+func (r *recorder) PutClicks(_ context.Context, events []types.ClickEvent) error {
+	if r.block != nil {
+		<-r.block
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]types.ClickEvent(nil), events...))
+	return nil
+}
+
+func (r *recorder) MergeVisitors(_ context.Context, sketches []types.VisitorSketch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sketches = append(r.sketches, sketches...)
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, b := range r.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func TestTracker(t *testing.T) {
+	w := &recorder{}
+	tracker := New(w, Options{QueueSize: 100, BatchSize: 3, FlushInterval: time.Hour, IPSalt: "salt", CountryHeader: "CF-IPCountry"}, nil)
+
+	req := httptest.NewRequest("GET", "/abcd1234", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0")
+	req.Header.Set("Referer", "https://example.com/")
+	req.Header.Set("Accept-Language", "en")
+	req.Header.Set("CF-IPCountry", "de")
+	for i := 0; i < 6; i++ {
+		tracker.Record("abcd1234", req, time.Now())
+	}
+	unfurl := httptest.NewRequest("GET", "/abcd1234", nil)
+	unfurl.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+	tracker.Record("abcd1234", unfurl, time.Now())
+
+	if err := tracker.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := w.count(); n != 7 {
+		t.Errorf("expected 7 stored events after drain, got %d", n)
+	}
+	if len(w.batches) != 3 {
+		t.Errorf("expected batches of 3, 3 and 1, got %d batches", len(w.batches))
+	}
+
+	e := w.batches[0][0]
+	if e.Referrer != "https://example.com/" || e.Language != "en" || e.Country != "DE" || e.IPHash == "" || e.IPHash == "192.0.2.1" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if !w.batches[2][0].Bot {
+		t.Errorf("expected the unfurler visit to be tagged as a bot")
+	}
+	if tracker.Track(e) {
+		t.Errorf("expected events to be refused after close")
+	}
+
+	// Each batch of people updates the sketch of the link and the sketch of all links
+	if len(w.sketches) != 4 || w.sketches[0].ShortURL != "abcd1234" || w.sketches[1].ShortURL != "" {
+		t.Fatalf("unexpected visitor sketches %+v", w.sketches)
+	}
+	if n := w.sketches[0].Sketch.Estimate(); n != 1 {
+		t.Errorf("expected a single visitor, got %d", n)
+	}
+}
+
+func TestTrackerOverflow(t *testing.T) {
+	w := &recorder{block: make(chan struct{})}
+	tracker := New(w, Options{QueueSize: 2, BatchSize: 1, FlushInterval: time.Hour}, nil)
+
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		if tracker.Track(types.ClickEvent{ShortURL: "abcd1234"}) {
+			accepted++
+		}
+	}
+	close(w.block)
+	tracker.Close(context.Background())
+
+	stats := tracker.Stats()
+	if stats.Dropped == 0 || int(stats.Dropped)+accepted != 10 {
+		t.Errorf("expected the overflow to be dropped, got %+v with %d accepted", stats, accepted)
+	}
+	if stats.Written != int64(accepted) {
+		t.Errorf("expected %d written events, got %d", accepted, stats.Written)
+	}
+}
+
+func TestHashIP(t *testing.T) {
+	a := New(&recorder{}, Options{QueueSize: 1, IPSalt: "salt"}, nil)
+	b := New(&recorder{}, Options{QueueSize: 1, IPSalt: "other"}, nil)
+	defer a.Close(context.Background())
+	defer b.Close(context.Background())
+
+	if a.HashIP("192.0.2.1") != a.HashIP("192.0.2.1") {
+		t.Errorf("expected a stable hash")
+	}
+	if a.HashIP("192.0.2.1") == b.HashIP("192.0.2.1") {
+		t.Errorf("expected the hash to depend on the salt")
+	}
+}
+
+func TestLoadSalt(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{FileStoragePath: filepath.Join(t.TempDir(), "storage.json")}
+
+	// open loads the salt the way a new run of the service does
+	open := func(salt string) string {
+		storage, err := filestorage.NewManager(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer storage.Close(ctx)
+		loaded, err := LoadSalt(ctx, storage, salt)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return loaded
+	}
+
+	generated := open("")
+	if generated == "" {
+		t.Fatalf("expected a salt to be generated")
+	}
+	if again := open(""); again != generated {
+		t.Errorf("expected the generated salt to be kept across runs, got %q and %q", generated, again)
+	}
+	if configured := open("configured"); configured != "configured" {
+		t.Errorf("expected the configured salt to be used, got %q", configured)
+	}
+}
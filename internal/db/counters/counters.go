@@ -0,0 +1,140 @@
+// Package counters maintains the statistics of stored links as records are added,
+// deleted and removed, so that storages can report them without scanning every record.
+package counters
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// Counters holds the statistics of the links of a storage.
+type Counters struct {
+	mu        sync.Mutex
+	live      int               // live is the number of links that are not deleted
+	deleted   int               // deleted is the number of links deleted by their owners
+	users     map[string]int    // users holds the number of live links of each user
+	created   map[time.Time]int // created holds the number of links created on each UTC day
+	expiries  []time.Time       // expiries holds the sorted expiry times of live links
+	redirects int64             // redirects is the number of recorded visits
+}
+
+// New returns empty counters.
+func New() *Counters {
+	return &Counters{
+		users:   make(map[string]int),
+		created: make(map[time.Time]int),
+	}
+}
+
+// Add counts a new record.
+func (c *Counters) Add(urlData types.URLData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if urlData.CreatedAt != nil {
+		c.created[types.VisitorDay(*urlData.CreatedAt)]++
+	}
+	if urlData.DeletedFlag {
+		c.deleted++
+		return
+	}
+	c.addLive(urlData)
+}
+
+// Delete moves a live record to the deleted ones.
+func (c *Counters) Delete(urlData types.URLData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeLive(urlData)
+	c.deleted++
+}
+
+// Remove uncounts a record removed from the storage. The day it was created on keeps counting it.
+func (c *Counters) Remove(urlData types.URLData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if urlData.DeletedFlag {
+		c.deleted--
+		return
+	}
+	c.removeLive(urlData)
+}
+
+// Redirected counts recorded visits.
+func (c *Counters) Redirected(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.redirects += int64(n)
+}
+
+// Stats returns the statistics at the given moment.
+func (c *Counters) Stats(now time.Time) types.Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Expiry times are sorted, so the expired links are a prefix
+	expired := sort.Search(len(c.expiries), func(i int) bool { return !c.expiries[i].Before(now) })
+	stats := types.Stats{
+		Urls:      c.live,
+		Users:     len(c.users),
+		Active:    c.live - expired,
+		Deleted:   c.deleted,
+		Expired:   expired,
+		Redirects: c.redirects,
+	}
+
+	first := types.VisitorDay(now).AddDate(0, 0, 1-types.StatsDays)
+	for day := first; !day.After(now); day = day.AddDate(0, 0, 1) {
+		if n := c.created[day]; n > 0 {
+			stats.CreatedPerDay = append(stats.CreatedPerDay, types.DayCount{Day: day, Links: n})
+		}
+	}
+
+	for userID, n := range c.users {
+		stats.TopUsers = append(stats.TopUsers, types.UserCount{UserID: userID, Links: n})
+	}
+	sort.Slice(stats.TopUsers, func(i, j int) bool {
+		if stats.TopUsers[i].Links != stats.TopUsers[j].Links {
+			return stats.TopUsers[i].Links > stats.TopUsers[j].Links
+		}
+		return stats.TopUsers[i].UserID < stats.TopUsers[j].UserID
+	})
+	if len(stats.TopUsers) > types.StatsTopUsers {
+		stats.TopUsers = stats.TopUsers[:types.StatsTopUsers]
+	}
+	return stats
+}
+
+// addLive counts a live record. The caller must hold the lock.
+func (c *Counters) addLive(urlData types.URLData) {
+	c.live++
+	if urlData.UserID != "" {
+		c.users[urlData.UserID]++
+	}
+	if urlData.ExpiresAt != nil {
+		i, _ := slices.BinarySearchFunc(c.expiries, *urlData.ExpiresAt, time.Time.Compare)
+		c.expiries = slices.Insert(c.expiries, i, *urlData.ExpiresAt)
+	}
+}
+
+// removeLive uncounts a live record. The caller must hold the lock.
+func (c *Counters) removeLive(urlData types.URLData) {
+	c.live--
+	if urlData.UserID != "" {
+		if c.users[urlData.UserID]--; c.users[urlData.UserID] <= 0 {
+			delete(c.users, urlData.UserID)
+		}
+	}
+	if urlData.ExpiresAt != nil {
+		if i, found := slices.BinarySearchFunc(c.expiries, *urlData.ExpiresAt, time.Time.Compare); found {
+			c.expiries = slices.Delete(c.expiries, i, i+1)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package counters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+func TestCounters(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	yesterday, longAgo := now.AddDate(0, 0, -1), now.AddDate(0, 0, -90)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	c := New()
+	links := []types.URLData{
+		{ShortURL: "a", UserID: "alice", CreatedAt: &now},
+		{ShortURL: "b", UserID: "alice", CreatedAt: &yesterday, ExpiresAt: &past},
+		{ShortURL: "c", UserID: "alice", CreatedAt: &yesterday, ExpiresAt: &future},
+		{ShortURL: "d", UserID: "bob", CreatedAt: &longAgo},
+		{ShortURL: "e", UserID: "bob", DeletedFlag: true},
+	}
+	for _, l := range links {
+		c.Add(l)
+	}
+	c.Delete(links[3])
+	c.Remove(links[1])
+	c.Redirected(3)
+
+	stats := c.Stats(now)
+	want := types.Stats{Urls: 2, Users: 1, Active: 2, Deleted: 2, Expired: 0, Redirects: 3}
+	if stats.Urls != want.Urls || stats.Users != want.Users || stats.Active != want.Active ||
+		stats.Deleted != want.Deleted || stats.Expired != want.Expired || stats.Redirects != want.Redirects {
+		t.Errorf("expected %+v, got %+v", want, stats)
+	}
+
+	// Removed links keep counting on the day they were created, links older than the window do not show
+	days := []types.DayCount{{Day: types.VisitorDay(yesterday), Links: 2}, {Day: types.VisitorDay(now), Links: 1}}
+	if len(stats.CreatedPerDay) != len(days) || stats.CreatedPerDay[0] != days[0] || stats.CreatedPerDay[1] != days[1] {
+		t.Errorf("expected days %+v, got %+v", days, stats.CreatedPerDay)
+	}
+	if len(stats.TopUsers) != 1 || stats.TopUsers[0] != (types.UserCount{UserID: "alice", Links: 2}) {
+		t.Errorf("unexpected top users %+v", stats.TopUsers)
+	}
+
+	if expired := c.Stats(future.Add(time.Minute)).Expired; expired != 1 {
+		t.Errorf("expected 1 expired link later on, got %d", expired)
+	}
+}
@@ -0,0 +1,124 @@
+package filestorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// clicksPath returns the path of the file that holds click events next to the URL storage file.
+func clicksPath(storagePath string) string {
+	return storagePath + ".clicks"
+}
+
+// loadClicks reads the click events stored by previous runs. A missing file means no clicks yet.
+func (fm *Manager) loadClicks() error {
+	file, err := os.Open(clicksPath(fm.cfg.FileStoragePath))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event types.ClickEvent
+		if err = json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return err
+		}
+		fm.clicks = append(fm.clicks, event)
+	}
+	fm.counters.Redirected(len(fm.clicks))
+	return scanner.Err()
+}
+
+// PutClicks appends a batch of click events to the click file.
+// The file is created on the first write, so storages without clicks leave no file behind.
+func (fm *Manager) PutClicks(_ context.Context, events []types.ClickEvent) error {
+	fm.clicksMu.Lock()
+	defer fm.clicksMu.Unlock()
+
+	if fm.clicksFile == nil {
+		file, err := os.OpenFile(clicksPath(fm.cfg.FileStoragePath), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		fm.clicksFile = file
+	}
+
+	// The batch is written at once so that a failure cannot leave half of it in memory only
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+	if _, err := fm.clicksFile.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	fm.clicks = append(fm.clicks, events...)
+	fm.counters.Redirected(len(events))
+	return nil
+}
+
+// ForEachClick calls fn for every click event of the short URL recorded in [from, to).
+func (fm *Manager) ForEachClick(_ context.Context, shortURL string, from, to time.Time, fn func(types.ClickEvent) error) error {
+	fm.clicksMu.RLock()
+	// Events are only appended, so the prefix seen now stays valid without the lock
+	events := fm.clicks
+	fm.clicksMu.RUnlock()
+
+	for _, event := range events {
+		if event.ShortURL != shortURL || event.Time.Before(from) || !event.Time.Before(to) {
+			continue
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachClickIn calls fn for every click event of the given short URLs recorded in [from, to).
+// Nil short URLs select the events of every link.
+func (fm *Manager) ForEachClickIn(_ context.Context, shortURLs []string, from, to time.Time, fn func(types.ClickEvent) error) error {
+	var selected map[string]bool
+	if shortURLs != nil {
+		selected = make(map[string]bool, len(shortURLs))
+		for _, shortURL := range shortURLs {
+			selected[shortURL] = true
+		}
+	}
+
+	fm.clicksMu.RLock()
+	events := fm.clicks
+	fm.clicksMu.RUnlock()
+
+	// Events are written in batches, so they are only roughly in time order
+	var matched []types.ClickEvent
+	for _, event := range events {
+		if (selected == nil || selected[event.ShortURL]) && !event.Time.Before(from) && event.Time.Before(to) {
+			matched = append(matched, event)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Time.Before(matched[j].Time) })
+
+	for _, event := range matched {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,17 +6,60 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/db/counters"
+	"github.com/jayjaytrn/URLShortener/internal/hll"
 	"github.com/jayjaytrn/URLShortener/internal/types"
 )
 
+// maxURLLineSize bounds the length of a line of the storage file. Records keep their whole
+// revision history, so their lines can be much longer than the URLs they hold.
+const maxURLLineSize = 64 * 1024 * 1024
+
+// compactMinLines is the number of lines from which the storage file is compacted once at least
+// half of them are superseded. Records are appended again on every click of a link with a click
+// limit and every visitor of a split link, so without compaction the file would grow without bound.
+const compactMinLines = 1024
+
 // Manager handles file-based URL storage operations.
 type Manager struct {
+	mu          sync.RWMutex
 	file        *os.File
 	FileStorage *[]types.URLData
 	cfg         *config.Config
+	counters    *counters.Counters
+	lines       int // lines is the number of lines of the storage file, superseded ones included
+
+	// Click events are kept in their own file with their own lock
+	clicksMu   sync.RWMutex
+	clicksFile *os.File
+	clicks     []types.ClickEvent
+
+	// Visitor sketches share the lock of click events
+	visitorsFile *os.File
+	visitors     map[visitorKey]*hll.Sketch
+
+	// Webhooks and their deliveries are kept in their own files with their own lock
+	hooksMu        sync.RWMutex
+	webhooksFile   *os.File
+	deliveriesFile *os.File
+	webhooks       []types.Webhook
+	deliveries     []types.Delivery
+	deliveryIndex  map[string]int
+
+	// Revoked sessions are kept by token ID until their tokens have expired
+	revokedMu   sync.RWMutex
+	revokedFile *os.File
+	revoked     map[string]time.Time
+
+	// Secrets generated by the service are read from their file when they are needed
+	secretsMu sync.Mutex
 }
 
 // NewManager creates a new instance of the file storage manager.
@@ -34,6 +77,7 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 		file:        file,
 		FileStorage: &storage,
 		cfg:         cfg,
+		counters:    counters.New(),
 	}
 
 	err = fm.LoadURLStorageFromFile()
@@ -41,31 +85,171 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 		return nil, fmt.Errorf("failed to load URL storage from file: %w", err)
 	}
 
+	if err = fm.loadClicks(); err != nil {
+		return nil, fmt.Errorf("failed to load click events from file: %w", err)
+	}
+
+	if err = fm.loadVisitors(); err != nil {
+		return nil, fmt.Errorf("failed to load visitor sketches from file: %w", err)
+	}
+
+	if err = fm.loadWebhooks(); err != nil {
+		return nil, fmt.Errorf("failed to load webhooks from file: %w", err)
+	}
+
+	if err = fm.loadRevocations(); err != nil {
+		return nil, fmt.Errorf("failed to load revoked tokens from file: %w", err)
+	}
+
 	return fm, nil
 }
 
 // GetOriginal retrieves the original URL corresponding to a given short URL.
 func (fm *Manager) GetOriginal(shortURL string) (string, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
 	for _, urlData := range *fm.FileStorage {
 		if urlData.ShortURL == shortURL {
+			if urlData.Expired(time.Now()) {
+				return "", fmt.Errorf("URL has expired")
+			}
 			return urlData.OriginalURL, nil
 		}
 	}
 	return "", fmt.Errorf("URL not found")
 }
 
+// Get retrieves the full record corresponding to a given short URL.
+func (fm *Manager) Get(shortURL string) (types.URLData, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	i := fm.indexOf(shortURL)
+	if i < 0 {
+		return types.URLData{}, fmt.Errorf("URL not found")
+	}
+	urlData := (*fm.FileStorage)[i]
+	if err := urlData.Available(time.Now()); err != nil {
+		return types.URLData{}, err
+	}
+	return urlData, nil
+}
+
+// UseClick consumes one redirect of the URL, appends the updated record to the storage file
+// and returns it.
+func (fm *Manager) UseClick(_ context.Context, shortURL string) (types.URLData, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	i := fm.indexOf(shortURL)
+	if i < 0 {
+		return types.URLData{}, fmt.Errorf("URL not found")
+	}
+	urlData := (*fm.FileStorage)[i]
+	if err := urlData.Available(time.Now()); err != nil {
+		return types.URLData{}, err
+	}
+	if urlData.ClicksLeft == nil {
+		return urlData, nil
+	}
+
+	// The click is only used up in memory once it is written
+	clicks := *urlData.ClicksLeft - 1
+	urlData.ClicksLeft = &clicks
+	if err := fm.WriteURL(urlData); err != nil {
+		return types.URLData{}, err
+	}
+	(*fm.FileStorage)[i] = urlData
+	return urlData, nil
+}
+
+// UpdateURL changes the URL record owned by userID and appends the updated record to the storage file.
+func (fm *Manager) UpdateURL(_ context.Context, shortURL, userID string, update func(*types.URLData) error) (types.URLData, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	i := fm.indexOf(shortURL)
+	if i < 0 || (*fm.FileStorage)[i].UserID != userID || (*fm.FileStorage)[i].DeletedFlag {
+		return types.URLData{}, fmt.Errorf("URL not found")
+	}
+
+	urlData := (*fm.FileStorage)[i]
+	if err := update(&urlData); err != nil {
+		return types.URLData{}, err
+	}
+	// Clip so that records handed out earlier keep their history
+	revisions := types.Revisions((*fm.FileStorage)[i], urlData, len(urlData.History), userID, time.Now())
+	urlData.History = append(slices.Clip(urlData.History), revisions...)
+	if err := fm.WriteURL(urlData); err != nil {
+		return types.URLData{}, err
+	}
+	(*fm.FileStorage)[i] = urlData
+	return urlData, nil
+}
+
+// GetUserURL returns the URL record owned by userID.
+func (fm *Manager) GetUserURL(_ context.Context, shortURL, userID string) (types.URLData, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	i := fm.indexOf(shortURL)
+	if i < 0 || (*fm.FileStorage)[i].UserID != userID || (*fm.FileStorage)[i].DeletedFlag {
+		return types.URLData{}, fmt.Errorf("URL not found")
+	}
+	return (*fm.FileStorage)[i], nil
+}
+
+// URLHistory returns the revisions of the URL record owned by userID.
+func (fm *Manager) URLHistory(_ context.Context, shortURL, userID string) ([]types.Revision, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
+	i := fm.indexOf(shortURL)
+	if i < 0 || (*fm.FileStorage)[i].UserID != userID || (*fm.FileStorage)[i].DeletedFlag {
+		return nil, fmt.Errorf("URL not found")
+	}
+
+	urlData := (*fm.FileStorage)[i]
+	if len(urlData.History) == 0 {
+		return []types.Revision{types.FirstRevision(urlData, time.Now())}, nil
+	}
+	return slices.Clone(urlData.History), nil
+}
+
+// CountAssignment increments the number of visitors assigned to a split variant of the URL
+// and appends the updated record to the storage file.
+func (fm *Manager) CountAssignment(_ context.Context, shortURL string, variant int) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	i := fm.indexOf(shortURL)
+	if i < 0 {
+		return fmt.Errorf("URL not found")
+	}
+	urlData := &(*fm.FileStorage)[i]
+	if variant >= len(urlData.Assignments) {
+		return nil
+	}
+
+	// Copy so that records handed out earlier keep their counts
+	assignments := append([]int64(nil), urlData.Assignments...)
+	assignments[variant]++
+	urlData.Assignments = assignments
+	return fm.WriteURL(*urlData)
+}
+
 // Put stores a new URL mapping in the file storage.
 func (fm *Manager) Put(urlData types.URLData) error {
-	data := types.URLData{
-		ShortURL:    urlData.ShortURL,
-		OriginalURL: urlData.OriginalURL,
-		UserID:      urlData.UserID,
-	}
-	*fm.FileStorage = append(*fm.FileStorage, data)
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	*fm.FileStorage = append(*fm.FileStorage, urlData)
 	err := fm.WriteURL(urlData)
 	if err != nil {
 		return err
 	}
+	fm.counters.Add(urlData)
 	return nil
 }
 
@@ -82,6 +266,9 @@ func (fm *Manager) PutBatch(_ context.Context, batchData []types.URLData) error
 
 // Exists checks if a given short URL exists in the storage.
 func (fm *Manager) Exists(shortURL string) (bool, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
 	for _, urlData := range *fm.FileStorage {
 		if urlData.ShortURL == shortURL {
 			return true, nil
@@ -97,13 +284,24 @@ func (fm *Manager) GenerateNewUserID() string {
 
 // GetURLsByUserID retrieves all stored URLs associated with a given user ID.
 func (fm *Manager) GetURLsByUserID(userID string) ([]types.URLData, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
 	var userURLs []types.URLData
 
 	for _, urlData := range *fm.FileStorage {
 		if urlData.UserID == userID {
 			userURLs = append(userURLs, types.URLData{
-				ShortURL:    fm.cfg.BaseURL + "/" + urlData.ShortURL,
-				OriginalURL: urlData.OriginalURL,
+				ShortURL:     fm.cfg.BaseURL + "/" + urlData.ShortURL,
+				OriginalURL:  urlData.OriginalURL,
+				UserID:       urlData.UserID,
+				CreatedAt:    urlData.CreatedAt,
+				DeletedFlag:  urlData.DeletedFlag,
+				ExpiresAt:    urlData.ExpiresAt,
+				ClicksLeft:   urlData.ClicksLeft,
+				PasswordHash: urlData.PasswordHash,
+				Options:      urlData.Options,
+				Assignments:  urlData.Assignments,
 			})
 		}
 	}
@@ -123,12 +321,60 @@ func (fm *Manager) Ping(ctx context.Context) error {
 
 // Close closes the storage file.
 func (fm *Manager) Close(_ context.Context) error {
+	fm.clicksMu.Lock()
+	if fm.clicksFile != nil {
+		fm.clicksFile.Close()
+	}
+	if fm.visitorsFile != nil {
+		fm.visitorsFile.Close()
+	}
+	fm.clicksMu.Unlock()
+
+	fm.hooksMu.Lock()
+	if fm.webhooksFile != nil {
+		fm.webhooksFile.Close()
+	}
+	if fm.deliveriesFile != nil {
+		fm.deliveriesFile.Close()
+	}
+	fm.hooksMu.Unlock()
+
+	fm.revokedMu.Lock()
+	if fm.revokedFile != nil {
+		fm.revokedFile.Close()
+	}
+	fm.revokedMu.Unlock()
+
 	return fm.file.Close()
 }
 
+// urlLine is a line of the storage file. Unlike URL records sent to clients, it holds the password hash.
+type urlLine struct {
+	types.URLData
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// newURLLine returns the line of the storage file that holds urlData.
+func newURLLine(urlData types.URLData) urlLine {
+	return urlLine{URLData: urlData, PasswordHash: urlData.PasswordHash}
+}
+
 // WriteURL appends a new URL entry to the storage file.
+//
+// When most lines of the file are superseded, the file is rewritten with the current records
+// instead, urlData replacing the record with its short URL. The caller must hold the lock.
 func (fm *Manager) WriteURL(urlData types.URLData) error {
-	data, err := json.Marshal(&urlData)
+	if fm.lines >= compactMinLines && fm.lines >= 2*len(*fm.FileStorage) {
+		records := slices.Clone(*fm.FileStorage)
+		if i := fm.indexOf(urlData.ShortURL); i >= 0 {
+			records[i] = urlData
+		} else {
+			records = append(records, urlData)
+		}
+		return fm.rewrite(records)
+	}
+
+	data, err := json.Marshal(newURLLine(urlData))
 	if err != nil {
 		return err
 	}
@@ -138,12 +384,16 @@ func (fm *Manager) WriteURL(urlData types.URLData) error {
 	if err != nil {
 		return err
 	}
+	fm.lines++
 
 	return err
 }
 
 // GetNewUserID generates a new unique user ID, ensuring it does not exist in storage.
 func (fm *Manager) GetNewUserID() (string, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+
 	for {
 		newUUID := uuid.New().String()
 
@@ -161,11 +411,28 @@ func (fm *Manager) GetNewUserID() (string, error) {
 	}
 }
 
-// BatchDelete is a placeholder for batch deletion, not implemented for file storage.
-func (fm *Manager) BatchDelete(_ chan string, _ string) {
+// BatchDelete marks the URLs received from urlChannel as deleted when they belong to userID
+// and appends the updated records to the storage file.
+func (fm *Manager) BatchDelete(urlChannel chan string, userID string) {
+	for shortURL := range urlChannel {
+		fm.mu.Lock()
+		if i := fm.indexOf(shortURL); i >= 0 && (*fm.FileStorage)[i].UserID == userID && !(*fm.FileStorage)[i].DeletedFlag {
+			urlData := (*fm.FileStorage)[i]
+			urlData.DeletedFlag = true
+			// A record that could not be written stays as it is on disk
+			if err := fm.WriteURL(urlData); err == nil {
+				fm.counters.Delete((*fm.FileStorage)[i])
+				(*fm.FileStorage)[i] = urlData
+			}
+		}
+		fm.mu.Unlock()
+	}
 }
 
 // LoadURLStorageFromFile reads stored URLs from the file and loads them into memory.
+//
+// Updated records are appended to the file, so a later line replaces an earlier one with the same short URL.
+// A file with superseded lines is compacted once it is loaded.
 func (fm *Manager) LoadURLStorageFromFile() error {
 	fi, err := fm.file.Stat()
 	if err != nil {
@@ -183,13 +450,25 @@ func (fm *Manager) LoadURLStorageFromFile() error {
 		return err
 	}
 
+	// Positions of the records by short URL, so that superseded lines are found without a scan
+	index := make(map[string]int)
+
 	var scanner = bufio.NewScanner(fm.file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxURLLineSize)
 	for scanner.Scan() {
-		var data types.URLData
+		var record urlLine
 		line := scanner.Bytes()
-		if err = json.Unmarshal(line, &data); err != nil {
+		if err = json.Unmarshal(line, &record); err != nil {
 			return err
 		}
+		fm.lines++
+		data := record.URLData
+		data.PasswordHash = record.PasswordHash
+		if i, ok := index[data.ShortURL]; ok {
+			(*fm.FileStorage)[i] = data
+			continue
+		}
+		index[data.ShortURL] = len(*fm.FileStorage)
 		*fm.FileStorage = append(*fm.FileStorage, data)
 	}
 
@@ -197,22 +476,136 @@ func (fm *Manager) LoadURLStorageFromFile() error {
 		return err
 	}
 
+	if fm.lines > len(*fm.FileStorage) {
+		if err = fm.rewrite(*fm.FileStorage); err != nil {
+			return err
+		}
+	}
+
+	for _, urlData := range *fm.FileStorage {
+		fm.counters.Add(urlData)
+	}
+	return nil
+}
+
+// ForEachURL calls fn for every stored URL record.
+func (fm *Manager) ForEachURL(_ context.Context, fn func(types.URLData) error) error {
+	fm.mu.RLock()
+	snapshot := make([]types.URLData, len(*fm.FileStorage))
+	copy(snapshot, *fm.FileStorage)
+	fm.mu.RUnlock()
+
+	for _, urlData := range snapshot {
+		if err := fn(urlData); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// GetStats возвращает количество сокращенных URL и количество пользователей.
-func (fm *Manager) GetStats() (types.Stats, error) {
-	urlCount := len(*fm.FileStorage)
-	userSet := make(map[string]struct{})
+// SetFlagged marks the given short URLs as pointing to a malicious destination
+// and appends the updated records to the storage file.
+func (fm *Manager) SetFlagged(_ context.Context, shortURLs []string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	for _, shortURL := range shortURLs {
+		i := fm.indexOf(shortURL)
+		if i < 0 || (*fm.FileStorage)[i].Flagged {
+			continue
+		}
+		(*fm.FileStorage)[i].Flagged = true
+		if err := fm.WriteURL((*fm.FileStorage)[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteExpired removes URLs that expired before the given moment and compacts the storage file.
+func (fm *Manager) DeleteExpired(_ context.Context, before time.Time) (int, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	kept := make([]types.URLData, 0, len(*fm.FileStorage))
+	for _, urlData := range *fm.FileStorage {
+		if urlData.ExpiresAt == nil || !urlData.ExpiresAt.Before(before) {
+			kept = append(kept, urlData)
+		}
+	}
+	removed := len(*fm.FileStorage) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
 
+	if err := fm.rewrite(kept); err != nil {
+		return 0, err
+	}
 	for _, urlData := range *fm.FileStorage {
-		if urlData.UserID != "" {
-			userSet[urlData.UserID] = struct{}{}
+		if urlData.ExpiresAt != nil && urlData.ExpiresAt.Before(before) {
+			fm.counters.Remove(urlData)
+		}
+	}
+	*fm.FileStorage = kept
+
+	return removed, nil
+}
+
+// rewrite replaces the storage file with the given records.
+// The new file is written next to the old one and renamed over it, so a crash never leaves a partial file.
+// The caller must hold the lock.
+func (fm *Manager) rewrite(records []types.URLData) error {
+	path := fm.cfg.FileStoragePath
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary storage file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, urlData := range records {
+		if err = encoder.Encode(newURLLine(urlData)); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write storage file: %w", err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write storage file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write storage file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace storage file: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen storage file: %w", err)
+	}
+	fm.file.Close()
+	fm.file = file
+	fm.lines = len(records)
+
+	return nil
+}
+
+// indexOf returns the position of the record with the given short URL, or -1 if there is none.
+// The caller must hold the lock.
+func (fm *Manager) indexOf(shortURL string) int {
+	for i, urlData := range *fm.FileStorage {
+		if urlData.ShortURL == shortURL {
+			return i
 		}
 	}
+	return -1
+}
 
-	return types.Stats{
-		Urls:  urlCount,
-		Users: len(userSet),
-	}, nil
+// GetStats возвращает количество сокращенных URL и количество пользователей.
+// The statistics are maintained as records change.
+func (fm *Manager) GetStats() (types.Stats, error) {
+	return fm.counters.Stats(time.Now()), nil
 }
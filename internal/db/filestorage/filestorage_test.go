@@ -0,0 +1,111 @@
+package filestorage
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+func TestCompaction(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{FileStoragePath: filepath.Join(t.TempDir(), "storage.json")}
+
+	// countLines returns the number of lines of the storage file
+	countLines := func() int {
+		file, err := os.Open(cfg.FileStoragePath)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxURLLineSize)
+		n := 0
+		for scanner.Scan() {
+			n++
+		}
+		if err = scanner.Err(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return n
+	}
+
+	fm, err := NewManager(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clicks := int64(3 * compactMinLines)
+	long := "https://example.com/?q=" + strings.Repeat("a", 128*1024)
+	if err = fm.Put(types.URLData{ShortURL: "limited", OriginalURL: "https://example.com", ClicksLeft: &clicks}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = fm.Put(types.URLData{ShortURL: "long", OriginalURL: long}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Every click appends the record again, until most lines are superseded
+	for i := 0; i < 2*compactMinLines; i++ {
+		if _, err = fm.UseClick(ctx, "limited"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := countLines(); n > compactMinLines+1 {
+		t.Errorf("expected the file to be compacted while in use, got %v lines", n)
+	}
+
+	if _, err = fm.UseClick(ctx, "limited"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fm.Close(ctx)
+
+	fm, err = NewManager(cfg)
+	if err != nil {
+		t.Fatalf("expected long lines to load, got %v", err)
+	}
+	defer fm.Close(ctx)
+	if n := countLines(); n != 2 {
+		t.Errorf("expected the file to be compacted on load to 2 lines, got %v", n)
+	}
+
+	urlData, err := fm.Get("limited")
+	if err != nil || *urlData.ClicksLeft != clicks-2*compactMinLines-1 {
+		t.Errorf("expected the last click count to be kept, got %+v, %v", urlData.ClicksLeft, err)
+	}
+	if original, err := fm.GetOriginal("long"); err != nil || original != long {
+		t.Errorf("expected the long URL to be kept, got %v", err)
+	}
+}
+
+func TestUseClickWriteError(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{FileStoragePath: filepath.Join(t.TempDir(), "storage.json")}
+
+	fm, err := NewManager(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer fm.Close(ctx)
+	clicks := int64(3)
+	if err = fm.Put(types.URLData{ShortURL: "limited", OriginalURL: "https://example.com", ClicksLeft: &clicks}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Writes fail once the storage file can only be read
+	writable := fm.file
+	defer writable.Close()
+	if fm.file, err = os.Open(cfg.FileStoragePath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = fm.UseClick(ctx, "limited"); err == nil {
+		t.Fatalf("expected the write to fail")
+	}
+	urlData, err := fm.Get("limited")
+	if err != nil || *urlData.ClicksLeft != clicks {
+		t.Errorf("expected the click to be kept when it cannot be written, got %+v, %v", urlData.ClicksLeft, err)
+	}
+}
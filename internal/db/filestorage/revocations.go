@@ -0,0 +1,65 @@
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// revocationLine is a line of the file of revoked tokens.
+type revocationLine struct {
+	TokenID string    `json:"jti"`
+	Until   time.Time `json:"until"`
+}
+
+// revocationsPath returns the path of the file that holds revoked tokens next to the URL storage file.
+func revocationsPath(storagePath string) string {
+	return storagePath + ".revoked"
+}
+
+// loadRevocations reads the revocations recorded by previous runs, skipping those that have lapsed.
+func (fm *Manager) loadRevocations() error {
+	fm.revoked = make(map[string]time.Time)
+
+	now := time.Now()
+	return readLines(revocationsPath(fm.cfg.FileStoragePath), func(line []byte) error {
+		var r revocationLine
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		if r.Until.After(now) && r.Until.After(fm.revoked[r.TokenID]) {
+			fm.revoked[r.TokenID] = r.Until
+		}
+		return nil
+	})
+}
+
+// RevokeToken appends the revocation of the session with the given token ID to the file of revoked tokens.
+func (fm *Manager) RevokeToken(_ context.Context, tokenID string, until time.Time) error {
+	fm.revokedMu.Lock()
+	defer fm.revokedMu.Unlock()
+
+	line := revocationLine{TokenID: tokenID, Until: until}
+	if err := appendLine(&fm.revokedFile, revocationsPath(fm.cfg.FileStoragePath), line); err != nil {
+		return err
+	}
+	if until.After(fm.revoked[tokenID]) {
+		fm.revoked[tokenID] = until
+	}
+	return nil
+}
+
+// RevokedTokens returns the revocations that have not lapsed.
+func (fm *Manager) RevokedTokens(_ context.Context) (map[string]time.Time, error) {
+	fm.revokedMu.RLock()
+	defer fm.revokedMu.RUnlock()
+
+	now := time.Now()
+	result := make(map[string]time.Time, len(fm.revoked))
+	for id, until := range fm.revoked {
+		if until.After(now) {
+			result[id] = until
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// secretLine is a line of the file of secrets.
+type secretLine struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// secretsPath returns the path of the file that holds secrets next to the URL storage file.
+func secretsPath(storagePath string) string {
+	return storagePath + ".secrets"
+}
+
+// LoadOrStoreSecret returns the secret stored under name, appending value under it to the file
+// of secrets first if there is none. The file is only readable by its owner.
+func (fm *Manager) LoadOrStoreSecret(_ context.Context, name, value string) (string, error) {
+	fm.secretsMu.Lock()
+	defer fm.secretsMu.Unlock()
+
+	path := secretsPath(fm.cfg.FileStoragePath)
+	var stored *secretLine
+	err := readLines(path, func(line []byte) error {
+		var s secretLine
+		if err := json.Unmarshal(line, &s); err != nil {
+			return err
+		}
+		if s.Name == name && stored == nil {
+			stored = &s
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if stored != nil {
+		return stored.Value, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if err = appendLine(&file, path, secretLine{Name: name, Value: value}); err != nil {
+		return "", err
+	}
+	return value, file.Sync()
+}
@@ -0,0 +1,112 @@
+package filestorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/hll"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// visitorKey identifies the visitor sketch of a link and a day.
+type visitorKey struct {
+	shortURL string
+	day      time.Time
+}
+
+// visitorsPath returns the path of the file that holds visitor sketches next to the URL storage file.
+func visitorsPath(storagePath string) string {
+	return storagePath + ".visitors"
+}
+
+// loadVisitors reads the visitor sketches stored by previous runs.
+// Every merge appends the whole sketch, so the last line of a link and day wins.
+func (fm *Manager) loadVisitors() error {
+	fm.visitors = make(map[visitorKey]*hll.Sketch)
+
+	file, err := os.Open(visitorsPath(fm.cfg.FileStoragePath))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var s types.VisitorSketch
+		if err = json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return err
+		}
+		fm.visitors[visitorKey{shortURL: s.ShortURL, day: s.Day}] = s.Sketch
+	}
+	return scanner.Err()
+}
+
+// MergeVisitors merges daily visitor sketches into the stored ones and appends the results
+// to the visitor file.
+func (fm *Manager) MergeVisitors(_ context.Context, sketches []types.VisitorSketch) error {
+	fm.clicksMu.Lock()
+	defer fm.clicksMu.Unlock()
+
+	if fm.visitorsFile == nil {
+		file, err := os.OpenFile(visitorsPath(fm.cfg.FileStoragePath), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		fm.visitorsFile = file
+	}
+
+	// Stored sketches are replaced only after the file write succeeded
+	merged := make(map[visitorKey]*hll.Sketch, len(sketches))
+	for _, s := range sketches {
+		key := visitorKey{shortURL: s.ShortURL, day: types.VisitorDay(s.Day)}
+		m, ok := merged[key]
+		if !ok {
+			m = hll.New()
+			if stored, ok := fm.visitors[key]; ok {
+				m.Merge(stored)
+			}
+			merged[key] = m
+		}
+		m.Merge(s.Sketch)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for key, s := range merged {
+		if err := encoder.Encode(types.VisitorSketch{ShortURL: key.shortURL, Day: key.day, Sketch: s}); err != nil {
+			return err
+		}
+	}
+	if _, err := fm.visitorsFile.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	for key, s := range merged {
+		fm.visitors[key] = s
+	}
+	return nil
+}
+
+// Visitors returns the merge of the visitor sketches of the short URL for the days starting in [VisitorDay(from), to).
+func (fm *Manager) Visitors(_ context.Context, shortURL string, from, to time.Time) (*hll.Sketch, error) {
+	fm.clicksMu.RLock()
+	defer fm.clicksMu.RUnlock()
+
+	merged := hll.New()
+	from = types.VisitorDay(from)
+	for key, s := range fm.visitors {
+		if key.shortURL == shortURL && !key.day.Before(from) && key.day.Before(to) {
+			merged.Merge(s)
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,193 @@
+package filestorage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// webhookLine is a line of the webhook file. A removed webhook is recorded by a line with Deleted set.
+type webhookLine struct {
+	types.Webhook
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// webhooksPath returns the path of the file that holds webhooks next to the URL storage file.
+func webhooksPath(storagePath string) string {
+	return storagePath + ".webhooks"
+}
+
+// deliveriesPath returns the path of the file that holds webhook deliveries next to the URL storage file.
+func deliveriesPath(storagePath string) string {
+	return storagePath + ".deliveries"
+}
+
+// loadWebhooks reads the webhooks and deliveries stored by previous runs.
+// Every change of a delivery appends the whole record, so the last line of a delivery wins;
+// deliveries of removed webhooks are dropped.
+func (fm *Manager) loadWebhooks() error {
+	fm.deliveryIndex = make(map[string]int)
+
+	err := readLines(webhooksPath(fm.cfg.FileStoragePath), func(line []byte) error {
+		var hook webhookLine
+		if err := json.Unmarshal(line, &hook); err != nil {
+			return err
+		}
+		if hook.Deleted {
+			fm.webhooks = slices.DeleteFunc(fm.webhooks, func(w types.Webhook) bool { return w.ID == hook.ID })
+			return nil
+		}
+		fm.webhooks = append(fm.webhooks, hook.Webhook)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return readLines(deliveriesPath(fm.cfg.FileStoragePath), func(line []byte) error {
+		var d types.Delivery
+		if err := json.Unmarshal(line, &d); err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(fm.webhooks, func(w types.Webhook) bool { return w.ID == d.WebhookID }) {
+			return nil
+		}
+		fm.setDelivery(d)
+		return nil
+	})
+}
+
+// readLines calls fn for every line of the file at path. A missing file has no lines.
+func readLines(path string, fn func([]byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// Delivery lines hold whole payloads
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err = fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// appendLine appends v as a JSON line to the file held by *file, opening it at path first if needed.
+func appendLine(file **os.File, path string, v any) error {
+	if *file == nil {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		*file = f
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = (*file).Write(append(data, '\n'))
+	return err
+}
+
+// PutWebhook appends a new webhook subscription to the webhook file.
+func (fm *Manager) PutWebhook(_ context.Context, hook types.Webhook) error {
+	fm.hooksMu.Lock()
+	defer fm.hooksMu.Unlock()
+
+	if err := appendLine(&fm.webhooksFile, webhooksPath(fm.cfg.FileStoragePath), webhookLine{Webhook: hook}); err != nil {
+		return err
+	}
+	fm.webhooks = append(fm.webhooks, hook)
+	return nil
+}
+
+// GetWebhooks returns the webhook subscriptions of userID, oldest first.
+func (fm *Manager) GetWebhooks(_ context.Context, userID string) ([]types.Webhook, error) {
+	fm.hooksMu.RLock()
+	defer fm.hooksMu.RUnlock()
+
+	var hooks []types.Webhook
+	for _, hook := range fm.webhooks {
+		if hook.UserID == userID {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+// DeleteWebhook records the removal of a webhook subscription of userID and forgets its deliveries.
+func (fm *Manager) DeleteWebhook(_ context.Context, id, userID string) error {
+	fm.hooksMu.Lock()
+	defer fm.hooksMu.Unlock()
+
+	i := slices.IndexFunc(fm.webhooks, func(hook types.Webhook) bool {
+		return hook.ID == id && hook.UserID == userID
+	})
+	if i < 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	line := webhookLine{Webhook: types.Webhook{ID: id}, Deleted: true}
+	if err := appendLine(&fm.webhooksFile, webhooksPath(fm.cfg.FileStoragePath), line); err != nil {
+		return err
+	}
+
+	fm.webhooks = slices.Delete(fm.webhooks, i, i+1)
+	fm.deliveries = slices.DeleteFunc(fm.deliveries, func(d types.Delivery) bool { return d.WebhookID == id })
+	fm.deliveryIndex = make(map[string]int, len(fm.deliveries))
+	for i, d := range fm.deliveries {
+		fm.deliveryIndex[d.ID] = i
+	}
+	return nil
+}
+
+// PutDelivery appends a delivery of a webhook event to the delivery file, replacing the one with the same ID.
+func (fm *Manager) PutDelivery(_ context.Context, delivery types.Delivery) error {
+	fm.hooksMu.Lock()
+	defer fm.hooksMu.Unlock()
+
+	if err := appendLine(&fm.deliveriesFile, deliveriesPath(fm.cfg.FileStoragePath), delivery); err != nil {
+		return err
+	}
+	fm.setDelivery(delivery)
+	return nil
+}
+
+// setDelivery replaces the delivery with the same ID or adds a new one.
+func (fm *Manager) setDelivery(delivery types.Delivery) {
+	if i, ok := fm.deliveryIndex[delivery.ID]; ok {
+		fm.deliveries[i] = delivery
+		return
+	}
+	fm.deliveryIndex[delivery.ID] = len(fm.deliveries)
+	fm.deliveries = append(fm.deliveries, delivery)
+}
+
+// GetDeliveries returns the deliveries of a webhook in the given status, newest first.
+func (fm *Manager) GetDeliveries(_ context.Context, webhookID, status string) ([]types.Delivery, error) {
+	fm.hooksMu.RLock()
+	defer fm.hooksMu.RUnlock()
+
+	var deliveries []types.Delivery
+	for i := len(fm.deliveries) - 1; i >= 0; i-- {
+		d := fm.deliveries[i]
+		if (webhookID == "" || d.WebhookID == webhookID) && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
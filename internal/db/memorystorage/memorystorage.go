@@ -3,16 +3,48 @@ package memorystorage
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/db/counters"
+	"github.com/jayjaytrn/URLShortener/internal/hll"
 	"github.com/jayjaytrn/URLShortener/internal/types"
 )
 
 // Manager handles in-memory storage for shortened URLs.
 type Manager struct {
+	mu          sync.RWMutex
 	RelatesURLs []types.URLData
 	Config      *config.Config
+	counters    *counters.Counters
+
+	// Click events have their own lock so that writing them does not hold up redirects
+	clicksMu sync.RWMutex
+	clicks   []types.ClickEvent
+	visitors map[visitorKey]*hll.Sketch
+
+	// Webhooks and their deliveries are guarded by their own lock
+	hooksMu    sync.RWMutex
+	webhooks   []types.Webhook
+	deliveries []types.Delivery
+
+	// Revoked sessions are kept by token ID until their tokens have expired
+	revokedMu sync.RWMutex
+	revoked   map[string]time.Time
+
+	// Secrets generated by the service, kept by name
+	secretsMu sync.Mutex
+	secrets   map[string]string
+}
+
+// visitorKey identifies the visitor sketch of a link and a day.
+type visitorKey struct {
+	shortURL string
+	day      time.Time
 }
 
 // NewManager initializes a new memory storage manager.
@@ -20,22 +52,157 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 	return &Manager{
 		RelatesURLs: []types.URLData{},
 		Config:      cfg,
+		counters:    counters.New(),
+		visitors:    make(map[visitorKey]*hll.Sketch),
+		revoked:     make(map[string]time.Time),
+		secrets:     make(map[string]string),
 	}, nil
 }
 
 // GetOriginal retrieves the original URL associated with the given short URL.
 func (m *Manager) GetOriginal(shortURL string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, urlData := range m.RelatesURLs {
 		if urlData.ShortURL == shortURL {
+			if urlData.Expired(time.Now()) {
+				return "", fmt.Errorf("URL has expired")
+			}
 			return urlData.OriginalURL, nil
 		}
 	}
 	return "", fmt.Errorf("URL not found")
 }
 
+// Get retrieves the full record associated with the given short URL.
+func (m *Manager) Get(shortURL string) (types.URLData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, urlData := range m.RelatesURLs {
+		if urlData.ShortURL == shortURL {
+			if err := urlData.Available(time.Now()); err != nil {
+				return types.URLData{}, err
+			}
+			return urlData, nil
+		}
+	}
+	return types.URLData{}, fmt.Errorf("URL not found")
+}
+
+// UseClick consumes one redirect of the URL and returns the updated record.
+func (m *Manager) UseClick(_ context.Context, shortURL string) (types.URLData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.RelatesURLs {
+		urlData := &m.RelatesURLs[i]
+		if urlData.ShortURL != shortURL {
+			continue
+		}
+		if err := urlData.Available(time.Now()); err != nil {
+			return types.URLData{}, err
+		}
+		if urlData.ClicksLeft != nil {
+			clicks := *urlData.ClicksLeft - 1
+			urlData.ClicksLeft = &clicks
+		}
+		return *urlData, nil
+	}
+	return types.URLData{}, fmt.Errorf("URL not found")
+}
+
+// UpdateURL changes the URL record owned by userID.
+func (m *Manager) UpdateURL(_ context.Context, shortURL, userID string, update func(*types.URLData) error) (types.URLData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.RelatesURLs {
+		if m.RelatesURLs[i].ShortURL != shortURL {
+			continue
+		}
+		if m.RelatesURLs[i].UserID != userID || m.RelatesURLs[i].DeletedFlag {
+			break
+		}
+		urlData := m.RelatesURLs[i]
+		if err := update(&urlData); err != nil {
+			return types.URLData{}, err
+		}
+		// Clip so that records handed out earlier keep their history
+		revisions := types.Revisions(m.RelatesURLs[i], urlData, len(urlData.History), userID, time.Now())
+		urlData.History = append(slices.Clip(urlData.History), revisions...)
+		m.RelatesURLs[i] = urlData
+		return urlData, nil
+	}
+	return types.URLData{}, fmt.Errorf("URL not found")
+}
+
+// GetUserURL returns the URL record owned by userID.
+func (m *Manager) GetUserURL(_ context.Context, shortURL, userID string) (types.URLData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, urlData := range m.RelatesURLs {
+		if urlData.ShortURL != shortURL {
+			continue
+		}
+		if urlData.UserID != userID || urlData.DeletedFlag {
+			break
+		}
+		return urlData, nil
+	}
+	return types.URLData{}, fmt.Errorf("URL not found")
+}
+
+// URLHistory returns the revisions of the URL record owned by userID.
+func (m *Manager) URLHistory(_ context.Context, shortURL, userID string) ([]types.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, urlData := range m.RelatesURLs {
+		if urlData.ShortURL != shortURL {
+			continue
+		}
+		if urlData.UserID != userID || urlData.DeletedFlag {
+			break
+		}
+		if len(urlData.History) == 0 {
+			return []types.Revision{types.FirstRevision(urlData, time.Now())}, nil
+		}
+		return slices.Clone(urlData.History), nil
+	}
+	return nil, fmt.Errorf("URL not found")
+}
+
+// CountAssignment increments the number of visitors assigned to a split variant of the URL.
+func (m *Manager) CountAssignment(_ context.Context, shortURL string, variant int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.RelatesURLs {
+		urlData := &m.RelatesURLs[i]
+		if urlData.ShortURL != shortURL {
+			continue
+		}
+		if variant < len(urlData.Assignments) {
+			// Copy so that records handed out earlier keep their counts
+			assignments := append([]int64(nil), urlData.Assignments...)
+			assignments[variant]++
+			urlData.Assignments = assignments
+		}
+		return nil
+	}
+	return fmt.Errorf("URL not found")
+}
+
 // Put stores a new URL mapping in memory.
 func (m *Manager) Put(urlData types.URLData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.RelatesURLs = append(m.RelatesURLs, urlData)
+	m.counters.Add(urlData)
 	return nil
 }
 
@@ -57,6 +224,9 @@ func (m *Manager) GenerateNewUserID() string {
 
 // Exists checks if a given short URL exists in the storage.
 func (m *Manager) Exists(shortURL string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, urlData := range m.RelatesURLs {
 		if urlData.ShortURL == shortURL {
 			return true, nil
@@ -67,13 +237,24 @@ func (m *Manager) Exists(shortURL string) (bool, error) {
 
 // GetURLsByUserID retrieves all URLs shortened by a specific user.
 func (m *Manager) GetURLsByUserID(userID string) ([]types.URLData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	var userURLs []types.URLData
 
 	for _, urlData := range m.RelatesURLs {
 		if urlData.UserID == userID {
 			userURLs = append(userURLs, types.URLData{
-				ShortURL:    m.Config.BaseURL + "/" + urlData.ShortURL,
-				OriginalURL: urlData.OriginalURL,
+				ShortURL:     m.Config.BaseURL + "/" + urlData.ShortURL,
+				OriginalURL:  urlData.OriginalURL,
+				UserID:       urlData.UserID,
+				CreatedAt:    urlData.CreatedAt,
+				DeletedFlag:  urlData.DeletedFlag,
+				ExpiresAt:    urlData.ExpiresAt,
+				ClicksLeft:   urlData.ClicksLeft,
+				PasswordHash: urlData.PasswordHash,
+				Options:      urlData.Options,
+				Assignments:  urlData.Assignments,
 			})
 		}
 	}
@@ -85,8 +266,23 @@ func (m *Manager) GetURLsByUserID(userID string) ([]types.URLData, error) {
 	return userURLs, nil
 }
 
-// BatchDelete is a placeholder for batch deletion, not implemented for memory storage.
-func (m *Manager) BatchDelete(_ chan string, _ string) {
+// BatchDelete marks the URLs received from urlChannel as deleted when they belong to userID.
+func (m *Manager) BatchDelete(urlChannel chan string, userID string) {
+	for shortURL := range urlChannel {
+		m.mu.Lock()
+		for i := range m.RelatesURLs {
+			urlData := &m.RelatesURLs[i]
+			if urlData.ShortURL != shortURL {
+				continue
+			}
+			if urlData.UserID == userID && !urlData.DeletedFlag {
+				m.counters.Delete(*urlData)
+				urlData.DeletedFlag = true
+			}
+			break
+		}
+		m.mu.Unlock()
+	}
 }
 
 // Close releases any allocated resources (not required for memory storage).
@@ -99,19 +295,151 @@ func (m *Manager) Ping(_ context.Context) error {
 	return fmt.Errorf("ping is not supported for memory storage")
 }
 
-// GetStats возвращает количество сокращенных URL и количество пользователей.
-func (m *Manager) GetStats() (types.Stats, error) {
-	urlCount := len(m.RelatesURLs)
-	userSet := make(map[string]struct{})
+// ForEachURL calls fn for every stored URL record.
+func (m *Manager) ForEachURL(_ context.Context, fn func(types.URLData) error) error {
+	m.mu.RLock()
+	snapshot := make([]types.URLData, len(m.RelatesURLs))
+	copy(snapshot, m.RelatesURLs)
+	m.mu.RUnlock()
 
+	for _, urlData := range snapshot {
+		if err := fn(urlData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetFlagged marks the given short URLs as pointing to a malicious destination.
+func (m *Manager) SetFlagged(_ context.Context, shortURLs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flagged := make(map[string]struct{}, len(shortURLs))
+	for _, shortURL := range shortURLs {
+		flagged[shortURL] = struct{}{}
+	}
+	for i := range m.RelatesURLs {
+		if _, ok := flagged[m.RelatesURLs[i].ShortURL]; ok {
+			m.RelatesURLs[i].Flagged = true
+		}
+	}
+	return nil
+}
+
+// PutClicks stores a batch of click events in memory.
+func (m *Manager) PutClicks(_ context.Context, events []types.ClickEvent) error {
+	m.clicksMu.Lock()
+	defer m.clicksMu.Unlock()
+
+	m.clicks = append(m.clicks, events...)
+	m.counters.Redirected(len(events))
+	return nil
+}
+
+// ForEachClick calls fn for every click event of the short URL recorded in [from, to).
+func (m *Manager) ForEachClick(_ context.Context, shortURL string, from, to time.Time, fn func(types.ClickEvent) error) error {
+	m.clicksMu.RLock()
+	// Events are only appended, so the prefix seen now stays valid without the lock
+	events := m.clicks
+	m.clicksMu.RUnlock()
+
+	for _, event := range events {
+		if event.ShortURL != shortURL || event.Time.Before(from) || !event.Time.Before(to) {
+			continue
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachClickIn calls fn for every click event of the given short URLs recorded in [from, to).
+// Nil short URLs select the events of every link.
+func (m *Manager) ForEachClickIn(_ context.Context, shortURLs []string, from, to time.Time, fn func(types.ClickEvent) error) error {
+	var selected map[string]bool
+	if shortURLs != nil {
+		selected = make(map[string]bool, len(shortURLs))
+		for _, shortURL := range shortURLs {
+			selected[shortURL] = true
+		}
+	}
+
+	m.clicksMu.RLock()
+	events := m.clicks
+	m.clicksMu.RUnlock()
+
+	// Events are written in batches, so they are only roughly in time order
+	var matched []types.ClickEvent
+	for _, event := range events {
+		if (selected == nil || selected[event.ShortURL]) && !event.Time.Before(from) && event.Time.Before(to) {
+			matched = append(matched, event)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Time.Before(matched[j].Time) })
+
+	for _, event := range matched {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergeVisitors merges daily visitor sketches into the stored ones.
+func (m *Manager) MergeVisitors(_ context.Context, sketches []types.VisitorSketch) error {
+	m.clicksMu.Lock()
+	defer m.clicksMu.Unlock()
+
+	for _, s := range sketches {
+		key := visitorKey{shortURL: s.ShortURL, day: types.VisitorDay(s.Day)}
+		stored, ok := m.visitors[key]
+		if !ok {
+			stored = hll.New()
+			m.visitors[key] = stored
+		}
+		stored.Merge(s.Sketch)
+	}
+	return nil
+}
+
+// Visitors returns the merge of the visitor sketches of the short URL for the days starting in [VisitorDay(from), to).
+func (m *Manager) Visitors(_ context.Context, shortURL string, from, to time.Time) (*hll.Sketch, error) {
+	m.clicksMu.RLock()
+	defer m.clicksMu.RUnlock()
+
+	merged := hll.New()
+	from = types.VisitorDay(from)
+	for key, s := range m.visitors {
+		if key.shortURL == shortURL && !key.day.Before(from) && key.day.Before(to) {
+			merged.Merge(s)
+		}
+	}
+	return merged, nil
+}
+
+// DeleteExpired removes URLs that expired before the given moment.
+func (m *Manager) DeleteExpired(_ context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.RelatesURLs[:0]
 	for _, urlData := range m.RelatesURLs {
-		if urlData.UserID != "" {
-			userSet[urlData.UserID] = struct{}{}
+		if urlData.ExpiresAt == nil || !urlData.ExpiresAt.Before(before) {
+			kept = append(kept, urlData)
+			continue
 		}
+		m.counters.Remove(urlData)
 	}
+	removed := len(m.RelatesURLs) - len(kept)
+	m.RelatesURLs = kept
 
-	return types.Stats{
-		Urls:  urlCount,
-		Users: len(userSet),
-	}, nil
+	return removed, nil
+}
+
+// GetStats возвращает количество сокращенных URL и количество пользователей.
+// The statistics are maintained as records change.
+func (m *Manager) GetStats() (types.Stats, error) {
+	return m.counters.Stats(time.Now()), nil
 }
@@ -0,0 +1,39 @@
+package memorystorage
+
+import (
+	"context"
+	"time"
+)
+
+// RevokeToken records the revocation of the session with the given token ID until the given moment.
+// Revocations that have lapsed are forgotten.
+func (m *Manager) RevokeToken(_ context.Context, tokenID string, until time.Time) error {
+	m.revokedMu.Lock()
+	defer m.revokedMu.Unlock()
+
+	now := time.Now()
+	for id, u := range m.revoked {
+		if !u.After(now) {
+			delete(m.revoked, id)
+		}
+	}
+	if until.After(m.revoked[tokenID]) {
+		m.revoked[tokenID] = until
+	}
+	return nil
+}
+
+// RevokedTokens returns the revocations that have not lapsed.
+func (m *Manager) RevokedTokens(_ context.Context) (map[string]time.Time, error) {
+	m.revokedMu.RLock()
+	defer m.revokedMu.RUnlock()
+
+	now := time.Now()
+	result := make(map[string]time.Time, len(m.revoked))
+	for id, until := range m.revoked {
+		if until.After(now) {
+			result[id] = until
+		}
+	}
+	return result, nil
+}
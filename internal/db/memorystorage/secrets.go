@@ -0,0 +1,15 @@
+package memorystorage
+
+import "context"
+
+// LoadOrStoreSecret returns the secret stored under name, storing value under it first if there is none.
+func (m *Manager) LoadOrStoreSecret(_ context.Context, name, value string) (string, error) {
+	m.secretsMu.Lock()
+	defer m.secretsMu.Unlock()
+
+	if stored, ok := m.secrets[name]; ok {
+		return stored, nil
+	}
+	m.secrets[name] = value
+	return value, nil
+}
@@ -0,0 +1,78 @@
+package memorystorage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// PutWebhook stores a new webhook subscription.
+func (m *Manager) PutWebhook(_ context.Context, hook types.Webhook) error {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+
+	m.webhooks = append(m.webhooks, hook)
+	return nil
+}
+
+// GetWebhooks returns the webhook subscriptions of userID, oldest first.
+func (m *Manager) GetWebhooks(_ context.Context, userID string) ([]types.Webhook, error) {
+	m.hooksMu.RLock()
+	defer m.hooksMu.RUnlock()
+
+	var hooks []types.Webhook
+	for _, hook := range m.webhooks {
+		if hook.UserID == userID {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+// DeleteWebhook removes a webhook subscription of userID together with its deliveries.
+func (m *Manager) DeleteWebhook(_ context.Context, id, userID string) error {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+
+	i := slices.IndexFunc(m.webhooks, func(hook types.Webhook) bool {
+		return hook.ID == id && hook.UserID == userID
+	})
+	if i < 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	m.webhooks = slices.Delete(m.webhooks, i, i+1)
+	m.deliveries = slices.DeleteFunc(m.deliveries, func(d types.Delivery) bool {
+		return d.WebhookID == id
+	})
+	return nil
+}
+
+// PutDelivery stores a delivery of a webhook event, replacing the one with the same ID.
+func (m *Manager) PutDelivery(_ context.Context, delivery types.Delivery) error {
+	m.hooksMu.Lock()
+	defer m.hooksMu.Unlock()
+
+	if i := slices.IndexFunc(m.deliveries, func(d types.Delivery) bool { return d.ID == delivery.ID }); i >= 0 {
+		m.deliveries[i] = delivery
+		return nil
+	}
+	m.deliveries = append(m.deliveries, delivery)
+	return nil
+}
+
+// GetDeliveries returns the deliveries of a webhook in the given status, newest first.
+func (m *Manager) GetDeliveries(_ context.Context, webhookID, status string) ([]types.Delivery, error) {
+	m.hooksMu.RLock()
+	defer m.hooksMu.RUnlock()
+
+	var deliveries []types.Delivery
+	for i := len(m.deliveries) - 1; i >= 0; i-- {
+		d := m.deliveries[i]
+		if (webhookID == "" || d.WebhookID == webhookID) && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// countersFunction maintains the link statistics as rows of shortener are inserted,
+// deleted or have their deletion flag or owner changed.
+const countersFunction = `
+CREATE OR REPLACE FUNCTION shortener_count() RETURNS trigger AS $$
+DECLARE
+	user_links BIGINT;
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		IF COALESCE(OLD.is_deleted, FALSE) THEN
+			UPDATE shortener_counters SET value = value - 1 WHERE name = 'deleted';
+		ELSE
+			UPDATE shortener_counters SET value = value - 1 WHERE name = 'live';
+			UPDATE shortener_user_counts SET links = shortener_user_counts.links - 1
+				WHERE user_id = OLD.user_id RETURNING shortener_user_counts.links INTO user_links;
+			IF user_links = 0 THEN
+				UPDATE shortener_counters SET value = value - 1 WHERE name = 'users';
+			END IF;
+		END IF;
+	END IF;
+
+	IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		IF COALESCE(NEW.is_deleted, FALSE) THEN
+			UPDATE shortener_counters SET value = value + 1 WHERE name = 'deleted';
+		ELSE
+			UPDATE shortener_counters SET value = value + 1 WHERE name = 'live';
+			INSERT INTO shortener_user_counts (user_id, links) VALUES (NEW.user_id, 1)
+				ON CONFLICT (user_id) DO UPDATE SET links = shortener_user_counts.links + 1
+				RETURNING shortener_user_counts.links INTO user_links;
+			IF user_links = 1 THEN
+				UPDATE shortener_counters SET value = value + 1 WHERE name = 'users';
+			END IF;
+		END IF;
+	END IF;
+
+	IF TG_OP = 'INSERT' THEN
+		INSERT INTO shortener_daily_created (day, links) VALUES ((NEW.created_at AT TIME ZONE 'UTC')::date, 1)
+			ON CONFLICT (day) DO UPDATE SET links = shortener_daily_created.links + 1;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;`
+
+// createCounters creates the tables of the link statistics and the trigger that maintains them.
+// The tables are filled from the existing rows the first time, with shortener locked so
+// that no change is missed between the initial counts and the trigger.
+func (m *Manager) createCounters() error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS shortener_counters (
+		name VARCHAR(32) PRIMARY KEY,
+		value BIGINT NOT NULL
+	);`,
+		`CREATE TABLE IF NOT EXISTS shortener_user_counts (
+		user_id VARCHAR(255) PRIMARY KEY,
+		links BIGINT NOT NULL
+	);`,
+		`CREATE INDEX IF NOT EXISTS shortener_user_counts_links_idx ON shortener_user_counts (links DESC);`,
+		`CREATE TABLE IF NOT EXISTS shortener_daily_created (
+		day DATE PRIMARY KEY,
+		links BIGINT NOT NULL
+	);`,
+		`LOCK TABLE shortener IN SHARE ROW EXCLUSIVE MODE;`,
+		countersFunction,
+		`DROP TRIGGER IF EXISTS shortener_count ON shortener;`,
+		`CREATE TRIGGER shortener_count AFTER INSERT OR DELETE OR UPDATE OF is_deleted, user_id ON shortener
+		FOR EACH ROW EXECUTE FUNCTION shortener_count();`,
+	}
+	for _, query := range queries {
+		if _, err = tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to create counters: %w", err)
+		}
+	}
+
+	var seeded bool
+	if err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM shortener_counters WHERE name = 'live')").Scan(&seeded); err != nil {
+		return fmt.Errorf("failed to check counters: %w", err)
+	}
+	if !seeded {
+		seed := []string{
+			`INSERT INTO shortener_user_counts (user_id, links)
+		SELECT user_id, COUNT(*) FROM shortener WHERE NOT COALESCE(is_deleted, FALSE) GROUP BY user_id;`,
+			`INSERT INTO shortener_daily_created (day, links)
+		SELECT (created_at AT TIME ZONE 'UTC')::date, COUNT(*) FROM shortener GROUP BY 1;`,
+			`INSERT INTO shortener_counters (name, value)
+		SELECT 'live', COUNT(*) FILTER (WHERE NOT COALESCE(is_deleted, FALSE)) FROM shortener
+		UNION ALL SELECT 'deleted', COUNT(*) FILTER (WHERE COALESCE(is_deleted, FALSE)) FROM shortener
+		UNION ALL SELECT 'users', COUNT(*) FROM shortener_user_counts
+		UNION ALL SELECT 'redirects', COUNT(*) FROM clicks;`,
+		}
+		for _, query := range seed {
+			if _, err = tx.Exec(query); err != nil {
+				return fmt.Errorf("failed to fill counters: %w", err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetStats возвращает количество сокращённых URL и количество уникальных пользователей.
+// The counts are maintained by a trigger; only expired links are counted by the query,
+// which reads the expiry index and is bounded by the retention of the sweeper.
+func (m *Manager) GetStats() (types.Stats, error) {
+	ctx := context.Background()
+	var stats types.Stats
+
+	rows, err := m.db.QueryContext(ctx, "SELECT name, value FROM shortener_counters")
+	if err != nil {
+		return stats, fmt.Errorf("failed to get counters: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var value int64
+		if err = rows.Scan(&name, &value); err != nil {
+			return stats, fmt.Errorf("failed to scan counter: %w", err)
+		}
+		switch name {
+		case "live":
+			stats.Urls = int(value)
+		case "deleted":
+			stats.Deleted = int(value)
+		case "users":
+			stats.Users = int(value)
+		case "redirects":
+			stats.Redirects = value
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return stats, fmt.Errorf("rows error: %w", err)
+	}
+
+	err = m.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM shortener WHERE expires_at <= now() AND NOT COALESCE(is_deleted, FALSE)").Scan(&stats.Expired)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count expired URLs: %w", err)
+	}
+	stats.Active = stats.Urls - stats.Expired
+
+	now := time.Now().UTC()
+	first := types.VisitorDay(now).AddDate(0, 0, 1-types.StatsDays)
+	if stats.CreatedPerDay, err = m.createdPerDay(ctx, first); err != nil {
+		return stats, err
+	}
+	if stats.TopUsers, err = m.topUsers(ctx); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// createdPerDay returns the number of links created on each day since first.
+func (m *Manager) createdPerDay(ctx context.Context, first time.Time) ([]types.DayCount, error) {
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT day, links FROM shortener_daily_created WHERE day >= $1::date AND links > 0 ORDER BY day",
+		first.Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily counts: %w", err)
+	}
+	defer rows.Close()
+
+	var days []types.DayCount
+	for rows.Next() {
+		var d types.DayCount
+		if err := rows.Scan(&d.Day, &d.Links); err != nil {
+			return nil, fmt.Errorf("failed to scan daily count: %w", err)
+		}
+		// Dates are scanned as midnight in the session time zone
+		d.Day = time.Date(d.Day.Year(), d.Day.Month(), d.Day.Day(), 0, 0, 0, 0, time.UTC)
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return days, nil
+}
+
+// topUsers returns the users with the most links that are not deleted.
+func (m *Manager) topUsers(ctx context.Context) ([]types.UserCount, error) {
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT user_id, links FROM shortener_user_counts WHERE links > 0 ORDER BY links DESC, user_id LIMIT $1",
+		types.StatsTopUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user counts: %w", err)
+	}
+	defer rows.Close()
+
+	var users []types.UserCount
+	for rows.Next() {
+		var u types.UserCount
+		if err := rows.Scan(&u.UserID, &u.Links); err != nil {
+			return nil, fmt.Errorf("failed to scan user count: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return users, nil
+}
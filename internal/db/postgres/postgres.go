@@ -3,12 +3,15 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/hll"
 	"github.com/jayjaytrn/URLShortener/internal/types"
 	"github.com/lib/pq"
 )
@@ -23,6 +26,69 @@ func (e *OriginalExistError) Error() string {
 	return fmt.Sprintf("original URL already exists, short URL for it is: %s", e.ShortURL)
 }
 
+// urlColumns lists the columns read by scanURL.
+const urlColumns = "user_id, short_url, original_url, is_deleted, is_flagged, created_at, expires_at, clicks_left, password_hash, options, variant_assignments"
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanURL reads a row selected with urlColumns.
+func scanURL(row rowScanner) (types.URLData, error) {
+	var urlData types.URLData
+	var createdAt, expiresAt sql.NullTime
+	var clicksLeft sql.NullInt64
+	var options []byte
+	var assignments pq.Int64Array
+	err := row.Scan(&urlData.UserID, &urlData.ShortURL, &urlData.OriginalURL, &urlData.DeletedFlag, &urlData.Flagged, &createdAt, &expiresAt, &clicksLeft, &urlData.PasswordHash, &options, &assignments)
+	if err != nil {
+		return types.URLData{}, err
+	}
+	if len(assignments) > 0 {
+		urlData.Assignments = assignments
+	}
+	if options != nil {
+		urlData.Options = &types.LinkOptions{}
+		if err = json.Unmarshal(options, urlData.Options); err != nil {
+			return types.URLData{}, fmt.Errorf("failed to decode link options: %w", err)
+		}
+	}
+	urlData.CreatedAt = nullTimePtr(createdAt)
+	urlData.ExpiresAt = nullTimePtr(expiresAt)
+	urlData.ClicksLeft = nullInt64Ptr(clicksLeft)
+	return urlData, nil
+}
+
+// nullTimePtr converts a nullable timestamp to a pointer.
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := t.Time.UTC()
+	return &v
+}
+
+// nullInt64Ptr converts a nullable integer to a pointer.
+func nullInt64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+// encodeOptions converts link options to a JSONB value.
+func encodeOptions(o *types.LinkOptions) (any, error) {
+	if o == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode link options: %w", err)
+	}
+	return string(data), nil
+}
+
 // Manager handles database interactions for URL shortening.
 type Manager struct {
 	db      *sql.DB
@@ -50,6 +116,22 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 		return nil, fmt.Errorf("failed to create table: %w", err)
 	}
 
+	if err = manager.createCounters(); err != nil {
+		return nil, err
+	}
+
+	if err = manager.createWebhookTables(); err != nil {
+		return nil, err
+	}
+
+	if err = manager.createRevocationTable(); err != nil {
+		return nil, err
+	}
+
+	if err = manager.createSecretsTable(); err != nil {
+		return nil, err
+	}
+
 	putStmt, err := preparePutStatement(db)
 	if err != nil {
 		return nil, err
@@ -62,8 +144,11 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 // GetOriginal retrieves the original URL associated with the given short URL.
 func (m *Manager) GetOriginal(shortURL string) (string, error) {
 	var originalURL string
-	var isDeleted bool
-	err := m.db.QueryRow("SELECT original_url, is_deleted FROM shortener WHERE short_url = $1", shortURL).Scan(&originalURL, &isDeleted)
+	var isDeleted, isExpired bool
+	err := m.db.QueryRow(
+		"SELECT original_url, is_deleted, COALESCE(expires_at <= now(), FALSE) FROM shortener WHERE short_url = $1",
+		shortURL,
+	).Scan(&originalURL, &isDeleted, &isExpired)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return "", fmt.Errorf("URL not found")
@@ -73,12 +158,52 @@ func (m *Manager) GetOriginal(shortURL string) (string, error) {
 	if isDeleted {
 		return "", fmt.Errorf("URL has been deleted")
 	}
+	if isExpired {
+		return "", fmt.Errorf("URL has expired")
+	}
 	return originalURL, nil
 }
 
+// Get retrieves the full record associated with the given short URL.
+func (m *Manager) Get(shortURL string) (types.URLData, error) {
+	urlData, err := scanURL(m.db.QueryRow("SELECT "+urlColumns+" FROM shortener WHERE short_url = $1", shortURL))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.URLData{}, fmt.Errorf("URL not found")
+		}
+		return types.URLData{}, fmt.Errorf("failed to get URL: %w", err)
+	}
+	if err = urlData.Available(time.Now()); err != nil {
+		return types.URLData{}, err
+	}
+	return urlData, nil
+}
+
+// UseClick atomically consumes one redirect of the URL and returns the updated record.
+// Links without a click limit are returned unchanged.
+func (m *Manager) UseClick(ctx context.Context, shortURL string) (types.URLData, error) {
+	urlData, err := scanURL(m.db.QueryRowContext(ctx, `
+		UPDATE shortener SET clicks_left = clicks_left - 1
+		WHERE short_url = $1 AND NOT is_deleted
+			AND (expires_at IS NULL OR expires_at > now())
+			AND (clicks_left IS NULL OR clicks_left > 0)
+		RETURNING `+urlColumns, shortURL))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Nothing was updated: report why the URL is unavailable
+			if _, err = m.Get(shortURL); err != nil {
+				return types.URLData{}, err
+			}
+			return types.URLData{}, fmt.Errorf("URL has no clicks left")
+		}
+		return types.URLData{}, fmt.Errorf("failed to use URL: %w", err)
+	}
+	return urlData, nil
+}
+
 // GetURLsByUserID retrieves all URLs shortened by a specific user.
 func (m *Manager) GetURLsByUserID(userID string) ([]types.URLData, error) {
-	rows, err := m.db.Query("SELECT short_url, original_url FROM shortener WHERE user_id = $1", userID)
+	rows, err := m.db.Query("SELECT "+urlColumns+" FROM shortener WHERE user_id = $1", userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get URLs for user: %w", err)
 	}
@@ -86,13 +211,21 @@ func (m *Manager) GetURLsByUserID(userID string) ([]types.URLData, error) {
 
 	var urls []types.URLData
 	for rows.Next() {
-		var shortURL, originalURL string
-		if err := rows.Scan(&shortURL, &originalURL); err != nil {
+		urlData, err := scanURL(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 		urls = append(urls, types.URLData{
-			ShortURL:    m.cfg.BaseURL + "/" + shortURL,
-			OriginalURL: originalURL,
+			ShortURL:     m.cfg.BaseURL + "/" + urlData.ShortURL,
+			OriginalURL:  urlData.OriginalURL,
+			UserID:       urlData.UserID,
+			CreatedAt:    urlData.CreatedAt,
+			DeletedFlag:  urlData.DeletedFlag,
+			ExpiresAt:    urlData.ExpiresAt,
+			ClicksLeft:   urlData.ClicksLeft,
+			PasswordHash: urlData.PasswordHash,
+			Options:      urlData.Options,
+			Assignments:  urlData.Assignments,
 		})
 	}
 
@@ -107,11 +240,325 @@ func (m *Manager) GetURLsByUserID(userID string) ([]types.URLData, error) {
 	return urls, nil
 }
 
+// UpdateURL changes the URL record owned by userID inside a transaction that locks the row.
+func (m *Manager) UpdateURL(ctx context.Context, shortURL, userID string, update func(*types.URLData) error) (types.URLData, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return types.URLData{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	urlData, err := scanURL(tx.QueryRowContext(ctx,
+		"SELECT "+urlColumns+" FROM shortener WHERE short_url = $1 AND user_id = $2 AND NOT is_deleted FOR UPDATE",
+		shortURL, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.URLData{}, fmt.Errorf("URL not found")
+		}
+		return types.URLData{}, fmt.Errorf("failed to get URL: %w", err)
+	}
+
+	before := urlData
+	if err = update(&urlData); err != nil {
+		return types.URLData{}, err
+	}
+
+	// Original URLs of plain links are unique, so the new destination of a plain link
+	// must not have a plain link already
+	if urlData.OriginalURL != before.OriginalURL {
+		var existing string
+		err = tx.QueryRowContext(ctx, `
+			SELECT s.short_url FROM shortener s JOIN shortener o ON o.short_url = $2
+			WHERE s.original_url = $1 AND s.short_url <> $2 AND s.is_plain AND o.is_plain`,
+			urlData.OriginalURL, shortURL).Scan(&existing)
+		if err == nil {
+			return types.URLData{}, &OriginalExistError{ShortURL: existing}
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return types.URLData{}, fmt.Errorf("failed to check original URL: %w", err)
+		}
+	}
+
+	options, err := encodeOptions(urlData.Options)
+	if err != nil {
+		return types.URLData{}, err
+	}
+	// A link that gets settings of its own is no longer shared; it does not become shared again
+	// when they are removed, as another plain link of its original URL may exist by then
+	_, err = tx.ExecContext(ctx, `
+		UPDATE shortener SET original_url = $2, expires_at = $3, clicks_left = $4, password_hash = $5, options = $6,
+			variant_assignments = $7, is_flagged = $8, is_plain = is_plain AND $9
+		WHERE short_url = $1`,
+		shortURL, urlData.OriginalURL, urlData.ExpiresAt, urlData.ClicksLeft, urlData.PasswordHash, options,
+		pq.Array(urlData.Assignments), urlData.Flagged, urlData.Plain())
+	if err != nil {
+		return types.URLData{}, fmt.Errorf("failed to update URL: %w", err)
+	}
+
+	var last int
+	err = tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM shortener_history WHERE short_url = $1", shortURL).Scan(&last)
+	if err != nil {
+		return types.URLData{}, fmt.Errorf("failed to get URL history: %w", err)
+	}
+	for _, r := range types.Revisions(before, urlData, last, userID, time.Now()) {
+		options, err := encodeOptions(r.Options)
+		if err != nil {
+			return types.URLData{}, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO shortener_history (short_url, version, original_url, options, changed_at, changed_by)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			shortURL, r.Version, r.OriginalURL, options, r.ChangedAt, r.ChangedBy)
+		if err != nil {
+			return types.URLData{}, fmt.Errorf("failed to record URL revision: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return types.URLData{}, fmt.Errorf("failed to commit URL update: %w", err)
+	}
+	return urlData, nil
+}
+
+// GetUserURL returns the URL record owned by userID.
+func (m *Manager) GetUserURL(ctx context.Context, shortURL, userID string) (types.URLData, error) {
+	urlData, err := scanURL(m.db.QueryRowContext(ctx,
+		"SELECT "+urlColumns+" FROM shortener WHERE short_url = $1 AND user_id = $2 AND NOT is_deleted",
+		shortURL, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.URLData{}, fmt.Errorf("URL not found")
+		}
+		return types.URLData{}, fmt.Errorf("failed to get URL: %w", err)
+	}
+	return urlData, nil
+}
+
+// URLHistory returns the revisions of the URL record owned by userID.
+func (m *Manager) URLHistory(ctx context.Context, shortURL, userID string) ([]types.Revision, error) {
+	urlData, err := m.GetUserURL(ctx, shortURL, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT version, original_url, options, changed_at, changed_by
+		FROM shortener_history WHERE short_url = $1 ORDER BY version`, shortURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []types.Revision
+	for rows.Next() {
+		var r types.Revision
+		var options []byte
+		if err := rows.Scan(&r.Version, &r.OriginalURL, &options, &r.ChangedAt, &r.ChangedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan URL revision: %w", err)
+		}
+		if options != nil {
+			r.Options = &types.LinkOptions{}
+			if err := json.Unmarshal(options, r.Options); err != nil {
+				return nil, fmt.Errorf("failed to decode link options: %w", err)
+			}
+		}
+		history = append(history, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	if len(history) == 0 {
+		return []types.Revision{types.FirstRevision(urlData, time.Now())}, nil
+	}
+	return history, nil
+}
+
+// PutClicks inserts a batch of click events in one transaction.
+func (m *Manager) PutClicks(ctx context.Context, events []types.ClickEvent) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		"INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, ip_hash, language, country, is_bot) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		if _, err = stmt.ExecContext(ctx, e.ShortURL, e.Time, e.Referrer, e.UserAgent, e.IPHash, e.Language, e.Country, e.Bot); err != nil {
+			return fmt.Errorf("failed to insert click event: %w", err)
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE shortener_counters SET value = value + $1 WHERE name = 'redirects'", len(events)); err != nil {
+		return fmt.Errorf("failed to count click events: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit click events: %w", err)
+	}
+	return nil
+}
+
+// MergeVisitors merges daily visitor sketches into the stored ones in one transaction.
+// Stored rows are locked while they are merged, so concurrent writers do not lose visitors.
+func (m *Manager) MergeVisitors(ctx context.Context, sketches []types.VisitorSketch) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, s := range sketches {
+		day := types.VisitorDay(s.Day)
+		// An empty row is created first so that there is always a row to lock
+		if _, err = tx.ExecContext(ctx,
+			"INSERT INTO visitor_sketches (short_url, day, sketch) VALUES ($1, $2, NULL) ON CONFLICT (short_url, day) DO NOTHING",
+			s.ShortURL, day); err != nil {
+			return fmt.Errorf("failed to insert visitor sketch: %w", err)
+		}
+
+		var data []byte
+		if err = tx.QueryRowContext(ctx,
+			"SELECT sketch FROM visitor_sketches WHERE short_url = $1 AND day = $2 FOR UPDATE",
+			s.ShortURL, day).Scan(&data); err != nil {
+			return fmt.Errorf("failed to get visitor sketch: %w", err)
+		}
+
+		merged := hll.New()
+		if data != nil {
+			if err = merged.UnmarshalBinary(data); err != nil {
+				return fmt.Errorf("failed to decode visitor sketch: %w", err)
+			}
+		}
+		merged.Merge(s.Sketch)
+		if data, err = merged.MarshalBinary(); err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx,
+			"UPDATE visitor_sketches SET sketch = $3 WHERE short_url = $1 AND day = $2",
+			s.ShortURL, day, data); err != nil {
+			return fmt.Errorf("failed to update visitor sketch: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit visitor sketches: %w", err)
+	}
+	return nil
+}
+
+// Visitors returns the merge of the visitor sketches of the short URL for the days starting in [VisitorDay(from), to).
+func (m *Manager) Visitors(ctx context.Context, shortURL string, from, to time.Time) (*hll.Sketch, error) {
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT sketch FROM visitor_sketches WHERE short_url = $1 AND day >= $2 AND day < $3 AND sketch IS NOT NULL",
+		shortURL, types.VisitorDay(from), to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visitor sketches: %w", err)
+	}
+	defer rows.Close()
+
+	merged := hll.New()
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan visitor sketch: %w", err)
+		}
+		s := hll.New()
+		if err := s.UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("failed to decode visitor sketch: %w", err)
+		}
+		merged.Merge(s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return merged, nil
+}
+
+// ForEachClick calls fn for every click event of the short URL recorded in [from, to).
+func (m *Manager) ForEachClick(ctx context.Context, shortURL string, from, to time.Time, fn func(types.ClickEvent) error) error {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT short_url, clicked_at, referrer, user_agent, ip_hash, language, country, is_bot
+		FROM clicks WHERE short_url = $1 AND clicked_at >= $2 AND clicked_at < $3
+		ORDER BY clicked_at`, shortURL, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to get click events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e types.ClickEvent
+		if err := rows.Scan(&e.ShortURL, &e.Time, &e.Referrer, &e.UserAgent, &e.IPHash, &e.Language, &e.Country, &e.Bot); err != nil {
+			return fmt.Errorf("failed to scan click event: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+	return nil
+}
+
+// ForEachClickIn calls fn for every click event of the given short URLs recorded in [from, to).
+// Nil short URLs select the events of every link.
+func (m *Manager) ForEachClickIn(ctx context.Context, shortURLs []string, from, to time.Time, fn func(types.ClickEvent) error) error {
+	// A nil slice is sent as NULL, which selects every link
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT short_url, clicked_at, referrer, user_agent, ip_hash, language, country, is_bot
+		FROM clicks WHERE ($1::text[] IS NULL OR short_url = ANY($1)) AND clicked_at >= $2 AND clicked_at < $3
+		ORDER BY clicked_at`, pq.Array(shortURLs), from, to)
+	if err != nil {
+		return fmt.Errorf("failed to get click events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e types.ClickEvent
+		if err := rows.Scan(&e.ShortURL, &e.Time, &e.Referrer, &e.UserAgent, &e.IPHash, &e.Language, &e.Country, &e.Bot); err != nil {
+			return fmt.Errorf("failed to scan click event: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+	return nil
+}
+
+// CountAssignment increments the number of visitors assigned to a split variant of the URL.
+func (m *Manager) CountAssignment(ctx context.Context, shortURL string, variant int) error {
+	// Postgres arrays are 1-based
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE shortener SET variant_assignments[$2] = variant_assignments[$2] + 1
+		WHERE short_url = $1 AND $2 <= cardinality(variant_assignments)`,
+		shortURL, variant+1)
+	if err != nil {
+		return fmt.Errorf("failed to count variant assignment: %w", err)
+	}
+	return nil
+}
+
 // Put inserts a new short URL into the database.
 func (m *Manager) Put(urlData types.URLData) error {
 	var alreadyExistedShortURL string
 
-	err := m.putStmt.QueryRow(urlData.ShortURL, urlData.OriginalURL, urlData.UserID).Scan(&alreadyExistedShortURL)
+	options, err := encodeOptions(urlData.Options)
+	if err != nil {
+		return err
+	}
+
+	err = m.putStmt.QueryRow(urlData.ShortURL, urlData.OriginalURL, urlData.UserID, urlData.CreatedAt, urlData.ExpiresAt, urlData.ClicksLeft, urlData.PasswordHash, options, pq.Array(urlData.Assignments), urlData.Plain()).Scan(&alreadyExistedShortURL)
 	if err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			return fmt.Errorf("failed to insert URL: %w", err)
@@ -132,9 +579,14 @@ func (m *Manager) PutBatch(ctx context.Context, batchData []types.URLData) error
 		return err
 	}
 	for _, b := range batchData {
+		options, err := encodeOptions(b.Options)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
 		_, err = tx.ExecContext(ctx,
-			"INSERT INTO shortener (short_url, original_url, user_id) VALUES ($1, $2, $3)",
-			b.ShortURL, b.OriginalURL, b.UserID)
+			"INSERT INTO shortener (short_url, original_url, user_id, created_at, expires_at, clicks_left, password_hash, options, variant_assignments, is_plain) VALUES ($1, $2, $3, COALESCE($4, now()), $5, $6, $7, $8, $9, $10)",
+			b.ShortURL, b.OriginalURL, b.UserID, b.CreatedAt, b.ExpiresAt, b.ClicksLeft, b.PasswordHash, options, pq.Array(b.Assignments), b.Plain())
 		if err != nil {
 			tx.Rollback()
 			return err
@@ -196,32 +648,75 @@ func (m *Manager) Close(ctx context.Context) error {
 	return m.db.Close()
 }
 
-// createShortenerTable creates the shortener table if it does not exist.
+// createShortenerTable creates the shortener table if it does not exist
+// and adds columns introduced after the table was first created.
 func (m *Manager) createShortenerTable() error {
-	query := `
+	queries := []string{`
 	CREATE TABLE IF NOT EXISTS shortener (
 		user_id VARCHAR(255) NOT NULL,
 		short_url VARCHAR(255) NOT NULL UNIQUE,
-		original_url TEXT NOT NULL UNIQUE,
+		original_url TEXT NOT NULL,
 	    is_deleted BOOLEAN DEFAULT FALSE
-	);`
+	);`,
+		`ALTER TABLE shortener ADD COLUMN IF NOT EXISTS is_flagged BOOLEAN NOT NULL DEFAULT FALSE;`,
+		`ALTER TABLE shortener ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();`,
+		`ALTER TABLE shortener ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;`,
+		`ALTER TABLE shortener ADD COLUMN IF NOT EXISTS clicks_left BIGINT;`,
+		`ALTER TABLE shortener ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE shortener ADD COLUMN IF NOT EXISTS options JSONB;`,
+		`ALTER TABLE shortener ADD COLUMN IF NOT EXISTS variant_assignments BIGINT[];`,
+		// Only plain links are deduplicated by original URL, see types.URLData.Plain
+		`ALTER TABLE shortener ADD COLUMN IF NOT EXISTS is_plain BOOLEAN NOT NULL DEFAULT TRUE;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS shortener_plain_original_url_idx ON shortener (original_url) WHERE is_plain;`,
+		`ALTER TABLE shortener DROP CONSTRAINT IF EXISTS shortener_original_url_key;`,
+		`CREATE INDEX IF NOT EXISTS shortener_expires_at_idx ON shortener (expires_at) WHERE expires_at IS NOT NULL;`,
+		`CREATE TABLE IF NOT EXISTS shortener_history (
+		short_url VARCHAR(255) NOT NULL REFERENCES shortener (short_url) ON DELETE CASCADE,
+		version INTEGER NOT NULL,
+		original_url TEXT NOT NULL,
+		options JSONB,
+		changed_at TIMESTAMPTZ NOT NULL,
+		changed_by VARCHAR(255) NOT NULL,
+		PRIMARY KEY (short_url, version)
+	);`,
+		`CREATE TABLE IF NOT EXISTS clicks (
+		id BIGSERIAL PRIMARY KEY,
+		short_url VARCHAR(255) NOT NULL,
+		clicked_at TIMESTAMPTZ NOT NULL,
+		referrer TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip_hash VARCHAR(64) NOT NULL DEFAULT '',
+		language TEXT NOT NULL DEFAULT ''
+	);`,
+		`CREATE INDEX IF NOT EXISTS clicks_short_url_clicked_at_idx ON clicks (short_url, clicked_at);`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS country VARCHAR(8) NOT NULL DEFAULT '';`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;`,
+		`CREATE TABLE IF NOT EXISTS visitor_sketches (
+		short_url VARCHAR(255) NOT NULL,
+		day TIMESTAMPTZ NOT NULL,
+		sketch BYTEA,
+		PRIMARY KEY (short_url, day)
+	);`,
+	}
 
-	_, err := m.db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("failed to create table: %w", err)
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
 	}
 	return nil
 }
 
 // preparePutStatement prepares an SQL statement for inserting or retrieving a short URL.
+// A plain link of the same original URL is returned instead of inserting another plain link.
 func preparePutStatement(db *sql.DB) (*sql.Stmt, error) {
 	stmt, err := db.Prepare(`
         WITH ins AS (
-            INSERT INTO shortener (short_url, original_url, user_id)
-            VALUES ($1, $2, $3)
-            ON CONFLICT (original_url) DO NOTHING
+            INSERT INTO shortener (short_url, original_url, user_id, created_at, expires_at, clicks_left, password_hash, options, variant_assignments, is_plain)
+            VALUES ($1, $2, $3, COALESCE($4, now()), $5, $6, $7, $8, $9, $10)
+            ON CONFLICT (original_url) WHERE is_plain DO NOTHING
         )
-        SELECT short_url FROM shortener WHERE original_url = $2;
+        SELECT short_url FROM shortener WHERE original_url = $2 AND is_plain AND $10;
     `)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare query: %w", err)
@@ -229,19 +724,45 @@ func preparePutStatement(db *sql.DB) (*sql.Stmt, error) {
 	return stmt, nil
 }
 
-// GetStats возвращает количество сокращённых URL и количество уникальных пользователей.
-func (m *Manager) GetStats() (types.Stats, error) {
-	var stats types.Stats
-
-	err := m.db.QueryRow("SELECT COUNT(*) FROM shortener WHERE is_deleted = FALSE").Scan(&stats.Urls)
+// ForEachURL calls fn for every stored URL record.
+func (m *Manager) ForEachURL(ctx context.Context, fn func(types.URLData) error) error {
+	rows, err := m.db.QueryContext(ctx, "SELECT "+urlColumns+" FROM shortener")
 	if err != nil {
-		return stats, fmt.Errorf("failed to get total URLs: %w", err)
+		return fmt.Errorf("failed to query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		urlData, err := scanURL(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err = fn(urlData); err != nil {
+			return err
+		}
 	}
 
-	err = m.db.QueryRow("SELECT COUNT(DISTINCT user_id) FROM shortener WHERE is_deleted = FALSE").Scan(&stats.Users)
+	return rows.Err()
+}
+
+// SetFlagged marks the given short URLs as pointing to a malicious destination.
+func (m *Manager) SetFlagged(ctx context.Context, shortURLs []string) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE shortener SET is_flagged = TRUE WHERE short_url = ANY($1)", pq.Array(shortURLs))
 	if err != nil {
-		return stats, fmt.Errorf("failed to get total users: %w", err)
+		return fmt.Errorf("failed to flag URLs: %w", err)
 	}
+	return nil
+}
 
-	return stats, nil
+// DeleteExpired removes URLs that expired before the given moment.
+func (m *Manager) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	res, err := m.db.ExecContext(ctx, "DELETE FROM shortener WHERE expires_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired URLs: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted URLs: %w", err)
+	}
+	return int(n), nil
 }
@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// createRevocationTable creates the table of revoked tokens.
+func (m *Manager) createRevocationTable() error {
+	_, err := m.db.Exec(`
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		token_id VARCHAR(64) PRIMARY KEY,
+		revoked_until TIMESTAMPTZ NOT NULL
+	);`)
+	if err != nil {
+		return fmt.Errorf("failed to create revoked tokens table: %w", err)
+	}
+	return nil
+}
+
+// RevokeToken records the revocation of the session with the given token ID until the given moment.
+// Revocations that have lapsed are removed.
+func (m *Manager) RevokeToken(ctx context.Context, tokenID string, until time.Time) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE revoked_until <= $1", time.Now()); err != nil {
+		return fmt.Errorf("failed to delete lapsed revocations: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (token_id, revoked_until) VALUES ($1, $2)
+		ON CONFLICT (token_id) DO UPDATE SET revoked_until = GREATEST(revoked_tokens.revoked_until, EXCLUDED.revoked_until)`,
+		tokenID, until)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RevokedTokens returns the revocations that have not lapsed.
+func (m *Manager) RevokedTokens(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT token_id, revoked_until FROM revoked_tokens WHERE revoked_until > $1", time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revoked tokens: %w", err)
+	}
+	defer rows.Close()
+
+	revoked := make(map[string]time.Time)
+	for rows.Next() {
+		var (
+			tokenID string
+			until   time.Time
+		)
+		if err = rows.Scan(&tokenID, &until); err != nil {
+			return nil, fmt.Errorf("failed to scan revoked token: %w", err)
+		}
+		revoked[tokenID] = until
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read revoked tokens: %w", err)
+	}
+	return revoked, nil
+}
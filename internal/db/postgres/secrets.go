@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+)
+
+// createSecretsTable creates the table of secrets generated by the service.
+func (m *Manager) createSecretsTable() error {
+	_, err := m.db.Exec(`
+	CREATE TABLE IF NOT EXISTS secrets (
+		name VARCHAR(64) PRIMARY KEY,
+		value TEXT NOT NULL
+	);`)
+	if err != nil {
+		return fmt.Errorf("failed to create secrets table: %w", err)
+	}
+	return nil
+}
+
+// LoadOrStoreSecret returns the secret stored under name, storing value under it first if there is none.
+// Instances starting at the same time all get the value stored by the first of them.
+func (m *Manager) LoadOrStoreSecret(ctx context.Context, name, value string) (string, error) {
+	if _, err := m.db.ExecContext(ctx,
+		"INSERT INTO secrets (name, value) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING", name, value); err != nil {
+		return "", fmt.Errorf("failed to store secret: %w", err)
+	}
+
+	var stored string
+	if err := m.db.QueryRowContext(ctx, "SELECT value FROM secrets WHERE name = $1", name).Scan(&stored); err != nil {
+		return "", fmt.Errorf("failed to load secret: %w", err)
+	}
+	return stored, nil
+}
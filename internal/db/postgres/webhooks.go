@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// createWebhookTables creates the tables of webhook subscriptions and their deliveries.
+// Payloads are stored as bytes since the signature covers their exact encoding.
+func (m *Manager) createWebhookTables() error {
+	queries := []string{`
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id VARCHAR(64) PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		url TEXT NOT NULL,
+		events TEXT[] NOT NULL DEFAULT '{}',
+		click_thresholds BIGINT[] NOT NULL DEFAULT '{}',
+		secret TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	);`,
+		`CREATE INDEX IF NOT EXISTS webhooks_user_id_idx ON webhooks (user_id);`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id VARCHAR(64) PRIMARY KEY,
+		webhook_id VARCHAR(64) NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+		user_id VARCHAR(255) NOT NULL,
+		event VARCHAR(64) NOT NULL,
+		payload BYTEA NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_code INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		next_attempt TIMESTAMPTZ
+	);`,
+		`CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS webhook_deliveries_status_idx ON webhook_deliveries (status, next_attempt);`,
+	}
+
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to create webhook tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// PutWebhook stores a new webhook subscription.
+func (m *Manager) PutWebhook(ctx context.Context, hook types.Webhook) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO webhooks (id, user_id, url, events, click_thresholds, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		hook.ID, hook.UserID, hook.URL, pq.Array(nonNil(hook.Events)), pq.Array(nonNil(hook.ClickThresholds)), hook.Secret, hook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook: %w", err)
+	}
+	return nil
+}
+
+// nonNil returns an empty slice for nil, which the NOT NULL array columns require.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// GetWebhooks returns the webhook subscriptions of userID, oldest first.
+func (m *Manager) GetWebhooks(ctx context.Context, userID string) ([]types.Webhook, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT id, user_id, url, events, click_thresholds, secret, created_at
+		FROM webhooks WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var hooks []types.Webhook
+	for rows.Next() {
+		var hook types.Webhook
+		var thresholds pq.Int64Array
+		if err := rows.Scan(&hook.ID, &hook.UserID, &hook.URL, (*pq.StringArray)(&hook.Events), &thresholds, &hook.Secret, &hook.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		if len(hook.Events) == 0 {
+			hook.Events = nil
+		}
+		if len(thresholds) > 0 {
+			hook.ClickThresholds = thresholds
+		}
+		hooks = append(hooks, hook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return hooks, nil
+}
+
+// DeleteWebhook removes a webhook subscription of userID; its deliveries are removed by the foreign key.
+func (m *Manager) DeleteWebhook(ctx context.Context, id, userID string) error {
+	res, err := m.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	return nil
+}
+
+// PutDelivery stores a delivery of a webhook event, replacing the one with the same ID.
+func (m *Manager) PutDelivery(ctx context.Context, d types.Delivery) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (id, webhook_id, user_id, event, payload, status, attempts, response_code, error, created_at, updated_at, next_attempt)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, attempts = EXCLUDED.attempts,
+			response_code = EXCLUDED.response_code, error = EXCLUDED.error,
+			updated_at = EXCLUDED.updated_at, next_attempt = EXCLUDED.next_attempt`,
+		d.ID, d.WebhookID, d.UserID, d.Event, []byte(d.Payload), d.Status, d.Attempts, d.ResponseCode, d.Error,
+		d.CreatedAt, d.UpdatedAt, d.NextAttempt)
+	if err != nil {
+		return fmt.Errorf("failed to store webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// GetDeliveries returns the deliveries of a webhook in the given status, newest first.
+func (m *Manager) GetDeliveries(ctx context.Context, webhookID, status string) ([]types.Delivery, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT id, webhook_id, user_id, event, payload, status, attempts, response_code, error, created_at, updated_at, next_attempt
+		FROM webhook_deliveries WHERE ($1 = '' OR webhook_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC`, webhookID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []types.Delivery
+	for rows.Next() {
+		var d types.Delivery
+		var payload []byte
+		var next sql.NullTime
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.UserID, &d.Event, &payload, &d.Status, &d.Attempts, &d.ResponseCode, &d.Error,
+			&d.CreatedAt, &d.UpdatedAt, &next); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.Payload = payload
+		d.NextAttempt = nullTimePtr(next)
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/auth"
+	"go.uber.org/zap"
+)
+
+// SyncRevocations loads the revoked sessions from storage into the auth manager.
+func SyncRevocations(ctx context.Context, storage ShortenerStorage, authManager *auth.Manager) error {
+	revoked, err := storage.RevokedTokens(ctx)
+	if err != nil {
+		return err
+	}
+	authManager.SyncRevoked(revoked)
+	return nil
+}
+
+// WatchRevocations periodically loads the revoked sessions from storage into the auth manager,
+// running every interval until ctx is done, so that sessions revoked through other instances
+// are rejected within an interval. Requests are checked against the loaded set only.
+func WatchRevocations(ctx context.Context, storage ShortenerStorage, authManager *auth.Manager, interval time.Duration, logger *zap.SugaredLogger) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := SyncRevocations(ctx, storage, authManager); err != nil {
+				logger.Errorw("failed to load revoked tokens", "error", err)
+			}
+		}
+	}
+}
@@ -2,7 +2,9 @@ package db
 
 import (
 	"context"
+	"time"
 
+	"github.com/jayjaytrn/URLShortener/internal/hll"
 	"github.com/jayjaytrn/URLShortener/internal/types"
 )
 
@@ -11,6 +13,29 @@ type ShortenerStorage interface {
 	// GetOriginal retrieves the original URL corresponding to the given short URL.
 	GetOriginal(shortURL string) (string, error)
 
+	// Get retrieves the full record for the given short URL.
+	Get(shortURL string) (types.URLData, error)
+
+	// UseClick consumes one redirect of a click-limited URL and returns the updated record.
+	// It fails like Get when the URL is unavailable or has no clicks left.
+	UseClick(ctx context.Context, shortURL string) (types.URLData, error)
+
+	// UpdateURL changes the URL record owned by userID by applying update to a copy of it
+	// and stores the result atomically. It returns the updated record.
+	// Changes of the destination or the options are recorded as revisions made by userID.
+	UpdateURL(ctx context.Context, shortURL, userID string, update func(*types.URLData) error) (types.URLData, error)
+
+	// GetUserURL returns the URL record owned by userID, including expired and used up ones.
+	// Deleted records and records of other users are reported as "URL not found".
+	GetUserURL(ctx context.Context, shortURL, userID string) (types.URLData, error)
+
+	// URLHistory returns the revisions of the URL record owned by userID, oldest first.
+	// A URL that was never changed has a single revision describing its creation.
+	URLHistory(ctx context.Context, shortURL, userID string) ([]types.Revision, error)
+
+	// CountAssignment increments the number of visitors assigned to a split variant of the URL.
+	CountAssignment(ctx context.Context, shortURL string, variant int) error
+
 	// Put adds a new URL record to the storage. Returns an error if the insertion fails.
 	Put(urlData types.URLData) error
 
@@ -35,6 +60,61 @@ type ShortenerStorage interface {
 	// BatchDelete marks a batch of URLs as deleted for a given user.
 	BatchDelete(urlChannel chan string, userID string)
 
+	// ForEachURL calls fn for every stored URL record, stopping at the first error.
+	ForEachURL(ctx context.Context, fn func(types.URLData) error) error
+
+	// SetFlagged marks the given short URLs as pointing to a malicious destination.
+	SetFlagged(ctx context.Context, shortURLs []string) error
+
+	// PutClicks stores a batch of click events.
+	PutClicks(ctx context.Context, events []types.ClickEvent) error
+
+	// ForEachClick calls fn for every click event of the short URL recorded in [from, to),
+	// stopping at the first error.
+	ForEachClick(ctx context.Context, shortURL string, from, to time.Time, fn func(types.ClickEvent) error) error
+
+	// ForEachClickIn calls fn for every click event of the given short URLs recorded in [from, to),
+	// oldest first, stopping at the first error. Nil short URLs select the events of every link.
+	ForEachClickIn(ctx context.Context, shortURLs []string, from, to time.Time, fn func(types.ClickEvent) error) error
+
+	// MergeVisitors merges daily visitor sketches into the stored sketches of the same link and day.
+	MergeVisitors(ctx context.Context, sketches []types.VisitorSketch) error
+
+	// Visitors returns the merge of the visitor sketches of the short URL for the days starting
+	// in [VisitorDay(from), to). An empty short URL selects the sketches of all links.
+	Visitors(ctx context.Context, shortURL string, from, to time.Time) (*hll.Sketch, error)
+
+	// PutWebhook stores a new webhook subscription.
+	PutWebhook(ctx context.Context, hook types.Webhook) error
+
+	// GetWebhooks returns the webhook subscriptions of userID, oldest first.
+	GetWebhooks(ctx context.Context, userID string) ([]types.Webhook, error)
+
+	// DeleteWebhook removes a webhook subscription of userID together with its deliveries.
+	// Unknown webhooks and webhooks of other users are reported as "webhook not found".
+	DeleteWebhook(ctx context.Context, id, userID string) error
+
+	// PutDelivery stores a delivery of a webhook event, replacing the one with the same ID.
+	PutDelivery(ctx context.Context, delivery types.Delivery) error
+
+	// GetDeliveries returns the deliveries of a webhook in the given status, newest first.
+	// An empty webhook ID selects the deliveries of all webhooks and an empty status selects every status.
+	GetDeliveries(ctx context.Context, webhookID, status string) ([]types.Delivery, error)
+
+	// RevokeToken records the revocation of the session with the given token ID. The record is
+	// kept until the given moment, by which every token of the session has expired.
+	RevokeToken(ctx context.Context, tokenID string, until time.Time) error
+
+	// RevokedTokens returns the revocations that have not lapsed, the moment each lapses by token ID.
+	RevokedTokens(ctx context.Context) (map[string]time.Time, error)
+
+	// LoadOrStoreSecret returns the secret stored under name, storing value under it first if there
+	// is none, so that a secret generated by one run is used by the following ones.
+	LoadOrStoreSecret(ctx context.Context, name, value string) (string, error)
+
+	// DeleteExpired removes URLs that expired before the given moment and returns how many were removed.
+	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+
 	// GetStats возвращает количество сокращенных URL и количество пользователей
 	GetStats() (types.Stats, error)
 }
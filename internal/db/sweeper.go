@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SweepExpired periodically removes links that expired more than retention ago,
+// running every interval until ctx is done. Expired links stay in storage for the
+// retention period so that they keep answering with 410 Gone instead of 404.
+func SweepExpired(ctx context.Context, storage ShortenerStorage, interval, retention time.Duration, logger *zap.SugaredLogger) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := storage.DeleteExpired(ctx, time.Now().Add(-retention))
+			if err != nil {
+				logger.Errorw("failed to delete expired URLs", "error", err)
+				continue
+			}
+			if n > 0 {
+				logger.Infow("expired URLs removed", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package export writes click events and link aggregates as CSV or NDJSON, one row at a time,
+// so that exports of any size can be streamed.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// Export formats.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Row is a line of an export.
+type Row interface {
+	// Record returns the CSV fields of the row, in the order of the header of its type.
+	Record() []string
+}
+
+// ParseFormat validates a format name. An empty name selects CSV.
+func ParseFormat(format string) (string, error) {
+	switch format = strings.ToLower(format); format {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatNDJSON:
+		return format, nil
+	}
+	return "", errors.New("format must be csv or ndjson")
+}
+
+// ContentType returns the media type of a format.
+func ContentType(format string) string {
+	if format == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// Writer writes rows in a format. CSV output starts with a header line.
+type Writer struct {
+	header     []string
+	headerDone bool
+	csv        *csv.Writer
+	json       *json.Encoder
+}
+
+// NewWriter creates a writer of rows to w. header names the CSV columns.
+func NewWriter(w io.Writer, format string, header []string) *Writer {
+	if format == FormatNDJSON {
+		return &Writer{json: json.NewEncoder(w)}
+	}
+	return &Writer{header: header, csv: csv.NewWriter(w)}
+}
+
+// Write writes a row.
+func (w *Writer) Write(row Row) error {
+	if w.json != nil {
+		return w.json.Encode(row)
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.csv.Write(row.Record())
+}
+
+// Flush writes buffered CSV data, starting with the header when no row was written.
+func (w *Writer) Flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// writeHeader writes the CSV header line once.
+func (w *Writer) writeHeader() error {
+	if w.headerDone {
+		return nil
+	}
+	w.headerDone = true
+	return w.csv.Write(w.header)
+}
+
+// ClickHeader names the CSV columns of ClickRow.
+var ClickHeader = []string{"short_url", "time", "referrer", "user_agent", "ip_hash", "language", "country", "bot"}
+
+// ClickRow is a click event in an export.
+type ClickRow types.ClickEvent
+
+// Record returns the CSV fields of the click event.
+func (r ClickRow) Record() []string {
+	return []string{
+		r.ShortURL, r.Time.UTC().Format(time.RFC3339), r.Referrer, r.UserAgent,
+		r.IPHash, r.Language, r.Country, strconv.FormatBool(r.Bot),
+	}
+}
+
+// MarshalJSON encodes the click event with every field, empty ones included.
+func (r ClickRow) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ShortURL  string    `json:"short_url"`
+		Time      time.Time `json:"time"`
+		Referrer  string    `json:"referrer"`
+		UserAgent string    `json:"user_agent"`
+		IPHash    string    `json:"ip_hash"`
+		Language  string    `json:"language"`
+		Country   string    `json:"country"`
+		Bot       bool      `json:"bot"`
+	}{r.ShortURL, r.Time.UTC(), r.Referrer, r.UserAgent, r.IPHash, r.Language, r.Country, r.Bot})
+}
+
+// LinkHeader names the CSV columns of LinkRow.
+var LinkHeader = []string{
+	"short_url", "original_url", "user_id", "created_at", "deleted",
+	"clicks", "bot_clicks", "unique_visitors", "first_click", "last_click",
+}
+
+// LinkRow aggregates the clicks of a link over the range of an export.
+type LinkRow struct {
+	ShortURL       string     `json:"short_url"`
+	OriginalURL    string     `json:"original_url"`
+	UserID         string     `json:"user_id"`
+	CreatedAt      *time.Time `json:"created_at"`
+	Deleted        bool       `json:"deleted"`
+	Clicks         int64      `json:"clicks"`          // Clicks is the number of visits of people
+	BotClicks      int64      `json:"bot_clicks"`      // BotClicks is the number of visits of bots
+	UniqueVisitors uint64     `json:"unique_visitors"` // UniqueVisitors estimates the distinct visitors of the days overlapping the range
+	FirstClick     *time.Time `json:"first_click"`
+	LastClick      *time.Time `json:"last_click"`
+}
+
+// NewLinkRow starts the aggregate of a link.
+func NewLinkRow(urlData types.URLData) LinkRow {
+	return LinkRow{
+		ShortURL:    urlData.ShortURL,
+		OriginalURL: urlData.OriginalURL,
+		UserID:      urlData.UserID,
+		CreatedAt:   urlData.CreatedAt,
+		Deleted:     urlData.DeletedFlag,
+	}
+}
+
+// Add counts a click event of the link.
+func (r *LinkRow) Add(e types.ClickEvent) error {
+	if e.Bot {
+		r.BotClicks++
+	} else {
+		r.Clicks++
+	}
+	if r.FirstClick == nil || e.Time.Before(*r.FirstClick) {
+		first := e.Time.UTC()
+		r.FirstClick = &first
+	}
+	if r.LastClick == nil || e.Time.After(*r.LastClick) {
+		last := e.Time.UTC()
+		r.LastClick = &last
+	}
+	return nil
+}
+
+// Record returns the CSV fields of the link aggregate.
+func (r LinkRow) Record() []string {
+	return []string{
+		r.ShortURL, r.OriginalURL, r.UserID, formatTime(r.CreatedAt), strconv.FormatBool(r.Deleted),
+		strconv.FormatInt(r.Clicks, 10), strconv.FormatInt(r.BotClicks, 10),
+		strconv.FormatUint(r.UniqueVisitors, 10), formatTime(r.FirstClick), formatTime(r.LastClick),
+	}
+}
+
+// formatTime formats an optional time as RFC 3339, or as an empty field when it is unset.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,95 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		expected string
+		wantErr  bool
+	}{
+		{name: "Default", format: "", expected: FormatCSV},
+		{name: "CSV", format: "CSV", expected: FormatCSV},
+		{name: "NDJSON", format: "ndjson", expected: FormatNDJSON},
+		{name: "Unknown", format: "xlsx", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := ParseFormat(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if format != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, format)
+			}
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	row := LinkRow{ShortURL: "abcd1234", OriginalURL: "https://example.com/?a=1,2", UserID: "owner"}
+	row.Add(types.ClickEvent{ShortURL: "abcd1234", Time: at})
+	row.Add(types.ClickEvent{ShortURL: "abcd1234", Time: at.Add(time.Hour), Bot: true})
+
+	tests := []struct {
+		name     string
+		format   string
+		rows     []Row
+		expected string
+	}{
+		{name: "Empty CSV has a header", format: FormatCSV,
+			expected: "short_url,time,referrer,user_agent,ip_hash,language,country,bot\n"},
+		{name: "CSV clicks", format: FormatCSV, rows: []Row{ClickRow{ShortURL: "abcd1234", Time: at, Country: "DE"}},
+			expected: "short_url,time,referrer,user_agent,ip_hash,language,country,bot\n" +
+				"abcd1234,2024-05-01T12:00:00Z,,,,,DE,false\n"},
+		{name: "NDJSON clicks", format: FormatNDJSON, rows: []Row{ClickRow{ShortURL: "abcd1234", Time: at}},
+			expected: `{"short_url":"abcd1234","time":"2024-05-01T12:00:00Z","referrer":"","user_agent":"","ip_hash":"","language":"","country":"","bot":false}` + "\n"},
+		{name: "CSV links", format: FormatCSV, rows: []Row{row},
+			expected: "short_url,original_url,user_id,created_at,deleted,clicks,bot_clicks,unique_visitors,first_click,last_click\n" +
+				`abcd1234,"https://example.com/?a=1,2",owner,,false,1,1,0,2024-05-01T12:00:00Z,2024-05-01T13:00:00Z` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := ClickHeader
+			if len(tt.rows) > 0 {
+				if _, link := tt.rows[0].(LinkRow); link {
+					header = LinkHeader
+				}
+			}
+
+			var buf bytes.Buffer
+			w := NewWriter(&buf, tt.format, header)
+			for _, r := range tt.rows {
+				if err := w.Write(r); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestLinkRowJSON(t *testing.T) {
+	b, _ := json.Marshal(LinkRow{ShortURL: "abcd1234", Clicks: 2})
+	var decoded map[string]any
+	json.Unmarshal(b, &decoded)
+	if decoded["clicks"] != float64(2) || decoded["first_click"] != nil {
+		t.Errorf("unexpected JSON %s", b)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/analytics"
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/middleware"
+)
+
+// statsPath is the suffix of the endpoint that reports the visits of a URL of the user.
+const statsPath = "/stats"
+
+// URLStats reports the visits of a shortened URL owned by the user. The range and the bucket
+// size of the time series are read from the "from", "to" (RFC 3339) and "interval" query parameters.
+// Visits of bots are counted only with "include_bots=true".
+func (h *Handler) URLStats(res http.ResponseWriter, req *http.Request) {
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(res, "internal server error", http.StatusBadRequest)
+		return
+	}
+
+	if req.Context().Value(middleware.CookieExistedKey) == false {
+		http.Error(res, "Unauthorized - cookie was created by request", http.StatusUnauthorized)
+		return
+	}
+
+	query := req.URL.Query()
+	q, err := analytics.ParseQuery(query.Get("from"), query.Get("to"), query.Get("interval"), time.Now())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := query.Get("include_bots"); v != "" {
+		if q.IncludeBots, err = strconv.ParseBool(v); err != nil {
+			http.Error(res, "include_bots must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+
+	shortURL := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, userURLPath), statsPath)
+	report, err := urlReport(req.Context(), h.Storage, shortURL, userID, q)
+	if err != nil {
+		if strings.Contains(err.Error(), "URL not found") {
+			http.Error(res, "URL not found", http.StatusNotFound)
+			return
+		}
+		http.Error(res, "error when trying to get URL stats", http.StatusInternalServerError)
+		return
+	}
+
+	br, err := json.Marshal(report)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	res.Write(br)
+}
+
+// urlReport aggregates the click events and the visitor sketches of a URL owned by userID.
+// Links of other users are reported as not found.
+func urlReport(ctx context.Context, storage db.ShortenerStorage, shortURL, userID string, q analytics.Query) (analytics.Report, error) {
+	if _, err := storage.GetUserURL(ctx, shortURL, userID); err != nil {
+		return analytics.Report{}, err
+	}
+
+	aggregator := analytics.NewAggregator(q)
+	if err := storage.ForEachClick(ctx, shortURL, q.From, q.To, aggregator.Add); err != nil {
+		return analytics.Report{}, err
+	}
+	visitors, err := storage.Visitors(ctx, shortURL, q.From, q.To)
+	if err != nil {
+		return analytics.Report{}, err
+	}
+	aggregator.AddVisitors(visitors)
+	return aggregator.Report(), nil
+}
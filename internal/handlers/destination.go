@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+	"github.com/jayjaytrn/URLShortener/internal/threat"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+	"github.com/jayjaytrn/URLShortener/internal/urlshort"
+)
+
+// destinationChecks groups the checks applied to every URL before it is shortened.
+type destinationChecks struct {
+	policy  *policy.Engine
+	threats *threat.List
+	loops   *urlshort.LoopGuard
+}
+
+// check resolves links to the service and to known shorteners for a normalised URL, then applies
+// the destination policy and the threat list to the result. It returns the URL that should be stored.
+// Rejections are returned as *policy.Violation.
+func (c destinationChecks) check(ctx context.Context, url string) (string, error) {
+	url, err := c.loops.Resolve(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	if err = c.policy.Check(url); err != nil {
+		return "", err
+	}
+	if c.threats.Listed(url) {
+		return "", &policy.Violation{Reason: policy.ReasonMaliciousURL, Detail: "destination is on the threat list"}
+	}
+	return url, nil
+}
+
+// destination normalises a raw destination URL and applies check to it.
+func (c destinationChecks) destination(ctx context.Context, cfg *config.Config, raw string) (string, error) {
+	url, err := urlshort.NormalizeURL(raw, cfg)
+	if err != nil {
+		return "", &policy.Violation{Reason: policy.ReasonInvalidURL, Detail: err.Error()}
+	}
+	return c.check(ctx, url)
+}
+
+// checkOptions normalises the rule and variant destinations of link options in place
+// and applies check to each of them.
+func (c destinationChecks) checkOptions(ctx context.Context, cfg *config.Config, opts *types.LinkOptions) error {
+	checkOne := func(what string, i int, raw string) (string, error) {
+		url, err := urlshort.NormalizeURL(raw, cfg)
+		if err != nil {
+			return "", &policy.Violation{Reason: policy.ReasonInvalidURL, Detail: fmt.Sprintf("%s %d: %v", what, i, err)}
+		}
+		return c.check(ctx, url)
+	}
+
+	var err error
+	for i := range opts.Rules {
+		if opts.Rules[i].URL, err = checkOne("rule", i, opts.Rules[i].URL); err != nil {
+			return err
+		}
+	}
+	for i := range opts.Variants {
+		if opts.Variants[i].URL, err = checkOne("variant", i, opts.Variants[i].URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// destinations returns the destination checks configured for the HTTP handler.
+func (h *Handler) destinations() destinationChecks {
+	return destinationChecks{policy: h.Policy, threats: h.Threats, loops: h.LoopGuard}
+}
+
+// destinations returns the destination checks configured for the gRPC service.
+func (s *URLShortener) destinations() destinationChecks {
+	return destinationChecks{policy: s.Policy, threats: s.Threats, loops: s.LoopGuard}
+}
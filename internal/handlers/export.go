@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/export"
+	"github.com/jayjaytrn/URLShortener/internal/middleware"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+	"github.com/jayjaytrn/URLShortener/logging"
+)
+
+// exportFlushRows is the number of rows after which a streamed export is sent to the client.
+const exportFlushRows = 1000
+
+// Kinds of export.
+const (
+	exportClicks = "clicks" // raw click events
+	exportLinks  = "links"  // one aggregate per link
+)
+
+// ExportClicks streams the click events of the URLs of the user.
+func (h *Handler) ExportClicks(res http.ResponseWriter, req *http.Request) {
+	h.exportUser(res, req, exportClicks)
+}
+
+// ExportLinks streams the click aggregates of the URLs of the user.
+func (h *Handler) ExportLinks(res http.ResponseWriter, req *http.Request) {
+	h.exportUser(res, req, exportLinks)
+}
+
+// AdminExportClicks streams the click events of every URL to clients of the trusted subnet.
+func (h *Handler) AdminExportClicks(res http.ResponseWriter, req *http.Request) {
+	if h.trusted(res, req) {
+		h.export(res, req, exportClicks, h.allLinks)
+	}
+}
+
+// AdminExportLinks streams the click aggregates of every URL to clients of the trusted subnet.
+func (h *Handler) AdminExportLinks(res http.ResponseWriter, req *http.Request) {
+	if h.trusted(res, req) {
+		h.export(res, req, exportLinks, h.allLinks)
+	}
+}
+
+// exportSelection holds the URLs an export covers.
+type exportSelection struct {
+	every bool            // every selects all URLs, so that click events are read without a list of links
+	urls  []types.URLData // urls are the selected URLs; exports of every click event do not load them
+}
+
+// allLinks selects every URL. Their records are only loaded for aggregates, since click
+// events of every link are read without a list of links.
+func (h *Handler) allLinks(ctx context.Context, kind string) (exportSelection, error) {
+	selection := exportSelection{every: true}
+	if kind != exportLinks {
+		return selection, nil
+	}
+	err := h.Storage.ForEachURL(ctx, func(urlData types.URLData) error {
+		selection.urls = append(selection.urls, urlData)
+		return nil
+	})
+	return selection, err
+}
+
+// exportUser streams an export of the URLs of the user that are not deleted.
+func (h *Handler) exportUser(res http.ResponseWriter, req *http.Request, kind string) {
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(res, "internal server error", http.StatusBadRequest)
+		return
+	}
+
+	if req.Context().Value(middleware.CookieExistedKey) == false {
+		http.Error(res, "Unauthorized - cookie was created by request", http.StatusUnauthorized)
+		return
+	}
+
+	h.export(res, req, kind, func(context.Context, string) (exportSelection, error) {
+		urls, err := h.Storage.GetURLsByUserID(userID)
+		if err != nil && !strings.Contains(err.Error(), "no URLs found for userID") {
+			return exportSelection{}, err
+		}
+
+		var selection exportSelection
+		for _, urlData := range urls {
+			if urlData.DeletedFlag {
+				continue
+			}
+			// User listings hold full short URLs, while click events hold short codes
+			urlData.ShortURL = strings.TrimPrefix(urlData.ShortURL, h.Config.BaseURL+"/")
+			selection.urls = append(selection.urls, urlData)
+		}
+		return selection, nil
+	})
+}
+
+// export streams the rows of the URLs selected by sel. The format is read from the
+// "format" query parameter or else from the Accept header, and the range from the
+// "from" and "to" (RFC 3339) query parameters, which default to all time until now.
+// Once the first row is sent the status can no longer change, so later storage
+// errors end the export early and are logged.
+func (h *Handler) export(res http.ResponseWriter, req *http.Request, kind string, sel func(context.Context, string) (exportSelection, error)) {
+	query := req.URL.Query()
+	format, err := export.ParseFormat(exportFormat(req))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, to, err := parseExportRange(query.Get("from"), query.Get("to"), time.Now())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	selection, err := sel(req.Context(), kind)
+	if err != nil {
+		http.Error(res, "failed to load URLs", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", export.ContentType(format))
+	res.Header().Set("Content-Disposition", `attachment; filename="`+kind+"."+format+`"`)
+	res.WriteHeader(http.StatusOK)
+
+	header := export.ClickHeader
+	if kind == exportLinks {
+		header = export.LinkHeader
+	}
+	w := export.NewWriter(res, format, header)
+	flusher, _ := res.(http.Flusher)
+
+	rows := 0
+	write := func(row export.Row) error {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	}
+
+	if err = streamExport(req.Context(), h.Storage, kind, selection, from, to, write); err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		logger := logging.GetSugaredLogger()
+		logger.Errorw("export aborted", "kind", kind, "rows", rows, "error", err)
+		logger.Sync()
+	}
+}
+
+// streamExport passes the rows of an export to write one by one. The click events of the
+// selected URLs are read at once: click rows are written as they are read, oldest first,
+// and link rows once every event has been counted, in the order of the selection.
+func streamExport(ctx context.Context, storage db.ShortenerStorage, kind string, selection exportSelection, from, to time.Time, write func(export.Row) error) error {
+	var shortURLs []string
+	if !selection.every {
+		if len(selection.urls) == 0 {
+			return nil
+		}
+		shortURLs = make([]string, len(selection.urls))
+		for i, urlData := range selection.urls {
+			shortURLs[i] = urlData.ShortURL
+		}
+	}
+
+	if kind == exportClicks {
+		return storage.ForEachClickIn(ctx, shortURLs, from, to, func(e types.ClickEvent) error {
+			return write(export.ClickRow(e))
+		})
+	}
+
+	rows := make([]export.LinkRow, len(selection.urls))
+	index := make(map[string]int, len(selection.urls))
+	for i, urlData := range selection.urls {
+		rows[i] = export.NewLinkRow(urlData)
+		index[urlData.ShortURL] = i
+	}
+	err := storage.ForEachClickIn(ctx, shortURLs, from, to, func(e types.ClickEvent) error {
+		// Events of links created after the selection was loaded are skipped
+		if i, ok := index[e.ShortURL]; ok {
+			return rows[i].Add(e)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		visitors, err := storage.Visitors(ctx, row.ShortURL, from, to)
+		if err != nil {
+			return err
+		}
+		row.UniqueVisitors = visitors.Estimate()
+		if err = write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportFormat returns the format asked for by the "format" query parameter or the Accept header.
+func exportFormat(req *http.Request) string {
+	if format := req.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	accept := req.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, export.ContentType(export.FormatNDJSON)):
+		return export.FormatNDJSON
+	case strings.Contains(accept, export.ContentType(export.FormatCSV)):
+		return export.FormatCSV
+	}
+	return ""
+}
+
+// parseExportRange reads the RFC 3339 bounds of an export. The range is unbounded in the
+// past by default and ends at now.
+func parseExportRange(fromParam, toParam string, now time.Time) (from, to time.Time, err error) {
+	from, to = time.Unix(0, 0).UTC(), now.UTC()
+	if fromParam != "" {
+		if from, err = time.Parse(time.RFC3339, fromParam); err != nil {
+			return from, to, errors.New("from must be an RFC 3339 timestamp")
+		}
+	}
+	if toParam != "" {
+		if to, err = time.Parse(time.RFC3339, toParam); err != nil {
+			return from, to, errors.New("to must be an RFC 3339 timestamp")
+		}
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
@@ -4,19 +4,35 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/analytics"
 	"github.com/jayjaytrn/URLShortener/internal/auth"
+	"github.com/jayjaytrn/URLShortener/internal/blocklist"
+	"github.com/jayjaytrn/URLShortener/internal/clicks"
 	"github.com/jayjaytrn/URLShortener/internal/db"
 	"github.com/jayjaytrn/URLShortener/internal/db/postgres"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+	"github.com/jayjaytrn/URLShortener/internal/qr"
+	"github.com/jayjaytrn/URLShortener/internal/ratelimit"
+	"github.com/jayjaytrn/URLShortener/internal/rules"
+	"github.com/jayjaytrn/URLShortener/internal/threat"
 	"github.com/jayjaytrn/URLShortener/internal/types"
 	"github.com/jayjaytrn/URLShortener/internal/urlshort"
 	"github.com/jayjaytrn/URLShortener/logging"
 	pb "github.com/jayjaytrn/URLShortener/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type URLShortener struct {
@@ -25,43 +41,150 @@ type URLShortener struct {
 	Storage     db.ShortenerStorage
 	Config      *config.Config
 	AuthManager *auth.Manager
+	Blocklist   *blocklist.List
+	Policy      *policy.Engine
+	Threats     *threat.List
+	LoopGuard   *urlshort.LoopGuard
+
+	PasswordLimiter *ratelimit.Limiter
+	Clicks          *clicks.Tracker
 }
 
-func NewURLShortener(s db.ShortenerStorage, authManager *auth.Manager, cfg *config.Config) *URLShortener {
+// NewURLShortener creates the gRPC service with the same dependencies as the HTTP handler h.
+func NewURLShortener(h Handler) *URLShortener {
 	return &URLShortener{
 		UnimplementedURLShortenerServer: pb.UnimplementedURLShortenerServer{},
-		Storage:                         s,
-		AuthManager:                     authManager,
-		Config:                          cfg,
+		Storage:                         h.Storage,
+		AuthManager:                     h.AuthManager,
+		Config:                          h.Config,
+		Blocklist:                       h.Blocklist,
+		Policy:                          h.Policy,
+		Threats:                         h.Threats,
+		LoopGuard:                       h.LoopGuard,
+		PasswordLimiter:                 h.PasswordLimiter,
+		Clicks:                          h.Clicks,
 	}
 }
 
 // URLReturner grpc
+//
+// The call is treated as the visit described by clickRequest, so redirect rules and split
+// variants choose the destination as they do for the HTTP redirect. Calls carry no cookie,
+// so the variant is chosen by the hash of the visitor key alone. The stored UTM parameters
+// are added to the destination; calls have no query or path suffix to forward.
 func (s *URLShortener) URLReturner(ctx context.Context, req *pb.URLReturnerRequest) (*pb.URLReturnerResponse, error) {
 	shortURL := req.ShortUrl[len("/"):]
 	if shortURL == "" {
 		return nil, status.Error(codes.InvalidArgument, "short url is empty")
 	}
 
-	originalURL, err := s.Storage.GetOriginal(shortURL)
+	urlData, err := s.Storage.Get(shortURL)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, unavailableStatus(err)
+	}
+
+	now := time.Now()
+	if err = urlData.Active(now); err != nil {
+		return nil, unavailableStatus(err)
+	}
+
+	visit := clickRequest(ctx)
+	destination, matched := rules.Destination(urlData, visit, now)
+	variant := -1
+	if !matched && urlData.Options != nil && len(urlData.Options.Variants) > 0 {
+		variant = rules.ChooseVariant(urlData.Options.Variants, shortURL+"|"+visitorKey(s.Config, visit))
+		destination = urlData.Options.Variants[variant].URL
 	}
 
+	if !urlData.Flagged && s.Config.ThreatCheckRedirects && s.Threats.Listed(destination) {
+		if err = s.Storage.SetFlagged(ctx, []string{shortURL}); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		urlData.Flagged = true
+	}
+
+	if urlData.Flagged {
+		return nil, rejectionStatus(&policy.Violation{Reason: policy.ReasonMaliciousURL, Detail: "destination is on the threat list"}, "")
+	}
+
+	if urlData.PasswordHash != "" {
+		var addr string
+		if p, ok := peer.FromContext(ctx); ok {
+			addr = p.Addr.String()
+		}
+		err = checkPassword(s.PasswordLimiter, attemptKey(shortURL, addr), urlData.PasswordHash, req.GetPassword())
+		switch {
+		case errors.Is(err, errTooManyAttempts):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		case err != nil:
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+
+	if urlData.ClicksLeft != nil {
+		if urlData, err = s.Storage.UseClick(ctx, shortURL); err != nil {
+			return nil, unavailableStatus(err)
+		}
+	}
+	destination, err = rules.Expand(destination, urlData, nil, "", now)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to build destination URL")
+	}
+
+	if variant >= 0 {
+		countAssignment(ctx, s.Storage, shortURL, variant)
+	}
+	s.Clicks.Record(shortURL, visit, now)
+
 	return &pb.URLReturnerResponse{
-		OriginalUrl: originalURL,
+		OriginalUrl: destination,
 	}, nil
 }
 
+// clickRequest describes a call as the HTTP request of a visit, so that it is recorded like one:
+// the peer address stands for the remote address and the metadata for the headers, such as
+// "user-agent", "referer" and "accept-language".
+func clickRequest(ctx context.Context) *http.Request {
+	req := &http.Request{Method: http.MethodGet, Header: make(http.Header)}
+	if p, ok := peer.FromContext(ctx); ok {
+		req.RemoteAddr = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			// Pseudo-headers and binary values are not headers of a visit
+			if strings.HasPrefix(key, ":") || strings.HasSuffix(key, "-bin") {
+				continue
+			}
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+	}
+	return req
+}
+
 // Shorten grpc
 func (s *URLShortener) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
-	url := req.Url
-	valid := urlshort.ValidateURL(url)
-	if !valid {
+	url, err := urlshort.NormalizeURL(req.Url, s.Config)
+	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "invalid URL format")
 	}
 
-	su, err := urlshort.GenerateShortURL(s.Storage)
+	opts, err := createOptionsFromProto(req.GetOptions())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	now := time.Now()
+	if err = urlshort.ValidateCreateOptions(opts, now); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	url, err = s.destinations().check(ctx, url)
+	if err != nil {
+		return nil, rejectionStatus(err, "")
+	}
+
+	su, err := urlshort.GenerateShortURL(s.Storage, s.Blocklist)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -71,6 +194,9 @@ func (s *URLShortener) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb
 		ShortURL:    su,
 		UserID:      uuid.New().String(),
 	}
+	if err = urlshort.ApplyCreateOptions(&urlData, opts, now); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	err = s.Storage.Put(urlData)
 	if err != nil {
 		var originalExistErr *postgres.OriginalExistError
@@ -110,19 +236,38 @@ func (s *URLShortener) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb
 func (s *URLShortener) ShortenBatch(ctx context.Context, req *pb.ShortenBatchListRequest) (*pb.ShortenBatchListResponse, error) {
 	var urls []types.ShortenBatchRequest
 
+	now := time.Now()
 	for _, r := range req.Urls {
+		opts, err := createOptionsFromProto(r.GetOptions())
+		if err == nil {
+			err = urlshort.ValidateCreateOptions(opts, now)
+		}
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, r.CorrelationId+": "+err.Error())
+		}
 		urls = append(urls, types.ShortenBatchRequest{
 			CorrelationID: r.CorrelationId,
 			OriginalURL:   r.OriginalUrl,
+			CreateOptions: opts,
 		})
 	}
 
-	valid := urlshort.ValidateBatchRequestURLs(urls)
-	if !valid {
+	err := urlshort.NormalizeBatchRequestURLs(urls, s.Config)
+	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "invalid URL format in batch")
 	}
 
-	batchResponse, batchData, err := urlshort.GenerateShortBatch(s.Config, s.Storage, urls, uuid.New().String())
+	// The items share one time limit for following known shorteners
+	budget, cancel := s.LoopGuard.WithBudget(ctx)
+	defer cancel()
+	for i, u := range urls {
+		urls[i].OriginalURL, err = s.destinations().check(budget, u.OriginalURL)
+		if err != nil {
+			return nil, rejectionStatus(err, u.CorrelationID)
+		}
+	}
+
+	batchResponse, batchData, err := urlshort.GenerateShortBatch(s.Config, s.Storage, s.Blocklist, urls, uuid.New().String())
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -208,13 +353,382 @@ func (s *URLShortener) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.Sta
 	}
 
 	// Получаем количество уникальных пользователей и сокращённых URL
-	stats, err := s.Storage.GetStats()
+	stats, err := collectStats(ctx, s.Storage, s.Config, s.Clicks)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	return &pb.StatsResponse{
-		UrlsCount:  int32(stats.Urls),
-		UsersCount: int32(stats.Users),
+	response := &pb.StatsResponse{
+		UrlsCount:     int32(stats.Urls),
+		UsersCount:    int32(stats.Users),
+		Visitors:      int64(stats.Visitors),
+		ActiveCount:   int32(stats.Active),
+		DeletedCount:  int32(stats.Deleted),
+		ExpiredCount:  int32(stats.Expired),
+		Redirects:     stats.Redirects,
+		CreatedPerDay: make([]*pb.DayCount, len(stats.CreatedPerDay)),
+		TopUsers:      make([]*pb.UserCount, len(stats.TopUsers)),
+		Backend: &pb.BackendHealth{
+			Type:      stats.Backend.Type,
+			Healthy:   stats.Backend.Healthy,
+			LatencyMs: stats.Backend.LatencyMS,
+			Error:     stats.Backend.Error,
+		},
+	}
+	for i, d := range stats.CreatedPerDay {
+		response.CreatedPerDay[i] = &pb.DayCount{Day: d.Day.Format(time.DateOnly), Links: int32(d.Links)}
+	}
+	for i, u := range stats.TopUsers {
+		response.TopUsers[i] = &pb.UserCount{UserId: u.UserID, Links: int32(u.Links)}
+	}
+	if q := stats.ClickQueue; q != nil {
+		response.ClickQueue = &pb.ClickQueue{Queued: int32(q.Queued), Written: q.Written, Dropped: q.Dropped, Failed: q.Failed}
+	}
+	return response, nil
+}
+
+// UpdateURL grpc
+func (s *URLShortener) UpdateURL(ctx context.Context, req *pb.UpdateURLRequest) (*pb.UpdateURLResponse, error) {
+	userID, err := s.authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shortURL := strings.TrimPrefix(req.GetShortUrl(), "/")
+	if shortURL == "" {
+		return nil, status.Error(codes.InvalidArgument, "short url is empty")
+	}
+
+	var destination string
+	if req.Url != nil {
+		if destination, err = s.destinations().destination(ctx, s.Config, req.GetUrl()); err != nil {
+			return nil, rejectionStatus(err, "")
+		}
+	}
+
+	urlData, err := s.Storage.UpdateURL(ctx, shortURL, userID, func(urlData *types.URLData) error {
+		var options types.LinkOptions
+		if urlData.Options != nil {
+			options = *urlData.Options
+		}
+		if req.RedirectType != nil {
+			options.RedirectType = int(req.GetRedirectType())
+		}
+		if req.CacheTtl != nil {
+			options.CacheTTL = nil
+			if cacheTTL := req.GetCacheTtl(); cacheTTL >= 0 {
+				options.CacheTTL = &cacheTTL
+			}
+		}
+		if req.Interstitial != nil {
+			options.Interstitial = req.GetInterstitial()
+		}
+		var err error
+		if req.NotBefore != nil {
+			if options.NotBefore, err = parseWindowTime("not_before", req.GetNotBefore()); err != nil {
+				return &invalidUpdateError{err}
+			}
+		}
+		if req.NotAfter != nil {
+			if options.NotAfter, err = parseWindowTime("not_after", req.GetNotAfter()); err != nil {
+				return &invalidUpdateError{err}
+			}
+		}
+		if err := urlshort.ValidateLinkOptions(options); err != nil {
+			return &invalidUpdateError{err}
+		}
+
+		urlData.Options = nil
+		if !options.IsZero() {
+			urlData.Options = &options
+		}
+		if destination != "" {
+			setDestination(urlData, destination)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, updateStatus(err)
+	}
+
+	return &pb.UpdateURLResponse{
+		ShortUrl:    s.Config.BaseURL + "/" + urlData.ShortURL,
+		OriginalUrl: urlData.OriginalURL,
 	}, nil
 }
+
+// URLHistory grpc
+func (s *URLShortener) URLHistory(ctx context.Context, req *pb.URLHistoryRequest) (*pb.URLHistoryResponse, error) {
+	userID, err := s.authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.Storage.URLHistory(ctx, strings.TrimPrefix(req.GetShortUrl(), "/"), userID)
+	if err != nil {
+		return nil, updateStatus(err)
+	}
+
+	response := &pb.URLHistoryResponse{
+		Revisions: make([]*pb.Revision, len(history)),
+	}
+	for i, r := range history {
+		response.Revisions[i] = &pb.Revision{
+			Version:     int32(r.Version),
+			OriginalUrl: r.OriginalURL,
+			ChangedAt:   r.ChangedAt.Format(time.RFC3339),
+			ChangedBy:   r.ChangedBy,
+		}
+	}
+	return response, nil
+}
+
+// RollbackURL grpc
+func (s *URLShortener) RollbackURL(ctx context.Context, req *pb.RollbackURLRequest) (*pb.UpdateURLResponse, error) {
+	userID, err := s.authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shortURL := strings.TrimPrefix(req.GetShortUrl(), "/")
+	urlData, err := rollbackURL(ctx, s.Storage, s.destinations(), s.Config, shortURL, userID, int(req.GetVersion()))
+	if err != nil {
+		return nil, updateStatus(err)
+	}
+
+	return &pb.UpdateURLResponse{
+		ShortUrl:    s.Config.BaseURL + "/" + urlData.ShortURL,
+		OriginalUrl: urlData.OriginalURL,
+	}, nil
+}
+
+// URLStats grpc
+func (s *URLShortener) URLStats(ctx context.Context, req *pb.URLStatsRequest) (*pb.URLStatsResponse, error) {
+	userID, err := s.authenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := analytics.ParseQuery(req.GetFrom(), req.GetTo(), req.GetInterval(), time.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	q.IncludeBots = req.GetIncludeBots()
+
+	report, err := urlReport(ctx, s.Storage, strings.TrimPrefix(req.GetShortUrl(), "/"), userID, q)
+	if err != nil {
+		if strings.Contains(err.Error(), "URL not found") {
+			return nil, status.Error(codes.NotFound, "URL not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &pb.URLStatsResponse{
+		TotalClicks:   report.TotalClicks,
+		UniqueClicks:  report.UniqueClicks,
+		Series:        make([]*pb.StatsBucket, len(report.Series)),
+		TopReferrers:  statsCounts(report.TopReferrers),
+		TopUserAgents: statsCounts(report.TopAgents),
+		TopCountries:  statsCounts(report.TopCountries),
+		BotClicks:     report.BotClicks,
+	}
+	for i, b := range report.Series {
+		response.Series[i] = &pb.StatsBucket{Start: b.Start.Format(time.RFC3339), Clicks: b.Clicks}
+	}
+	return response, nil
+}
+
+// tokenMetadata is the metadata key of the user token of gRPC calls.
+const tokenMetadata = "authorization"
+
+// authenticatedUser returns the user of the token sent in the call metadata, the value of the
+// Authorization cookie of the HTTP API, optionally prefixed with "Bearer ".
+// Calls without a valid token fail with Unauthenticated.
+func (s *URLShortener) authenticatedUser(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(tokenMetadata)
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "missing authorization token")
+	}
+	claims, err := s.AuthManager.ParseToken(strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, "invalid authorization token")
+	}
+	return claims.UserID, nil
+}
+
+// statsCounts converts a ranking of a report into its protobuf form.
+func statsCounts(counts []analytics.Count) []*pb.StatsCount {
+	result := make([]*pb.StatsCount, len(counts))
+	for i, c := range counts {
+		result[i] = &pb.StatsCount{Value: c.Value, Clicks: c.Clicks}
+	}
+	return result
+}
+
+// updateStatus converts an error of a change to a URL record into a gRPC status.
+func updateStatus(err error) error {
+	var violation *policy.Violation
+	if errors.As(err, &violation) {
+		return rejectionStatus(err, "")
+	}
+	var invalid *invalidUpdateError
+	if errors.As(err, &invalid) {
+		return status.Error(codes.InvalidArgument, invalid.Error())
+	}
+	var originalExistErr *postgres.OriginalExistError
+	if errors.As(err, &originalExistErr) {
+		return status.Error(codes.AlreadyExists, originalExistErr.Error())
+	}
+	if strings.Contains(err.Error(), "URL not found") {
+		return status.Error(codes.NotFound, "URL not found")
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// QRCode grpc
+func (s *URLShortener) QRCode(ctx context.Context, req *pb.QRCodeRequest) (*pb.QRCodeResponse, error) {
+	shortURL := strings.TrimPrefix(req.GetShortUrl(), "/")
+	if shortURL == "" {
+		return nil, status.Error(codes.InvalidArgument, "short url is empty")
+	}
+
+	if _, err := s.Storage.Get(shortURL); err != nil {
+		return nil, unavailableStatus(err)
+	}
+
+	// The options are checked by the same rules as the query of the HTTP endpoint
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"format": req.GetFormat(),
+		"ecc":    req.GetEcc(),
+		"fg":     req.GetForeground(),
+		"bg":     req.GetBackground(),
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if req.GetSize() != 0 {
+		query.Set("size", strconv.Itoa(int(req.GetSize())))
+	}
+	if req.Margin != nil {
+		query.Set("margin", strconv.Itoa(int(req.GetMargin())))
+	}
+	opts, err := qr.ParseOptions(query)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	image, err := qr.Encode(s.Config.BaseURL+"/"+shortURL, opts)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.QRCodeResponse{
+		Image:       image,
+		ContentType: opts.ContentType(),
+	}, nil
+}
+
+// createOptionsFromProto converts the protobuf creation options.
+func createOptionsFromProto(o *pb.CreateOptions) (types.CreateOptions, error) {
+	opts := types.CreateOptions{
+		TTL:       o.GetTtl(),
+		MaxClicks: o.GetMaxClicks(),
+		Password:  o.GetPassword(),
+		LinkOptions: types.LinkOptions{
+			RedirectType: int(o.GetRedirectType()),
+		},
+	}
+	if o != nil && o.CacheTtl != nil {
+		cacheTTL := o.GetCacheTtl()
+		opts.CacheTTL = &cacheTTL
+	}
+	if o.GetExpiresAt() != "" {
+		expiresAt, err := time.Parse(time.RFC3339, o.GetExpiresAt())
+		if err != nil {
+			return opts, errors.New("expires_at must be an RFC 3339 timestamp")
+		}
+		opts.ExpiresAt = &expiresAt
+	}
+	var err error
+	if opts.NotBefore, err = parseWindowTime("not_before", o.GetNotBefore()); err != nil {
+		return opts, err
+	}
+	if opts.NotAfter, err = parseWindowTime("not_after", o.GetNotAfter()); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// parseWindowTime parses a bound of an activation window; an empty value means no bound.
+func parseWindowTime(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return &t, nil
+}
+
+// rejectionStatus converts a policy violation into a PermissionDenied status
+// carrying the machine-readable reason in an ErrorInfo detail.
+func rejectionStatus(err error, correlationID string) error {
+	var violation *policy.Violation
+	if !errors.As(err, &violation) {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	metadata := map[string]string{"detail": violation.Detail}
+	if correlationID != "" {
+		metadata["correlation_id"] = correlationID
+	}
+	return errorInfoStatus(codes.PermissionDenied, violation.Error(), violation.Reason, metadata)
+}
+
+// Reasons of the ErrorInfo details of links that can no longer redirect.
+const (
+	reasonDeleted        = "deleted"
+	reasonExpired        = "expired"
+	reasonNoClicksLeft   = "no_clicks_left"
+	reasonNoLongerActive = "no_longer_active"
+)
+
+// unavailableReasons pairs the errors of links that can no longer redirect with their reasons.
+var unavailableReasons = []struct{ message, reason string }{
+	{"URL has been deleted", reasonDeleted},
+	{"URL has expired", reasonExpired},
+	{"URL has no clicks left", reasonNoClicksLeft},
+	{"URL is no longer active", reasonNoLongerActive},
+}
+
+// unavailableStatus converts the error of a link that cannot redirect into a gRPC status.
+// Links that are gone, answered with 410 Gone over HTTP, fail with FailedPrecondition and
+// the reason in ErrorInfo; unknown links and links that are not active yet are not found.
+func unavailableStatus(err error) error {
+	for _, u := range unavailableReasons {
+		if strings.Contains(err.Error(), u.message) {
+			return errorInfoStatus(codes.FailedPrecondition, u.message, u.reason, nil)
+		}
+	}
+	if strings.Contains(err.Error(), "URL not found") || strings.Contains(err.Error(), "URL is not active yet") {
+		return status.Error(codes.NotFound, "URL not found")
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// errorInfoStatus returns a status with the given code and an ErrorInfo detail with the reason.
+func errorInfoStatus(code codes.Code, message, reason string, metadata map[string]string) error {
+	st := status.New(code, message)
+	withDetails, detailsErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   "urlshortener",
+		Metadata: metadata,
+	})
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
@@ -8,15 +8,25 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jayjaytrn/URLShortener/config"
 	"github.com/jayjaytrn/URLShortener/internal/auth"
+	"github.com/jayjaytrn/URLShortener/internal/blocklist"
+	"github.com/jayjaytrn/URLShortener/internal/clicks"
 	"github.com/jayjaytrn/URLShortener/internal/db"
 	"github.com/jayjaytrn/URLShortener/internal/db/postgres"
 	"github.com/jayjaytrn/URLShortener/internal/middleware"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+	"github.com/jayjaytrn/URLShortener/internal/ratelimit"
+	"github.com/jayjaytrn/URLShortener/internal/rules"
+	"github.com/jayjaytrn/URLShortener/internal/threat"
 	"github.com/jayjaytrn/URLShortener/internal/types"
 	"github.com/jayjaytrn/URLShortener/internal/urlshort"
+	"github.com/jayjaytrn/URLShortener/internal/webhooks"
 )
 
 // Handler represents the main HTTP handler for the URL shortening service.
@@ -24,6 +34,14 @@ type Handler struct {
 	Storage     db.ShortenerStorage
 	Config      *config.Config
 	AuthManager *auth.Manager
+	Blocklist   *blocklist.List
+	Policy      *policy.Engine
+	Threats     *threat.List
+	LoopGuard   *urlshort.LoopGuard
+
+	PasswordLimiter *ratelimit.Limiter
+	Clicks          *clicks.Tracker
+	Webhooks        *webhooks.Dispatcher
 }
 
 // URLWaiter handles waiting for a URL input and processing it.
@@ -41,14 +59,30 @@ func (h *Handler) URLWaiter(res http.ResponseWriter, req *http.Request) {
 		http.Error(res, "error when read body", http.StatusBadRequest)
 		return
 	}
-	url := string(body)
-	valid := urlshort.ValidateURL(url)
-	if !valid {
+	url, err := urlshort.NormalizeURL(string(body), h.Config)
+	if err != nil {
 		http.Error(res, "wrong parameters", http.StatusBadRequest)
 		return
 	}
 
-	su, err := urlshort.GenerateShortURL(h.Storage)
+	opts, err := createOptionsFromQuery(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	if err = urlshort.ValidateCreateOptions(opts, now); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	url, err = h.destinations().check(req.Context(), url)
+	if err != nil {
+		writeRejection(res, err, "")
+		return
+	}
+
+	su, err := urlshort.GenerateShortURL(h.Storage, h.Blocklist)
 	if err != nil {
 		http.Error(res, "failed to generate short URL: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -65,6 +99,10 @@ func (h *Handler) URLWaiter(res http.ResponseWriter, req *http.Request) {
 		ShortURL:    su,
 		UserID:      userID,
 	}
+	if err = urlshort.ApplyCreateOptions(&urlData, opts, now); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	err = h.Storage.Put(urlData)
 	if err != nil {
@@ -87,26 +125,98 @@ func (h *Handler) URLWaiter(res http.ResponseWriter, req *http.Request) {
 }
 
 // URLReturner retrieves the original URL from the shortened URL.
+// A trailing "+" on the short code or ?preview=1 renders the preview page instead of redirecting.
+//
+// HEAD requests, made by crawlers and link unfurlers to check the link, are answered like GET
+// and recorded as visits of bots, but use up no click and count no split assignment.
 func (h *Handler) URLReturner(res http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodGet {
-		http.Error(res, "only GET method is allowed", http.StatusBadRequest)
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(res, "only GET and HEAD methods are allowed", http.StatusBadRequest)
 		return
 	}
 
-	shortURL := req.URL.Path[len("/"):]
+	// Links with path suffixes are requested as /{id}/extra/path
+	shortURL, _, hasSuffix := strings.Cut(req.URL.Path[len("/"):], "/")
+	_, suffix, _ := strings.Cut(req.URL.EscapedPath()[len("/"):], "/")
+	query := req.URL.Query()
+	shortURL, preview := cutPreview(shortURL, query)
 
-	originalURL, err := h.Storage.GetOriginal(shortURL)
+	urlData, err := h.Storage.Get(shortURL)
 	if err != nil {
-		if strings.Contains(err.Error(), "URL has been deleted") {
-			http.Error(res, "URL has been deleted", http.StatusGone) // 410 Gone
+		writeUnavailable(res, err)
+		return
+	}
+	if hasSuffix && (urlData.Options == nil || !urlData.Options.PathSuffix || !rules.ValidSuffix(suffix)) {
+		http.Error(res, "URL not found", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	if err = urlData.Active(now); err != nil {
+		h.writeInactive(res, urlData, err)
+		return
+	}
+
+	// A preview of a link without interstitial mode is not a visit: the continue
+	// button leads back to the link, which then counts the click
+	interstitial := h.interstitial(urlData)
+	visit := interstitial || !preview
+
+	destination, matched := rules.Destination(urlData, req, now)
+	if visit && !matched && urlData.Options != nil && len(urlData.Options.Variants) > 0 {
+		destination = h.chooseVariant(res, req, urlData)
+	}
+
+	// Links listed after creation are flagged on first visit
+	if !urlData.Flagged && (h.Config.ThreatCheckRedirects || preview || interstitial) && h.Threats.Listed(destination) {
+		if err = h.Storage.SetFlagged(req.Context(), []string{shortURL}); err != nil {
+			logger := logging.GetSugaredLogger()
+			logger.Errorw("failed to flag URL", "short_url", shortURL, "error", err)
+			logger.Sync()
+		}
+		urlData.Flagged = true
+	}
+
+	if urlData.Flagged {
+		renderPage(res, "warning.html", http.StatusOK, urlData)
+		return
+	}
+
+	if urlData.PasswordHash != "" && !h.unlocked(req, shortURL) {
+		renderPage(res, "password.html", http.StatusOK, passwordPage{ShortURL: shortURL, Preview: preview})
+		return
+	}
+
+	if visit && req.Method != http.MethodHead && urlData.ClicksLeft != nil {
+		if urlData, err = h.Storage.UseClick(req.Context(), shortURL); err != nil {
+			writeUnavailable(res, err)
 			return
 		}
-		http.Error(res, "URL not found", http.StatusNotFound)
+	}
+
+	destination, err = rules.Expand(destination, urlData, query, suffix, now)
+	if err != nil {
+		http.Error(res, "failed to build destination URL", http.StatusInternalServerError)
 		return
 	}
 
-	res.Header().Set("Location", originalURL)
-	res.WriteHeader(http.StatusTemporaryRedirect)
+	switch {
+	case interstitial:
+		h.Clicks.Record(shortURL, req, now)
+		h.preview(res, urlData, destination, destination)
+	case preview:
+		continueURL := "/" + shortURL
+		if hasSuffix {
+			continueURL += "/" + suffix
+		}
+		if len(query) > 0 {
+			continueURL += "?" + query.Encode()
+		}
+		h.preview(res, urlData, destination, continueURL)
+	default:
+		h.Clicks.Record(shortURL, req, now)
+		h.redirect(res, urlData, destination)
+	}
 }
 
 // Shorten handles the request to shorten a given URL.
@@ -125,14 +235,29 @@ func (h *Handler) Shorten(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	url := shortenRequest.URL
-	valid := urlshort.ValidateURL(url)
-	if !valid {
+	url, err := urlshort.NormalizeURL(shortenRequest.URL, h.Config)
+	if err != nil {
 		http.Error(res, "wrong parameters", http.StatusBadRequest)
 		return
 	}
 
-	su, err := urlshort.GenerateShortURL(h.Storage)
+	now := time.Now()
+	if err = urlshort.ValidateCreateOptions(shortenRequest.CreateOptions, now); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = h.destinations().checkOptions(req.Context(), h.Config, &shortenRequest.LinkOptions); err != nil {
+		writeRejection(res, err, "")
+		return
+	}
+
+	url, err = h.destinations().check(req.Context(), url)
+	if err != nil {
+		writeRejection(res, err, "")
+		return
+	}
+
+	su, err := urlshort.GenerateShortURL(h.Storage, h.Blocklist)
 	if err != nil {
 		http.Error(res, "failed to generate short URL: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -149,6 +274,10 @@ func (h *Handler) Shorten(res http.ResponseWriter, req *http.Request) {
 		ShortURL:    su,
 		UserID:      userID,
 	}
+	if err = urlshort.ApplyCreateOptions(&urlData, shortenRequest.CreateOptions, now); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
 	err = h.Storage.Put(urlData)
 	if err != nil {
 		var originalExistErr *postgres.OriginalExistError
@@ -204,19 +333,41 @@ func (h *Handler) ShortenBatch(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	valid := urlshort.ValidateBatchRequestURLs(batchRequest)
-	if !valid {
+	err = urlshort.NormalizeBatchRequestURLs(batchRequest, h.Config)
+	if err != nil {
 		http.Error(res, "wrong parameters", http.StatusBadRequest)
 		return
 	}
 
+	now := time.Now()
+	for _, b := range batchRequest {
+		if err = urlshort.ValidateCreateOptions(b.CreateOptions, now); err != nil {
+			http.Error(res, b.CorrelationID+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The items share one time limit for following known shorteners
+	ctx, cancel := h.LoopGuard.WithBudget(req.Context())
+	defer cancel()
+	for i, b := range batchRequest {
+		batchRequest[i].OriginalURL, err = h.destinations().check(ctx, b.OriginalURL)
+		if err == nil {
+			err = h.destinations().checkOptions(ctx, h.Config, &batchRequest[i].LinkOptions)
+		}
+		if err != nil {
+			writeRejection(res, err, b.CorrelationID)
+			return
+		}
+	}
+
 	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
 	if !ok {
 		http.Error(res, "internal server error", http.StatusBadRequest)
 		return
 	}
 
-	batchResponse, batchData, err := urlshort.GenerateShortBatch(h.Config, h.Storage, batchRequest, userID)
+	batchResponse, batchData, err := urlshort.GenerateShortBatch(h.Config, h.Storage, h.Blocklist, batchRequest, userID)
 	if err != nil {
 		http.Error(res, "failed to generate short URL: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -270,7 +421,11 @@ func (h *Handler) Urls(res http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	urlsResponse, err := json.Marshal(urls)
+	listing := make([]types.UserURL, len(urls))
+	for i, urlData := range urls {
+		listing[i] = types.NewUserURL(urlData)
+	}
+	urlsResponse, err := json.Marshal(listing)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
@@ -280,6 +435,156 @@ func (h *Handler) Urls(res http.ResponseWriter, req *http.Request) {
 	res.Write(urlsResponse)
 }
 
+// userURLPath is the prefix of the endpoints that manage a single URL of the user.
+const userURLPath = "/api/user/urls/"
+
+// UpdateURL changes the destination and settings of a shortened URL owned by the user.
+// Only the fields present in the request body are changed.
+func (h *Handler) UpdateURL(res http.ResponseWriter, req *http.Request) {
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(res, "internal server error", http.StatusBadRequest)
+		return
+	}
+
+	if req.Context().Value(middleware.CookieExistedKey) == false {
+		http.Error(res, "Unauthorized - cookie was created by request", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(res, "error when read body", http.StatusBadRequest)
+		return
+	}
+
+	// New destinations are checked like those of new links before the record is locked
+	// for the update; a malformed body is reported by the full decode below
+	changed := types.UpdateURLRequest{LinkOptions: &types.LinkOptions{}}
+	json.Unmarshal(body, &changed)
+	if err = h.destinations().checkOptions(req.Context(), h.Config, changed.LinkOptions); err != nil {
+		writeRejection(res, err, "")
+		return
+	}
+	var destination string
+	if changed.URL != "" {
+		if destination, err = h.destinations().destination(req.Context(), h.Config, changed.URL); err != nil {
+			writeRejection(res, err, "")
+			return
+		}
+	}
+
+	shortURL := strings.TrimPrefix(req.URL.Path, userURLPath)
+	urlData, err := h.Storage.UpdateURL(req.Context(), shortURL, userID, func(urlData *types.URLData) error {
+		var options types.LinkOptions
+		if urlData.Options != nil {
+			options = *urlData.Options
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&types.UpdateURLRequest{LinkOptions: &options}); err != nil {
+			return &invalidUpdateError{err}
+		}
+		if changed.Rules != nil {
+			options.Rules = changed.Rules
+		}
+		if changed.Variants != nil {
+			options.Variants = changed.Variants
+		}
+		if err := urlshort.ValidateLinkOptions(options); err != nil {
+			return &invalidUpdateError{err}
+		}
+
+		// Counts of a previous split say nothing about new variants
+		var previous []types.Variant
+		if urlData.Options != nil {
+			previous = urlData.Options.Variants
+		}
+		if !slices.Equal(previous, options.Variants) {
+			urlData.Assignments = nil
+			if len(options.Variants) > 0 {
+				urlData.Assignments = make([]int64, len(options.Variants))
+			}
+		}
+
+		urlData.Options = nil
+		if !options.IsZero() {
+			urlData.Options = &options
+		}
+		if destination != "" {
+			setDestination(urlData, destination)
+		}
+		return nil
+	})
+	if err != nil {
+		writeUpdateError(res, h.Config, err)
+		return
+	}
+
+	writeUserURL(res, h.Config, urlData)
+}
+
+// setDestination changes the destination of a URL record. The new destination has passed
+// the threat check, so a flag raised for the previous one is cleared.
+func setDestination(urlData *types.URLData, destination string) {
+	if urlData.OriginalURL != destination {
+		urlData.OriginalURL = destination
+		urlData.Flagged = false
+	}
+}
+
+// writeUserURL writes a URL record of the user as JSON.
+func writeUserURL(res http.ResponseWriter, cfg *config.Config, urlData types.URLData) {
+	urlData.ShortURL = cfg.BaseURL + "/" + urlData.ShortURL
+	br, err := json.Marshal(types.NewUserURL(urlData))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	res.Write(br)
+}
+
+// writeUpdateError writes the response for a failed change of a URL record.
+// A destination that is already shortened is answered like in Shorten, with 409 and the existing short URL.
+func writeUpdateError(res http.ResponseWriter, cfg *config.Config, err error) {
+	var invalid *invalidUpdateError
+	if errors.As(err, &invalid) {
+		http.Error(res, invalid.Error(), http.StatusBadRequest)
+		return
+	}
+	var originalExistErr *postgres.OriginalExistError
+	if errors.As(err, &originalExistErr) {
+		br, err := json.Marshal(types.ShortenResponse{Result: cfg.BaseURL + "/" + originalExistErr.ShortURL})
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusConflict)
+		res.Write(br)
+		return
+	}
+	if strings.Contains(err.Error(), "URL not found") {
+		http.Error(res, "URL not found", http.StatusNotFound)
+		return
+	}
+	http.Error(res, "error when trying to update URL", http.StatusInternalServerError)
+}
+
+// invalidUpdateError wraps problems with the body of an update request.
+type invalidUpdateError struct {
+	err error
+}
+
+// Error returns the error message for invalidUpdateError.
+func (e *invalidUpdateError) Error() string {
+	return "invalid update: " + e.err.Error()
+}
+
 // DeleteUrlsAsync asynchronously deletes a list of shortened URLs.
 func (h *Handler) DeleteUrlsAsync(res http.ResponseWriter, req *http.Request) {
 	res.Header().Set("Content-Type", "application/json")
@@ -324,39 +629,105 @@ func (h *Handler) Stats(res http.ResponseWriter, req *http.Request) {
 	logger := logging.GetSugaredLogger()
 	defer logger.Sync()
 
+	if !h.trusted(res, req) {
+		return
+	}
+
+	// Получаем количество уникальных пользователей и сокращённых URL
+	response, err := collectStats(req.Context(), h.Storage, h.Config, h.Clicks)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	json.NewEncoder(res).Encode(response)
+}
+
+// trusted reports whether the client reached the service from the trusted subnet,
+// answering with 403 Forbidden otherwise.
+func (h *Handler) trusted(res http.ResponseWriter, req *http.Request) bool {
 	trustedSubnet := h.Config.TrustedSubnet
 	if trustedSubnet == "" {
 		http.Error(res, "access denied: trusted_subnet is not set", http.StatusForbidden)
-		return
+		return false
 	}
 
 	clientIP := req.Header.Get("X-Real-IP")
 	if clientIP == "" {
 		http.Error(res, "access denied: missing X-Real-IP", http.StatusForbidden)
-		return
+		return false
 	}
 
 	if !isIPInTrustedSubnet(clientIP, trustedSubnet) {
 		http.Error(res, "access denied: IP not in trusted subnet", http.StatusForbidden)
-		return
+		return false
 	}
+	return true
+}
 
-	// Получаем количество уникальных пользователей и сокращённых URL
-	stats, err := h.Storage.GetStats()
-	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		return
+// writeUnavailable responds to a failed lookup of a short URL.
+// Links that existed but can no longer be followed answer with 410 Gone.
+func writeUnavailable(res http.ResponseWriter, err error) {
+	for _, reason := range []string{"URL has been deleted", "URL has expired", "URL has no clicks left"} {
+		if strings.Contains(err.Error(), reason) {
+			http.Error(res, reason, http.StatusGone) // 410 Gone
+			return
+		}
+	}
+	http.Error(res, "URL not found", http.StatusNotFound)
+}
+
+// createOptionsFromQuery reads the creation options of the plain-text endpoint from the
+// "ttl" (seconds), "expires_at" (RFC 3339) and "max_clicks" query parameters.
+// The link password is read from the X-Link-Password header to keep it out of access logs.
+func createOptionsFromQuery(req *http.Request) (types.CreateOptions, error) {
+	var opts types.CreateOptions
+	query := req.URL.Query()
+
+	if v := query.Get("ttl"); v != "" {
+		ttl, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return opts, errors.New("ttl must be a number of seconds")
+		}
+		opts.TTL = ttl
+	}
+	if v := query.Get("expires_at"); v != "" {
+		expiresAt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, errors.New("expires_at must be an RFC 3339 timestamp")
+		}
+		opts.ExpiresAt = &expiresAt
 	}
+	if v := query.Get("max_clicks"); v != "" {
+		maxClicks, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return opts, errors.New("max_clicks must be a number")
+		}
+		opts.MaxClicks = maxClicks
+	}
+	opts.Password = req.Header.Get("X-Link-Password")
 
-	// Формируем JSON-ответ
-	response := types.Stats{
-		Urls:  stats.Urls,
-		Users: stats.Users,
+	return opts, nil
+}
+
+// writeRejection responds with a machine-readable reason when a destination URL is refused.
+func writeRejection(res http.ResponseWriter, err error, correlationID string) {
+	var violation *policy.Violation
+	if !errors.As(err, &violation) {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	res.WriteHeader(http.StatusOK)
-	json.NewEncoder(res).Encode(response)
+	res.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(res).Encode(types.RejectionResponse{
+		Error:         "URL rejected by policy",
+		Reason:        violation.Reason,
+		Detail:        violation.Detail,
+		CorrelationID: correlationID,
+	})
 }
 
 // isIPInTrustedSubnet проверяет, входит ли IP в доверенную подсеть
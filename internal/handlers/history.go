@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/middleware"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// Suffixes of the endpoints that read and restore the revisions of a URL of the user.
+const (
+	historyPath  = "/history"
+	rollbackPath = "/rollback"
+)
+
+// URLHistory lists the revisions of a shortened URL owned by the user, oldest first.
+func (h *Handler) URLHistory(res http.ResponseWriter, req *http.Request) {
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(res, "internal server error", http.StatusBadRequest)
+		return
+	}
+
+	if req.Context().Value(middleware.CookieExistedKey) == false {
+		http.Error(res, "Unauthorized - cookie was created by request", http.StatusUnauthorized)
+		return
+	}
+
+	shortURL := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, userURLPath), historyPath)
+	history, err := h.Storage.URLHistory(req.Context(), shortURL, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "URL not found") {
+			http.Error(res, "URL not found", http.StatusNotFound)
+			return
+		}
+		http.Error(res, "error when trying to get URL history", http.StatusInternalServerError)
+		return
+	}
+
+	br, err := json.Marshal(history)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	res.Write(br)
+}
+
+// RollbackURL restores the destination of an earlier revision of a shortened URL owned by the user.
+// The rollback is recorded as a new revision.
+func (h *Handler) RollbackURL(res http.ResponseWriter, req *http.Request) {
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(res, "internal server error", http.StatusBadRequest)
+		return
+	}
+
+	if req.Context().Value(middleware.CookieExistedKey) == false {
+		http.Error(res, "Unauthorized - cookie was created by request", http.StatusUnauthorized)
+		return
+	}
+
+	var rollback types.RollbackRequest
+	if err := json.NewDecoder(req.Body).Decode(&rollback); err != nil {
+		http.Error(res, "invalid update: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shortURL := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, userURLPath), rollbackPath)
+	urlData, err := rollbackURL(req.Context(), h.Storage, h.destinations(), h.Config, shortURL, userID, rollback.Version)
+	if err != nil {
+		var violation *policy.Violation
+		if errors.As(err, &violation) {
+			writeRejection(res, err, "")
+			return
+		}
+		writeUpdateError(res, h.Config, err)
+		return
+	}
+
+	writeUserURL(res, h.Config, urlData)
+}
+
+// rollbackURL sets the destination of a URL record back to the one of the given revision.
+// The old destination is checked again like a new one, since the policy and the threat
+// list may have changed since it was stored.
+func rollbackURL(ctx context.Context, storage db.ShortenerStorage, checks destinationChecks, cfg *config.Config, shortURL, userID string, version int) (types.URLData, error) {
+	history, err := storage.URLHistory(ctx, shortURL, userID)
+	if err != nil {
+		return types.URLData{}, err
+	}
+
+	var destination string
+	for _, r := range history {
+		if r.Version == version {
+			destination = r.OriginalURL
+		}
+	}
+	if destination == "" {
+		return types.URLData{}, &invalidUpdateError{fmt.Errorf("no revision %d", version)}
+	}
+
+	if destination, err = checks.destination(ctx, cfg, destination); err != nil {
+		return types.URLData{}, err
+	}
+
+	return storage.UpdateURL(ctx, shortURL, userID, func(urlData *types.URLData) error {
+		setDestination(urlData, destination)
+		return nil
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/ratelimit"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// unlockCookie is the cookie that holds the token for an unlocked password-protected link.
+// It is scoped to the path of the link, so each link has its own.
+const unlockCookie = "link_unlock"
+
+// Errors returned by checkPassword.
+var (
+	errPasswordRequired = errors.New("password required")
+	errWrongPassword    = errors.New("wrong password")
+	errTooManyAttempts  = errors.New("too many wrong password attempts, try again later")
+)
+
+// passwordPage is the data of the password form.
+type passwordPage struct {
+	ShortURL string
+	Error    string
+	Preview  bool // Preview keeps the preview page asked for across the unlock
+}
+
+// checkPassword compares password with the bcrypt hash of a link.
+// Failures are counted per key and further attempts are refused once the limiter blocks the key.
+// The attempt is reserved before the slow comparison, so parallel guesses cannot overshoot the limit.
+func checkPassword(limiter *ratelimit.Limiter, key, hash, password string) error {
+	if !limiter.Reserve(key) {
+		return errTooManyAttempts
+	}
+	if password == "" {
+		limiter.Refund(key)
+		return errPasswordRequired
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return errWrongPassword
+	}
+	limiter.Reset(key)
+	return nil
+}
+
+// attemptKey identifies password attempts for a link from one client address.
+func attemptKey(shortURL, remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return shortURL + "|" + host
+}
+
+// unlocked reports whether the request carries a valid unlock token for the link.
+func (h *Handler) unlocked(req *http.Request, shortURL string) bool {
+	cookie, err := req.Cookie(unlockCookie)
+	if err != nil {
+		return false
+	}
+	return h.AuthManager.ValidLinkToken(cookie.Value, shortURL)
+}
+
+// URLUnlock checks the password submitted for a protected link. On success it sets a
+// short-lived unlock cookie and redirects back to the link, which then redirects to its destination.
+func (h *Handler) URLUnlock(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(res, "only POST method is allowed", http.StatusBadRequest)
+		return
+	}
+
+	shortURL := req.URL.Path[len("/"):]
+	preview := req.URL.Query().Get(previewParam) == "1"
+	back := "/" + shortURL
+	if preview {
+		back += "?" + previewParam + "=1"
+	}
+
+	urlData, err := h.Storage.Get(shortURL)
+	if err != nil {
+		writeUnavailable(res, err)
+		return
+	}
+	if urlData.PasswordHash == "" {
+		http.Redirect(res, req, back, http.StatusSeeOther)
+		return
+	}
+
+	err = checkPassword(h.PasswordLimiter, attemptKey(shortURL, req.RemoteAddr), urlData.PasswordHash, req.PostFormValue("password"))
+	switch {
+	case errors.Is(err, errTooManyAttempts):
+		renderPage(res, "password.html", http.StatusTooManyRequests, passwordPage{ShortURL: shortURL, Error: err.Error(), Preview: preview})
+		return
+	case err != nil:
+		renderPage(res, "password.html", http.StatusForbidden, passwordPage{ShortURL: shortURL, Error: err.Error(), Preview: preview})
+		return
+	}
+
+	ttl := time.Duration(h.Config.LinkUnlockTTL)
+	token, err := h.AuthManager.BuildLinkToken(shortURL, ttl)
+	if err != nil {
+		http.Error(res, "failed to unlock URL", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(res, &http.Cookie{
+		Name:     unlockCookie,
+		Value:    token,
+		Path:     "/" + shortURL,
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   h.Config.EnableHTTPS,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(res, req, back, http.StatusSeeOther)
+}
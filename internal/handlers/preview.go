@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// previewParam is the query parameter that asks for the preview page instead of the redirect.
+const previewParam = "preview"
+
+// previewPage is the data of the preview page.
+type previewPage struct {
+	ShortURL    string
+	Destination string
+	CreatedAt   *time.Time
+	Checked     bool   // Checked reports whether the destination was looked up in the threat list
+	ContinueURL string // ContinueURL is where the continue button leads
+}
+
+// cutPreview removes the preview marker from the requested short code: a trailing "+"
+// or the preview query parameter. It reports whether the preview page was asked for.
+func cutPreview(shortURL string, query url.Values) (string, bool) {
+	shortURL, plus := strings.CutSuffix(shortURL, "+")
+	param := query.Get(previewParam) == "1"
+	query.Del(previewParam)
+	return shortURL, plus || param
+}
+
+// interstitial reports whether visits of the link always go through the preview page.
+func (h *Handler) interstitial(urlData types.URLData) bool {
+	return h.Config.AlwaysInterstitial || (urlData.Options != nil && urlData.Options.Interstitial)
+}
+
+// preview renders the preview page of a link with a continue button leading to continueURL.
+func (h *Handler) preview(res http.ResponseWriter, urlData types.URLData, destination, continueURL string) {
+	// The page may be the counted visit of a link, so it is never cached
+	res.Header().Set("Cache-Control", "no-store")
+	renderPage(res, "preview.html", http.StatusOK, previewPage{
+		ShortURL:    urlData.ShortURL,
+		Destination: destination,
+		CreatedAt:   urlData.CreatedAt,
+		Checked:     h.Threats != nil,
+		ContinueURL: continueURL,
+	})
+}
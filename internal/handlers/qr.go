@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jayjaytrn/URLShortener/internal/qr"
+)
+
+// qrPath is the suffix of the endpoint that serves the QR code of a link.
+const qrPath = "/qr"
+
+// QRCode serves the QR code of a short link as a PNG or SVG image.
+// The image is customised with the query parameters read by qr.ParseOptions.
+func (h *Handler) QRCode(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(res, "only GET method is allowed", http.StatusBadRequest)
+		return
+	}
+
+	shortURL := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/"), qrPath)
+	if _, err := h.Storage.Get(shortURL); err != nil {
+		writeUnavailable(res, err)
+		return
+	}
+
+	opts, err := qr.ParseOptions(req.URL.Query())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	image, err := qr.Encode(h.Config.BaseURL+"/"+shortURL, opts)
+	if err != nil {
+		http.Error(res, "failed to generate QR code", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", opts.ContentType())
+	res.WriteHeader(http.StatusOK)
+	res.Write(image)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/rules"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// redirect sends the client to destination using the redirect status and cache lifetime of the link,
+// falling back to the server defaults for settings the link does not have.
+func (h *Handler) redirect(res http.ResponseWriter, urlData types.URLData, destination string) {
+	status := h.Config.RedirectType
+	cacheTTL := int64(h.Config.RedirectCacheTTL)
+	if o := urlData.Options; o != nil {
+		if o.RedirectType != 0 {
+			status = o.RedirectType
+		}
+		if o.CacheTTL != nil {
+			cacheTTL = *o.CacheTTL
+		}
+	}
+	if status == 0 {
+		status = http.StatusTemporaryRedirect
+	}
+
+	// Every visit of a click-limited link has to reach the server to be counted
+	if urlData.ClicksLeft != nil {
+		cacheTTL = 0
+	}
+	// A cached redirect must not outlive the activation window of the link
+	if o := urlData.Options; o != nil && o.NotAfter != nil {
+		left := int64(max(time.Until(*o.NotAfter), 0) / time.Second)
+		if cacheTTL < 0 || left < cacheTTL {
+			cacheTTL = left
+		}
+	}
+
+	// The destination of a link with rules or a split depends on the visitor,
+	// so such redirects must not be stored by shared caches
+	var vary []string
+	split := false
+	if urlData.Options != nil {
+		vary = rules.Vary(urlData.Options.Rules)
+		split = len(urlData.Options.Variants) > 0
+	}
+	if len(vary) > 0 {
+		res.Header().Set("Vary", strings.Join(vary, ", "))
+	}
+
+	switch {
+	case cacheTTL == 0:
+		res.Header().Set("Cache-Control", "no-store")
+	case cacheTTL > 0 && (urlData.PasswordHash != "" || len(vary) > 0 || split):
+		res.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(cacheTTL, 10))
+	case cacheTTL > 0:
+		res.Header().Set("Cache-Control", "public, max-age="+strconv.FormatInt(cacheTTL, 10))
+	}
+
+	res.Header().Set("Location", destination)
+	res.WriteHeader(status)
+}
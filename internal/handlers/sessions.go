@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/middleware"
+)
+
+// maxTokenIDLength is the longest token ID that can be revoked.
+const maxTokenIDLength = 64
+
+// Logout revokes the session of the auth cookie, so that copies of the cookie are no longer
+// accepted, and removes the cookie.
+func (h *Handler) Logout(res http.ResponseWriter, req *http.Request) {
+	if req.Context().Value(middleware.CookieExistedKey) == false {
+		http.Error(res, "Unauthorized - cookie was created by request", http.StatusUnauthorized)
+		return
+	}
+	tokenID, ok := req.Context().Value(middleware.TokenIDKey).(string)
+	if !ok {
+		http.Error(res, "Unauthorized - session is not valid", http.StatusUnauthorized)
+		return
+	}
+
+	// Refreshed copies of the session may expire up to a token lifetime from now
+	until := time.Now().Add(h.AuthManager.TokenTTL())
+	if err := h.Storage.RevokeToken(req.Context(), tokenID, until); err != nil {
+		http.Error(res, "error when trying to revoke session", http.StatusInternalServerError)
+		return
+	}
+	h.AuthManager.Revoke(tokenID, until)
+
+	http.SetCookie(res, &http.Cookie{
+		Name:     "Authorization",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeTokens revokes the sessions with the token IDs of the request body, the "jti" claims of
+// their auth cookies. Only requests from the trusted subnet are served.
+func (h *Handler) RevokeTokens(res http.ResponseWriter, req *http.Request) {
+	if !h.trusted(res, req) {
+		return
+	}
+
+	var tokenIDs []string
+	if err := json.NewDecoder(req.Body).Decode(&tokenIDs); err != nil || len(tokenIDs) == 0 {
+		http.Error(res, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	for _, tokenID := range tokenIDs {
+		if tokenID == "" || len(tokenID) > maxTokenIDLength {
+			http.Error(res, "invalid token ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	until := time.Now().Add(h.AuthManager.TokenTTL())
+	for _, tokenID := range tokenIDs {
+		if err := h.Storage.RevokeToken(req.Context(), tokenID, until); err != nil {
+			http.Error(res, "error when trying to revoke session", http.StatusInternalServerError)
+			return
+		}
+		h.AuthManager.Revoke(tokenID, until)
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/rules"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+	"github.com/jayjaytrn/URLShortener/logging"
+)
+
+// splitCookie remembers the split variant assigned to a visitor.
+// It is scoped to the path of the link, so each link has its own.
+const splitCookie = "split_variant"
+
+// splitCookieMaxAge keeps visitors on the same variant for 30 days.
+const splitCookieMaxAge = 30 * 24 * 60 * 60
+
+// chooseVariant returns the split destination for the visitor.
+//
+// Returning visitors keep the variant stored in their cookie. New visitors are assigned by
+// hashing a visitor key, so clients without cookies stay on the same variant as well;
+// each new assignment is counted. HEAD requests are not assigned, they only get the variant.
+func (h *Handler) chooseVariant(res http.ResponseWriter, req *http.Request, urlData types.URLData) string {
+	variants := urlData.Options.Variants
+
+	if cookie, err := req.Cookie(splitCookie); err == nil {
+		if i, err := strconv.Atoi(cookie.Value); err == nil && i >= 0 && i < len(variants) {
+			return variants[i].URL
+		}
+	}
+
+	i := rules.ChooseVariant(variants, urlData.ShortURL+"|"+visitorKey(h.Config, req))
+	if req.Method == http.MethodHead {
+		return variants[i].URL
+	}
+	countAssignment(req.Context(), h.Storage, urlData.ShortURL, i)
+
+	http.SetCookie(res, &http.Cookie{
+		Name:     splitCookie,
+		Value:    strconv.Itoa(i),
+		Path:     "/" + urlData.ShortURL,
+		MaxAge:   splitCookieMaxAge,
+		HttpOnly: true,
+		Secure:   h.Config.EnableHTTPS,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return variants[i].URL
+}
+
+// countAssignment counts the assignment of a visitor to split variant i of a link.
+// Errors are logged, as they must not fail the redirect.
+func countAssignment(ctx context.Context, storage db.ShortenerStorage, shortURL string, i int) {
+	if err := storage.CountAssignment(ctx, shortURL, i); err != nil {
+		logger := logging.GetSugaredLogger()
+		logger.Errorw("failed to count variant assignment", "short_url", shortURL, "error", err)
+		logger.Sync()
+	}
+}
+
+// visitorKey identifies a visitor for split assignment: the configured visitor header if present,
+// otherwise the client address and User-Agent.
+func visitorKey(cfg *config.Config, req *http.Request) string {
+	if cfg.SplitVisitorHeader != "" {
+		if v := req.Header.Get(cfg.SplitVisitorHeader); v != "" {
+			return v
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return host + "|" + req.UserAgent()
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/clicks"
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// visitorsWindow is the period over which the service stats count distinct visitors.
+const visitorsWindow = 30 * 24 * time.Hour
+
+// serviceStats is the body of the service stats endpoint.
+type serviceStats struct {
+	types.Stats
+	Backend    backendHealth `json:"backend"`
+	ClickQueue *clicks.Stats `json:"click_queue,omitempty"` // ClickQueue is absent when click tracking is off
+}
+
+// backendHealth describes the state of the storage.
+type backendHealth struct {
+	Type      string  `json:"type"`
+	Healthy   bool    `json:"healthy"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// collectStats gathers the statistics of the storage, the health of the backend and the
+// state of the click queue.
+func collectStats(ctx context.Context, storage db.ShortenerStorage, cfg *config.Config, tracker *clicks.Tracker) (serviceStats, error) {
+	stats, err := storage.GetStats()
+	if err != nil {
+		return serviceStats{}, err
+	}
+
+	now := time.Now()
+	visitors, err := storage.Visitors(ctx, "", now.Add(-visitorsWindow), now)
+	if err != nil {
+		return serviceStats{}, err
+	}
+	stats.Visitors = visitors.Estimate()
+
+	result := serviceStats{Stats: stats, Backend: pingBackend(ctx, storage, cfg)}
+	if tracker != nil {
+		queue := tracker.Stats()
+		result.ClickQueue = &queue
+	}
+	return result, nil
+}
+
+// pingBackend checks that the storage answers. In-process storages cannot be pinged
+// and are always healthy.
+func pingBackend(ctx context.Context, storage db.ShortenerStorage, cfg *config.Config) backendHealth {
+	health := backendHealth{Type: cfg.StorageType, Healthy: true}
+
+	start := time.Now()
+	err := storage.Ping(ctx)
+	health.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil && !strings.Contains(err.Error(), "not supported") {
+		health.Healthy = false
+		health.Error = err.Error()
+	}
+	return health
+}
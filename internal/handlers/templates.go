@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// pages holds the HTML pages served by the redirect handler instead of a redirect.
+var pages = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// renderPage renders the named HTML page with the given status code.
+func renderPage(res http.ResponseWriter, name string, status int, data any) {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		http.Error(res, "failed to render page", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(status)
+	res.Write(buf.Bytes())
+}
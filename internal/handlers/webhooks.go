@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/middleware"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// webhooksPath is the prefix of the endpoints that manage the webhooks of the user.
+const webhooksPath = "/api/user/webhooks"
+
+// Limits of the delivery log.
+const (
+	defaultDeliveries = 100
+	maxDeliveries     = 1000
+)
+
+// CreateWebhook subscribes a URL of the user to the events of their links. The response is the
+// only one that holds the secret that signs the payloads.
+func (h *Handler) CreateWebhook(res http.ResponseWriter, req *http.Request) {
+	userID, ok := h.webhookUser(res, req)
+	if !ok {
+		return
+	}
+
+	var hookRequest types.WebhookRequest
+	if err := json.NewDecoder(req.Body).Decode(&hookRequest); err != nil {
+		http.Error(res, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	hook, err := h.Webhooks.NewWebhook(userID, hookRequest, time.Now())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = h.Storage.PutWebhook(req.Context(), hook); err != nil {
+		http.Error(res, "error when trying to put data in storage", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(res, http.StatusCreated, hook)
+}
+
+// ListWebhooks lists the webhooks of the user, oldest first, without their secrets.
+func (h *Handler) ListWebhooks(res http.ResponseWriter, req *http.Request) {
+	userID, ok := h.webhookUser(res, req)
+	if !ok {
+		return
+	}
+
+	hooks, err := h.Storage.GetWebhooks(req.Context(), userID)
+	if err != nil {
+		http.Error(res, "error when trying to get webhooks", http.StatusInternalServerError)
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	if hooks == nil {
+		hooks = []types.Webhook{}
+	}
+
+	writeJSON(res, http.StatusOK, hooks)
+}
+
+// DeleteWebhook removes a webhook of the user together with its delivery log.
+func (h *Handler) DeleteWebhook(res http.ResponseWriter, req *http.Request) {
+	userID, ok := h.webhookUser(res, req)
+	if !ok {
+		return
+	}
+
+	id := strings.TrimPrefix(req.URL.Path, webhooksPath+"/")
+	if err := h.Storage.DeleteWebhook(req.Context(), id, userID); err != nil {
+		if strings.Contains(err.Error(), "webhook not found") {
+			http.Error(res, "webhook not found", http.StatusNotFound)
+			return
+		}
+		http.Error(res, "error when trying to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// WebhookDeliveries lists the deliveries of a webhook of the user, newest first. The "status"
+// query parameter selects pending, delivered or dead deliveries, and "limit" bounds their number.
+func (h *Handler) WebhookDeliveries(res http.ResponseWriter, req *http.Request) {
+	userID, ok := h.webhookUser(res, req)
+	if !ok {
+		return
+	}
+
+	query := req.URL.Query()
+	status := query.Get("status")
+	if status != "" && status != types.DeliveryPending && status != types.DeliveryDelivered && status != types.DeliveryDead {
+		http.Error(res, "status must be pending, delivered or dead", http.StatusBadRequest)
+		return
+	}
+	limit := defaultDeliveries
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDeliveries {
+			http.Error(res, "limit must be between 1 and "+strconv.Itoa(maxDeliveries), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	id, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, webhooksPath+"/"), "/")
+	if !h.ownsWebhook(res, req, id, userID) {
+		return
+	}
+	deliveries, err := h.Storage.GetDeliveries(req.Context(), id, status)
+	if err != nil {
+		http.Error(res, "error when trying to get webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []types.Delivery{}
+	}
+
+	writeJSON(res, http.StatusOK, deliveries[:min(limit, len(deliveries))])
+}
+
+// RetryDelivery sends a dead letter of a webhook of the user again with a fresh set of attempts.
+func (h *Handler) RetryDelivery(res http.ResponseWriter, req *http.Request) {
+	userID, ok := h.webhookUser(res, req)
+	if !ok {
+		return
+	}
+
+	// The path is /api/user/webhooks/{id}/deliveries/{delivery}/retry
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, webhooksPath+"/"), "/")
+	if len(parts) != 4 {
+		http.Error(res, "delivery not found", http.StatusNotFound)
+		return
+	}
+	if !h.ownsWebhook(res, req, parts[0], userID) {
+		return
+	}
+	delivery, err := h.Webhooks.Retry(req.Context(), parts[0], parts[2])
+	if err != nil {
+		if strings.Contains(err.Error(), "delivery not found") {
+			http.Error(res, "delivery not found", http.StatusNotFound)
+			return
+		}
+		http.Error(res, "error when trying to retry delivery", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(res, http.StatusAccepted, delivery)
+}
+
+// webhookUser returns the user of a webhook request, answering it when the user is not authenticated.
+func (h *Handler) webhookUser(res http.ResponseWriter, req *http.Request) (string, bool) {
+	userID, ok := req.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		http.Error(res, "internal server error", http.StatusBadRequest)
+		return "", false
+	}
+
+	if req.Context().Value(middleware.CookieExistedKey) == false {
+		http.Error(res, "Unauthorized - cookie was created by request", http.StatusUnauthorized)
+		return "", false
+	}
+	return userID, true
+}
+
+// ownsWebhook reports whether the webhook belongs to the user, answering with 404 Not Found otherwise.
+func (h *Handler) ownsWebhook(res http.ResponseWriter, req *http.Request, id, userID string) bool {
+	hooks, err := h.Storage.GetWebhooks(req.Context(), userID)
+	if err != nil {
+		http.Error(res, "error when trying to get webhooks", http.StatusInternalServerError)
+		return false
+	}
+	if !slices.ContainsFunc(hooks, func(hook types.Webhook) bool { return hook.ID == id }) {
+		http.Error(res, "webhook not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(res http.ResponseWriter, status int, v any) {
+	br, err := json.Marshal(v)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	res.Write(br)
+}
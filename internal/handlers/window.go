@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// inactivePageResponse is the InactiveResponse setting that answers visits of links
+// outside their activation window with an HTML page instead of a bare status.
+const inactivePageResponse = "page"
+
+// inactivePage is the data of the page shown for links outside their activation window.
+type inactivePage struct {
+	ShortURL  string
+	NotBefore *time.Time
+	NotAfter  *time.Time
+	Ended     bool // Ended reports whether the window has passed
+}
+
+// writeInactive answers a visit of a link outside its activation window. A link that is not
+// active yet is reported as not found, one whose window has passed as gone.
+func (h *Handler) writeInactive(res http.ResponseWriter, urlData types.URLData, err error) {
+	ended := strings.Contains(err.Error(), "URL is no longer active")
+	status, message := http.StatusNotFound, "URL not found"
+	if ended {
+		status, message = http.StatusGone, err.Error()
+	}
+
+	// The answer changes when the window opens, so it must not be cached
+	res.Header().Set("Cache-Control", "no-store")
+	if h.Config.InactiveResponse != inactivePageResponse {
+		http.Error(res, message, status)
+		return
+	}
+	renderPage(res, "inactive.html", status, inactivePage{
+		ShortURL:  urlData.ShortURL,
+		NotBefore: urlData.Options.NotBefore,
+		NotAfter:  urlData.Options.NotAfter,
+		Ended:     ended,
+	})
+}
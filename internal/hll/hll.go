@@ -0,0 +1,177 @@
+// Package hll implements HyperLogLog sketches that estimate the number of distinct values
+// added to them in a small, fixed amount of memory.
+//
+// A sketch has 2^14 registers. The standard error of its estimate is 1.04/sqrt(2^14),
+// about 0.81%: two estimates in three are within 0.81% of the true count and almost all
+// are within 2.5%. Counts up to a few tens of thousands use linear counting, which is
+// more accurate still. Sketches of disjoint periods can be merged into the sketch of the
+// whole period without losing accuracy, so distinct counts over any range of days can be
+// built from daily sketches.
+package hll
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// precision is the number of hash bits that select a register.
+const precision = 14
+
+// registers is the number of registers of a sketch.
+const registers = 1 << precision
+
+// Encodings of a marshalled sketch.
+const (
+	encodingDense  = 0 // one byte per register
+	encodingSparse = 1 // a 2-byte index and a 1-byte value per non-zero register
+)
+
+// Sketch estimates the number of distinct values added to it.
+// The zero value is not usable; create sketches with New.
+type Sketch struct {
+	regs []uint8
+}
+
+// New returns an empty sketch.
+func New() *Sketch {
+	return &Sketch{regs: make([]uint8, registers)}
+}
+
+// Add adds a value to the sketch. The value is hashed, so any string identifying
+// a visitor can be used.
+func (s *Sketch) Add(value string) {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	s.AddHash(mix(h.Sum64()))
+}
+
+// AddHash adds a value given by a uniformly distributed 64-bit hash.
+func (s *Sketch) AddHash(x uint64) {
+	i := x >> (64 - precision)
+	// The remaining bits are shifted to the top; the sentinel bit bounds the rank
+	// when they are all zero
+	rank := uint8(bits.LeadingZeros64(x<<precision|1<<(precision-1))) + 1
+	if rank > s.regs[i] {
+		s.regs[i] = rank
+	}
+}
+
+// Merge adds the values of other to the sketch.
+func (s *Sketch) Merge(other *Sketch) {
+	for i, r := range other.regs {
+		if r > s.regs[i] {
+			s.regs[i] = r
+		}
+	}
+}
+
+// Estimate returns the approximate number of distinct values added to the sketch.
+func (s *Sketch) Estimate() uint64 {
+	var sum float64
+	zeros := 0
+	for _, r := range s.regs {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(registers)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// MarshalBinary encodes the sketch. Sketches with few visitors are stored sparsely,
+// so that the many daily sketches of rarely visited links stay small.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	nonZero := 0
+	for _, r := range s.regs {
+		if r != 0 {
+			nonZero++
+		}
+	}
+
+	if 3*nonZero >= registers {
+		return append([]byte{precision, encodingDense}, s.regs...), nil
+	}
+
+	data := make([]byte, 2, 2+3*nonZero)
+	data[0], data[1] = precision, encodingSparse
+	for i, r := range s.regs {
+		if r != 0 {
+			data = binary.BigEndian.AppendUint16(data, uint16(i))
+			data = append(data, r)
+		}
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary.
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 || data[0] != precision {
+		return errors.New("hll: unsupported sketch encoding")
+	}
+
+	regs := make([]uint8, registers)
+	body := data[2:]
+	switch data[1] {
+	case encodingDense:
+		if len(body) != registers {
+			return errors.New("hll: truncated sketch")
+		}
+		copy(regs, body)
+	case encodingSparse:
+		if len(body)%3 != 0 {
+			return errors.New("hll: truncated sketch")
+		}
+		for ; len(body) > 0; body = body[3:] {
+			i := binary.BigEndian.Uint16(body)
+			if int(i) >= registers {
+				return errors.New("hll: register index out of range")
+			}
+			regs[i] = body[2]
+		}
+	default:
+		return errors.New("hll: unsupported sketch encoding")
+	}
+
+	s.regs = regs
+	return nil
+}
+
+// MarshalText encodes the sketch as base64 text, so that it can be stored in JSON.
+func (s *Sketch) MarshalText() ([]byte, error) {
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.AppendEncode(nil, data), nil
+}
+
+// UnmarshalText decodes a sketch encoded by MarshalText.
+func (s *Sketch) UnmarshalText(text []byte) error {
+	data, err := base64.StdEncoding.AppendDecode(nil, text)
+	if err != nil {
+		return err
+	}
+	return s.UnmarshalBinary(data)
+}
+
+// mix spreads the bits of a hash, since HyperLogLog relies on every bit being uniform.
+// It is the finalizer of SplitMix64.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
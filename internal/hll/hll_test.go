@@ -0,0 +1,84 @@
+package hll
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name     string
+		distinct int
+	}{
+		{name: "Empty", distinct: 0},
+		{name: "Few", distinct: 10},
+		{name: "Linear counting range", distinct: 5000},
+		{name: "Large", distinct: 500000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			for i := 0; i < tt.distinct; i++ {
+				// Every value is added twice, duplicates must not count
+				s.Add("visitor-" + strconv.Itoa(i))
+				s.Add("visitor-" + strconv.Itoa(i))
+			}
+
+			got := float64(s.Estimate())
+			// Four standard errors
+			if math.Abs(got-float64(tt.distinct)) > 0.033*float64(tt.distinct)+1 {
+				t.Errorf("expected about %d, got %v", tt.distinct, got)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b, all := New(), New(), New()
+	for i := 0; i < 20000; i++ {
+		v := strconv.Itoa(i)
+		all.Add(v)
+		if i < 12000 {
+			a.Add(v)
+		}
+		if i >= 8000 {
+			b.Add(v)
+		}
+	}
+
+	a.Merge(b)
+	if a.Estimate() != all.Estimate() {
+		t.Errorf("expected the merged sketch to estimate %d, got %d", all.Estimate(), a.Estimate())
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, distinct := range []int{0, 100, 100000} {
+		s := New()
+		for i := 0; i < distinct; i++ {
+			s.Add(strconv.Itoa(i))
+		}
+
+		data, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if distinct == 100 && len(data) > 2+3*100 {
+			t.Errorf("expected a sparse encoding, got %d bytes", len(data))
+		}
+
+		decoded := New()
+		if err = decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("failed to decode %d values: %v", distinct, err)
+		}
+		if decoded.Estimate() != s.Estimate() {
+			t.Errorf("expected %d after decoding, got %d", s.Estimate(), decoded.Estimate())
+		}
+	}
+
+	if err := New().UnmarshalBinary([]byte{precision, encodingSparse, 0}); err == nil {
+		t.Errorf("expected an error for a truncated sketch")
+	}
+}
@@ -24,6 +24,9 @@ const UserIDKey ContextKey = "userID"
 // CookieExistedKey is the key to determine if the cookie existed before the request.
 const CookieExistedKey ContextKey = "cookieExisted"
 
+// TokenIDKey is the key used to store the session ID of a valid auth token in the request context.
+const TokenIDKey ContextKey = "tokenID"
+
 // loggingResponseWriter is a wrapper around http.ResponseWriter that captures response details.
 // It allows you to intercept the response data (status code and size) for logging purposes.
 type loggingResponseWriter struct {
@@ -65,7 +68,7 @@ func Conveyor(h http.Handler, sugar *zap.SugaredLogger, middlewares ...Middlewar
 func WriteWithCompression(h http.Handler, sugar *zap.SugaredLogger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		contentType := r.Header.Get("Content-Type")
-		if contentType != "application/json" && contentType != "text/html" {
+		if contentType != "application/json" && contentType != "text/html" && !acceptsExport(r) {
 			sugar.Info("Content-Type is not supported for compression. Content-Type: " + contentType)
 			h.ServeHTTP(w, r)
 			return
@@ -96,6 +99,35 @@ func (w gzipWriter) Write(b []byte) (int, error) {
 	return w.GzipWriter.Write(b)
 }
 
+// Flush sends the data compressed so far to the client, so that streamed responses
+// are not held back by the compressor.
+func (w gzipWriter) Flush() {
+	if f, ok := w.GzipWriter.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// exportTypes are the media types of streamed exports, which clients ask for with the Accept header.
+var exportTypes = []string{"text/csv", "application/x-ndjson"}
+
+// acceptsExport reports whether the request asks for an export, by its path or Accept header.
+// Export paths are matched since their format may be chosen by a query parameter instead.
+func acceptsExport(r *http.Request) bool {
+	if strings.Contains(r.URL.Path, "/export/") {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	for _, t := range exportTypes {
+		if strings.Contains(accept, t) {
+			return true
+		}
+	}
+	return false
+}
+
 // ReadWithCompression is a middleware that enables GZIP decompression for incoming requests.
 func ReadWithCompression(h http.Handler, sugar *zap.SugaredLogger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -181,6 +213,13 @@ func (r *loggingResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Flush sends buffered data to the client when the underlying writer supports it.
+func (r *loggingResponseWriter) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // WriteHeader captures the response status code.
 func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.ResponseWriter.WriteHeader(statusCode)
@@ -188,6 +227,10 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 }
 
 // WithAuth is a middleware that manages JWT authentication and assigns user IDs.
+//
+// Tokens of revoked sessions are treated as invalid, as the auth manager reports them without
+// a storage lookup, and valid tokens close to their expiry
+// are reissued so that active users keep their session.
 func WithAuth(next http.Handler, authManager *auth.Manager, storage db.ShortenerStorage, logger *zap.SugaredLogger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var newJWT string
@@ -206,12 +249,7 @@ func WithAuth(next http.Handler, authManager *auth.Manager, storage db.Shortener
 				ctx = context.WithValue(ctx, CookieExistedKey, false)
 				r = r.WithContext(ctx)
 
-				http.SetCookie(w, &http.Cookie{
-					Name:     "Authorization",
-					Value:    newJWT,
-					Path:     "/",
-					HttpOnly: true,
-				})
+				setAuthCookie(w, newJWT, authManager.TokenTTL())
 			} else {
 				logger.Debug("Error retrieving cookie: " + err.Error())
 				http.Error(w, "Error retrieving cookie", http.StatusUnauthorized)
@@ -220,7 +258,7 @@ func WithAuth(next http.Handler, authManager *auth.Manager, storage db.Shortener
 		} else {
 			// Validate JWT from existing cookie
 			logger.Debug("Cookie found, validating JWT")
-			userID, err := authManager.GetUserIDFromJWTString(cookie.Value)
+			claims, err := authManager.ParseToken(cookie.Value)
 			if err != nil {
 				logger.Debug("JWT validation failed: " + err.Error())
 				// Generate a new JWT if the old one is invalid
@@ -233,19 +271,35 @@ func WithAuth(next http.Handler, authManager *auth.Manager, storage db.Shortener
 				ctx = context.WithValue(ctx, CookieExistedKey, true)
 				r = r.WithContext(ctx)
 
-				http.SetCookie(w, &http.Cookie{
-					Name:     "Authorization",
-					Value:    newJWT,
-					Path:     "/",
-					HttpOnly: true,
-				})
+				setAuthCookie(w, newJWT, authManager.TokenTTL())
 			} else {
-				// Valid JWT, setting the user ID in context
-				ctx := context.WithValue(r.Context(), UserIDKey, userID)
+				// Valid JWT, setting the user ID and the session in context
+				ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 				ctx = context.WithValue(ctx, CookieExistedKey, true)
+				ctx = context.WithValue(ctx, TokenIDKey, claims.ID)
 				r = r.WithContext(ctx)
+
+				// Sliding refresh: reissue the cookie of an active user before it expires
+				if authManager.NeedsRefresh(claims, time.Now()) {
+					if newJWT, err = authManager.Refresh(claims); err != nil {
+						logger.Errorw("failed to refresh token", "error", err)
+					} else {
+						setAuthCookie(w, newJWT, authManager.TokenTTL())
+					}
+				}
 			}
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// setAuthCookie sets the auth cookie to a token that expires after ttl.
+func setAuthCookie(w http.ResponseWriter, token string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "Authorization",
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+	})
+}
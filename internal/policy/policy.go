@@ -0,0 +1,302 @@
+// Package policy decides which destination URLs may be shortened.
+//
+// A policy consists of allow and deny rules matched against the scheme, host and path of a URL.
+// Deny rules are checked first and reject on any match. Allow rules are grouped into
+// scheme, host (exact hosts, domain suffixes and CIDRs) and path groups; when a group is
+// non-empty the URL must match at least one of its entries.
+// Links to private, loopback and link-local addresses are rejected unless explicitly allowed.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Machine-readable rejection reasons.
+const (
+	ReasonInvalidURL     = "invalid_url"
+	ReasonDeniedScheme   = "denied_scheme"
+	ReasonDeniedHost     = "denied_host"
+	ReasonDeniedDomain   = "denied_domain"
+	ReasonDeniedNetwork  = "denied_network"
+	ReasonDeniedPath     = "denied_path"
+	ReasonPrivateAddress = "private_address"
+	ReasonNotAllowed     = "not_allowed"
+	ReasonMaliciousURL   = "malicious_url"
+
+	ReasonSelfReference        = "self_reference"
+	ReasonRedirectLoop         = "redirect_loop"
+	ReasonRedirectChainTooLong = "redirect_chain_too_long"
+)
+
+// Violation is returned when a URL is rejected by the policy.
+type Violation struct {
+	Reason string // Reason is one of the Reason* constants
+	Detail string // Detail is a human-readable description of the matched rule
+}
+
+// Error returns the error message for Violation.
+func (v *Violation) Error() string {
+	return fmt.Sprintf("URL rejected by policy: %s (%s)", v.Reason, v.Detail)
+}
+
+// Rules is a set of matchers used for either the allow or the deny list.
+type Rules struct {
+	DomainSuffixes []string `json:"domain_suffixes"` // e.g. "example.com" matches example.com and any subdomain
+	Hosts          []string `json:"hosts"`           // exact host names or IP addresses
+	CIDRs          []string `json:"cidrs"`           // networks matched against IP hosts
+	Schemes        []string `json:"schemes"`         // e.g. "https"
+	PathRegexes    []string `json:"path_regexes"`    // regular expressions matched against the path
+}
+
+// File is the on-disk representation of a policy.
+type File struct {
+	Allow           Rules `json:"allow"`
+	Deny            Rules `json:"deny"`
+	AllowPrivateIPs bool  `json:"allow_private_ips"` // disables the built-in private address check
+}
+
+// compiledRules holds parsed matchers.
+type compiledRules struct {
+	suffixes []string
+	hosts    map[string]struct{}
+	networks []*net.IPNet
+	schemes  map[string]struct{}
+	paths    []*regexp.Regexp
+}
+
+// Engine evaluates URLs against a reloadable policy.
+type Engine struct {
+	mu              sync.RWMutex
+	path            string
+	allow           *compiledRules
+	deny            *compiledRules
+	allowPrivateIPs bool
+}
+
+// New creates an engine from an in-memory policy.
+func New(f File) (*Engine, error) {
+	e := &Engine{}
+	if err := e.set(f); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Load creates an engine from a JSON policy file.
+// If path is empty, the default policy is used, which only rejects private addresses.
+func Load(path string) (*Engine, error) {
+	e := &Engine{path: path}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload re-reads the policy from the file the engine was loaded from.
+// On error the previous policy stays in effect.
+func (e *Engine) Reload() error {
+	if e.path == "" {
+		return e.set(File{})
+	}
+
+	file, err := os.Open(e.path)
+	if err != nil {
+		return fmt.Errorf("failed to open policy file: %w", err)
+	}
+	defer file.Close()
+
+	var f File
+	if err = json.NewDecoder(file).Decode(&f); err != nil {
+		return fmt.Errorf("failed to decode policy file: %w", err)
+	}
+	return e.set(f)
+}
+
+// Check returns a *Violation if rawURL may not be shortened.
+// A nil engine allows everything.
+func (e *Engine) Check(rawURL string) error {
+	if e == nil {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &Violation{Reason: ReasonInvalidURL, Detail: err.Error()}
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	ip := net.ParseIP(host)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, ok := e.deny.schemes[scheme]; ok {
+		return &Violation{Reason: ReasonDeniedScheme, Detail: scheme}
+	}
+	if _, ok := e.deny.hosts[host]; ok {
+		return &Violation{Reason: ReasonDeniedHost, Detail: host}
+	}
+	if s, ok := matchSuffix(e.deny.suffixes, host); ok {
+		return &Violation{Reason: ReasonDeniedDomain, Detail: s}
+	}
+	if n, ok := matchNetwork(e.deny.networks, ip); ok {
+		return &Violation{Reason: ReasonDeniedNetwork, Detail: n.String()}
+	}
+	if re, ok := matchPath(e.deny.paths, u.EscapedPath()); ok {
+		return &Violation{Reason: ReasonDeniedPath, Detail: re.String()}
+	}
+
+	if !e.allowPrivateIPs && isPrivateHost(host, ip) {
+		if _, ok := matchNetwork(e.allow.networks, ip); !ok {
+			return &Violation{Reason: ReasonPrivateAddress, Detail: host}
+		}
+	}
+
+	if len(e.allow.schemes) > 0 {
+		if _, ok := e.allow.schemes[scheme]; !ok {
+			return &Violation{Reason: ReasonNotAllowed, Detail: "scheme " + scheme}
+		}
+	}
+	if len(e.allow.hosts) > 0 || len(e.allow.suffixes) > 0 || len(e.allow.networks) > 0 {
+		_, hostOK := e.allow.hosts[host]
+		_, suffixOK := matchSuffix(e.allow.suffixes, host)
+		_, networkOK := matchNetwork(e.allow.networks, ip)
+		if !hostOK && !suffixOK && !networkOK {
+			return &Violation{Reason: ReasonNotAllowed, Detail: "host " + host}
+		}
+	}
+	if len(e.allow.paths) > 0 {
+		if _, ok := matchPath(e.allow.paths, u.EscapedPath()); !ok {
+			return &Violation{Reason: ReasonNotAllowed, Detail: "path " + u.EscapedPath()}
+		}
+	}
+
+	return nil
+}
+
+// CheckAddress returns a *Violation if connections to ip are not allowed: when it is in a denied
+// network, or a private address that is not explicitly allowed. Unlike Check it ignores host,
+// scheme and path rules, so it can be applied to the addresses names resolve to when dialing.
+// A nil engine allows everything.
+func (e *Engine) CheckAddress(ip net.IP) error {
+	if e == nil {
+		return nil
+	}
+
+	if ip == nil {
+		return &Violation{Reason: ReasonInvalidURL, Detail: "missing IP address"}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if n, ok := matchNetwork(e.deny.networks, ip); ok {
+		return &Violation{Reason: ReasonDeniedNetwork, Detail: n.String()}
+	}
+	if !e.allowPrivateIPs && isPrivateHost("", ip) {
+		if _, ok := matchNetwork(e.allow.networks, ip); !ok {
+			return &Violation{Reason: ReasonPrivateAddress, Detail: ip.String()}
+		}
+	}
+	return nil
+}
+
+// set compiles f and replaces the active policy.
+func (e *Engine) set(f File) error {
+	allow, err := compile(f.Allow)
+	if err != nil {
+		return fmt.Errorf("invalid allow rules: %w", err)
+	}
+	deny, err := compile(f.Deny)
+	if err != nil {
+		return fmt.Errorf("invalid deny rules: %w", err)
+	}
+
+	e.mu.Lock()
+	e.allow = allow
+	e.deny = deny
+	e.allowPrivateIPs = f.AllowPrivateIPs
+	e.mu.Unlock()
+	return nil
+}
+
+// compile parses the matchers of r.
+func compile(r Rules) (*compiledRules, error) {
+	c := &compiledRules{
+		hosts:   make(map[string]struct{}),
+		schemes: make(map[string]struct{}),
+	}
+	for _, s := range r.DomainSuffixes {
+		c.suffixes = append(c.suffixes, strings.TrimPrefix(strings.ToLower(s), "."))
+	}
+	for _, h := range r.Hosts {
+		c.hosts[strings.ToLower(h)] = struct{}{}
+	}
+	for _, cidr := range r.CIDRs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		c.networks = append(c.networks, n)
+	}
+	for _, s := range r.Schemes {
+		c.schemes[strings.ToLower(s)] = struct{}{}
+	}
+	for _, p := range r.PathRegexes {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path regex %q: %w", p, err)
+		}
+		c.paths = append(c.paths, re)
+	}
+	return c, nil
+}
+
+// matchSuffix returns the first suffix that host equals or is a subdomain of.
+func matchSuffix(suffixes []string, host string) (string, bool) {
+	for _, s := range suffixes {
+		if host == s || strings.HasSuffix(host, "."+s) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// matchNetwork returns the first network containing ip.
+func matchNetwork(networks []*net.IPNet, ip net.IP) (*net.IPNet, bool) {
+	if ip == nil {
+		return nil, false
+	}
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// matchPath returns the first regular expression matching path.
+func matchPath(paths []*regexp.Regexp, path string) (*regexp.Regexp, bool) {
+	for _, re := range paths {
+		if re.MatchString(path) {
+			return re, true
+		}
+	}
+	return nil, false
+}
+
+// isPrivateHost reports whether the host points into a non-public network.
+func isPrivateHost(host string, ip net.IP) bool {
+	if ip == nil {
+		return host == "localhost" || strings.HasSuffix(host, ".localhost")
+	}
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsInterfaceLocalMulticast()
+}
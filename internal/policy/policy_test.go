@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestEngine_Check(t *testing.T) {
+	deny, err := New(File{
+		Deny: Rules{
+			DomainSuffixes: []string{"evil.com"},
+			Hosts:          []string{"bad.example.org"},
+			CIDRs:          []string{"203.0.113.0/24"},
+			Schemes:        []string{"http"},
+			PathRegexes:    []string{`^/admin`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+
+	allow, err := New(File{
+		Allow: Rules{
+			DomainSuffixes: []string{"example.com"},
+			CIDRs:          []string{"10.1.0.0/16"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		engine *Engine
+		url    string
+		reason string
+	}{
+		{name: "Allowed", engine: deny, url: "https://example.com/"},
+		{name: "Denied scheme", engine: deny, url: "http://example.com/", reason: ReasonDeniedScheme},
+		{name: "Denied suffix", engine: deny, url: "https://www.evil.com/", reason: ReasonDeniedDomain},
+		{name: "Denied host", engine: deny, url: "https://bad.example.org/", reason: ReasonDeniedHost},
+		{name: "Denied network", engine: deny, url: "https://203.0.113.7/", reason: ReasonDeniedNetwork},
+		{name: "Denied path", engine: deny, url: "https://example.com/admin/x", reason: ReasonDeniedPath},
+		{name: "Private address", engine: deny, url: "https://192.168.1.1/", reason: ReasonPrivateAddress},
+		{name: "Loopback name", engine: deny, url: "https://localhost/", reason: ReasonPrivateAddress},
+		{name: "Allowed suffix", engine: allow, url: "https://www.example.com/"},
+		{name: "Allowed private network", engine: allow, url: "https://10.1.2.3/"},
+		{name: "Not allowed host", engine: allow, url: "https://example.net/", reason: ReasonNotAllowed},
+		{name: "Nil engine", engine: nil, url: "https://192.168.1.1/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.engine.Check(tt.url)
+			if tt.reason == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			var v *Violation
+			if !errors.As(err, &v) {
+				t.Fatalf("expected violation, got %v", err)
+			}
+			if v.Reason != tt.reason {
+				t.Errorf("expected reason %s, got %s", tt.reason, v.Reason)
+			}
+		})
+	}
+}
+
+func TestEngine_Default(t *testing.T) {
+	e, err := Load("")
+	if err != nil {
+		t.Fatalf("failed to load default policy: %v", err)
+	}
+	if err = e.Check("https://practicum.yandex.ru/"); err != nil {
+		t.Errorf("expected public URL to be allowed, got %v", err)
+	}
+	if err = e.Check("http://127.0.0.1:8080/"); err == nil {
+		t.Errorf("expected loopback URL to be rejected")
+	}
+}
+
+func TestEngine_CheckAddress(t *testing.T) {
+	e, err := New(File{
+		Allow: Rules{Hosts: []string{"example.com"}, CIDRs: []string{"10.1.0.0/16"}},
+		Deny:  Rules{CIDRs: []string{"203.0.113.0/24"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		ip     string
+		reason string
+	}{
+		{name: "Public", ip: "93.184.216.34"},
+		{name: "Denied network", ip: "203.0.113.7", reason: ReasonDeniedNetwork},
+		{name: "Loopback", ip: "127.0.0.1", reason: ReasonPrivateAddress},
+		{name: "Metadata service", ip: "169.254.169.254", reason: ReasonPrivateAddress},
+		{name: "IPv6 loopback", ip: "::1", reason: ReasonPrivateAddress},
+		{name: "Allowed private network", ip: "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.CheckAddress(net.ParseIP(tt.ip))
+			var v *Violation
+			if tt.reason == "" && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if tt.reason != "" && (!errors.As(err, &v) || v.Reason != tt.reason) {
+				t.Errorf("expected reason %s, got %v", tt.reason, err)
+			}
+		})
+	}
+}
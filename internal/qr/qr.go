@@ -0,0 +1,212 @@
+// Package qr renders QR codes of short links as PNG or SVG images.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Image formats.
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Limits and defaults of the image options.
+const (
+	defaultSize   = 256
+	minSize       = 64
+	maxSize       = 2048
+	defaultMargin = 4 // the quiet zone required by the QR specification
+	maxMargin     = 16
+)
+
+// Options control how a QR code is rendered.
+type Options struct {
+	Format     string               // Format is FormatPNG or FormatSVG
+	Size       int                  // Size is the width and height of the image in pixels
+	Level      qrcode.RecoveryLevel // Level is the error correction level
+	Margin     int                  // Margin is the width of the quiet zone in modules
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// levels maps the error correction letters to recovery levels.
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// ParseOptions reads the image options from query parameters:
+// format (png or svg), size in pixels, ecc (L, M, Q or H), margin in modules,
+// and fg and bg colours as hex RGB. Missing parameters take their defaults.
+func ParseOptions(query url.Values) (Options, error) {
+	o := Options{
+		Format:     FormatPNG,
+		Size:       defaultSize,
+		Level:      qrcode.Medium,
+		Margin:     defaultMargin,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+
+	if v := query.Get("format"); v != "" {
+		v = strings.ToLower(v)
+		if v != FormatPNG && v != FormatSVG {
+			return o, errors.New("format must be png or svg")
+		}
+		o.Format = v
+	}
+	if v := query.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < minSize || size > maxSize {
+			return o, fmt.Errorf("size must be between %d and %d pixels", minSize, maxSize)
+		}
+		o.Size = size
+	}
+	if v := query.Get("ecc"); v != "" {
+		level, ok := levels[strings.ToUpper(v)]
+		if !ok {
+			return o, errors.New("ecc must be L, M, Q or H")
+		}
+		o.Level = level
+	}
+	if v := query.Get("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil || margin < 0 || margin > maxMargin {
+			return o, fmt.Errorf("margin must be between 0 and %d modules", maxMargin)
+		}
+		o.Margin = margin
+	}
+	var err error
+	if v := query.Get("fg"); v != "" {
+		if o.Foreground, err = parseColor(v); err != nil {
+			return o, fmt.Errorf("fg: %w", err)
+		}
+	}
+	if v := query.Get("bg"); v != "" {
+		if o.Background, err = parseColor(v); err != nil {
+			return o, fmt.Errorf("bg: %w", err)
+		}
+	}
+	if o.Foreground == o.Background {
+		return o, errors.New("fg and bg must differ")
+	}
+	return o, nil
+}
+
+// ContentType returns the MIME type of the image format.
+func (o Options) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// Encode renders content as a QR code image.
+func Encode(content string, o Options) ([]byte, error) {
+	code, err := qrcode.New(content, o.Level)
+	if err != nil {
+		return nil, err
+	}
+	// The quiet zone is drawn here so that its width can be chosen
+	code.DisableBorder = true
+	bitmap := code.Bitmap()
+
+	modules := len(bitmap) + 2*o.Margin
+	scale := o.Size / modules
+	if scale < 1 {
+		return nil, errors.New("size is too small for the code")
+	}
+	// Integer scaling keeps modules sharp; the rest of the image is padded evenly
+	offset := (o.Size - scale*modules) / 2
+
+	if o.Format == FormatSVG {
+		return encodeSVG(bitmap, o, scale, offset), nil
+	}
+	return encodePNG(bitmap, o, scale, offset)
+}
+
+// encodePNG draws bitmap as a two-colour PNG image.
+func encodePNG(bitmap [][]bool, o Options, scale, offset int) ([]byte, error) {
+	img := image.NewPaletted(image.Rect(0, 0, o.Size, o.Size), color.Palette{o.Background, o.Foreground})
+	start := offset + o.Margin*scale
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for py := start + y*scale; py < start+(y+1)*scale; py++ {
+				for px := start + x*scale; px < start+(x+1)*scale; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeSVG draws bitmap as an SVG image with one path for all dark modules.
+func encodeSVG(bitmap [][]bool, o Options, scale, offset int) []byte {
+	var path strings.Builder
+	start := offset + o.Margin*scale
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			// Runs of dark modules on a row are drawn as one rectangle
+			run := 1
+			for x+run < len(row) && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv%dh-%dz", start+x*scale, start+y*scale, run*scale, scale, run*scale)
+			x += run - 1
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		o.Size, o.Size, o.Size, o.Size)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, hexColor(o.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="%s"/>`, hexColor(o.Foreground), path.String())
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// parseColor parses a colour written as RGB or RRGGBB hex digits with an optional leading "#".
+func parseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, errors.New("colour must be RGB or RRGGBB hex")
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, errors.New("colour must be RGB or RRGGBB hex")
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// hexColor formats c as a #rrggbb colour.
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
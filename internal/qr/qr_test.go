@@ -0,0 +1,62 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{name: "Defaults", query: ""},
+		{name: "All options", query: "format=svg&size=512&ecc=H&margin=2&fg=%23112233&bg=fff"},
+		{name: "Unknown format", query: "format=gif", wantErr: true},
+		{name: "Size too small", query: "size=10", wantErr: true},
+		{name: "Unknown level", query: "ecc=X", wantErr: true},
+		{name: "Negative margin", query: "margin=-1", wantErr: true},
+		{name: "Bad colour", query: "fg=zzzzzz", wantErr: true},
+		{name: "Same colours", query: "fg=000&bg=000000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			_, err := ParseOptions(query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	query, _ := url.ParseQuery("size=300&margin=0")
+	o, _ := ParseOptions(query)
+
+	data, err := Encode("http://localhost:8080/abcd1234", o)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 300 {
+		t.Errorf("expected 300x300 image, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	o.Format = FormatSVG
+	data, err = Encode("http://localhost:8080/abcd1234", o)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("<svg")) || !strings.Contains(string(data), `width="300"`) {
+		t.Errorf("unexpected SVG: %s", data)
+	}
+}
@@ -0,0 +1,131 @@
+// Package ratelimit limits repeated failures, such as wrong password attempts, per key.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneThreshold is the number of tracked keys above which expired entries are dropped.
+const pruneThreshold = 10000
+
+// Limiter counts failures per key in fixed time windows.
+type Limiter struct {
+	mu      sync.Mutex
+	max     int
+	window  time.Duration
+	now     func() time.Time
+	entries map[string]*entry
+}
+
+// entry holds the failures of a key in the current window.
+type entry struct {
+	failures int
+	start    time.Time
+}
+
+// New creates a limiter that blocks a key after max failures within window.
+func New(max int, window time.Duration) *Limiter {
+	return &Limiter{
+		max:     max,
+		window:  window,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Allow reports whether another attempt is allowed for key.
+// A nil limiter or a limiter with a non-positive maximum allows everything.
+func (l *Limiter) Allow(key string) bool {
+	if l == nil || l.max <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok || l.now().Sub(e.start) >= l.window {
+		return true
+	}
+	return e.failures < l.max
+}
+
+// Fail records a failed attempt for key.
+func (l *Limiter) Fail(key string) {
+	if l == nil || l.max <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.fail(key, l.now())
+}
+
+// Reserve counts an attempt for key as failed in advance and reports whether it is allowed.
+// Checking and counting happen at once, so concurrent attempts cannot all pass before the
+// first failure is recorded. An attempt that turns out not to fail is given back with Refund
+// or Reset. A nil limiter or a limiter with a non-positive maximum allows everything.
+func (l *Limiter) Reserve(key string) bool {
+	if l == nil || l.max <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if e, ok := l.entries[key]; ok && now.Sub(e.start) < l.window && e.failures >= l.max {
+		return false
+	}
+	l.fail(key, now)
+	return true
+}
+
+// Refund gives back an attempt reserved for key that did not fail.
+func (l *Limiter) Refund(key string) {
+	if l == nil || l.max <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.entries[key]; ok && l.now().Sub(e.start) < l.window && e.failures > 0 {
+		e.failures--
+	}
+}
+
+// fail records a failed attempt for key. The caller must hold the lock.
+func (l *Limiter) fail(key string, now time.Time) {
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.start) >= l.window {
+		if len(l.entries) >= pruneThreshold {
+			l.prune(now)
+		}
+		l.entries[key] = &entry{failures: 1, start: now}
+		return
+	}
+	e.failures++
+}
+
+// Reset forgets the failures of key, e.g. after a successful attempt.
+func (l *Limiter) Reset(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	delete(l.entries, key)
+	l.mu.Unlock()
+}
+
+// prune drops entries whose window has passed. The caller must hold the lock.
+func (l *Limiter) prune(now time.Time) {
+	for key, e := range l.entries {
+		if now.Sub(e.start) >= l.window {
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		fail    bool
+		reset   bool
+		want    bool
+	}{
+		{name: "First attempt", want: true},
+		{name: "After one failure", fail: true, want: true},
+		{name: "After two failures", fail: true, want: false},
+		{name: "Window passed", advance: time.Minute, want: true},
+		{name: "First failure in new window", fail: true, want: true},
+		{name: "Second failure in new window", fail: true, want: false},
+		{name: "Reset", reset: true, want: true},
+	}
+
+	for _, s := range steps {
+		now = now.Add(s.advance)
+		if s.fail {
+			l.Fail("key")
+		}
+		if s.reset {
+			l.Reset("key")
+		}
+		if got := l.Allow("key"); got != s.want {
+			t.Errorf("%s: expected Allow %v, got %v", s.name, s.want, got)
+		}
+		if !l.Allow("other") {
+			t.Errorf("%s: unrelated key must not be limited", s.name)
+		}
+	}
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	l := New(2, time.Minute)
+
+	if !l.Reserve("key") || !l.Reserve("key") {
+		t.Fatalf("expected the first two attempts to be allowed")
+	}
+	if l.Reserve("key") {
+		t.Errorf("expected a third attempt to be refused while two are reserved")
+	}
+
+	l.Refund("key")
+	if !l.Reserve("key") {
+		t.Errorf("expected a refunded attempt to be available again")
+	}
+	if l.Allow("key") {
+		t.Errorf("expected reserved attempts to count as failures")
+	}
+}
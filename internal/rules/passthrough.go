@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// maxUTMParams is the largest number of stored query parameters a link may have.
+const maxUTMParams = 20
+
+// ValidatePassthrough checks the query forwarding, UTM and path suffix settings.
+func ValidatePassthrough(opts types.LinkOptions) error {
+	switch opts.ForwardQuery {
+	case "", types.ForwardQueryKeep, types.ForwardQueryOverride, types.ForwardQueryAppend:
+	default:
+		return fmt.Errorf("forward_query must be one of keep, override or append")
+	}
+	if len(opts.UTM) > maxUTMParams {
+		return fmt.Errorf("at most %d utm parameters are allowed", maxUTMParams)
+	}
+	for name := range opts.UTM {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("utm parameter names must not be empty")
+		}
+	}
+	return nil
+}
+
+// ValidSuffix reports whether a path suffix may be appended to a destination.
+// Dot segments are rejected so that a suffix cannot climb above the destination path.
+func ValidSuffix(suffix string) bool {
+	for _, segment := range strings.Split(suffix, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+		if unescaped, err := url.PathUnescape(segment); err != nil || unescaped == "." || unescaped == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// Expand applies the query forwarding, UTM and path suffix settings of the link to destination.
+// incoming is the query of the request and suffix the escaped path after the short code.
+func Expand(destination string, urlData types.URLData, incoming url.Values, suffix string, now time.Time) (string, error) {
+	opts := urlData.Options
+	if opts == nil || opts.ForwardQuery == "" && len(opts.UTM) == 0 && (!opts.PathSuffix || suffix == "") {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("invalid destination: %w", err)
+	}
+
+	if opts.PathSuffix && suffix != "" {
+		u = u.JoinPath(strings.Split(suffix, "/")...)
+	}
+
+	query := u.Query()
+	for name, value := range opts.UTM {
+		query.Set(name, expandTemplate(value, urlData.ShortURL, now))
+	}
+	for name, values := range incoming {
+		_, exists := query[name]
+		switch {
+		case opts.ForwardQuery == "":
+		case !exists, opts.ForwardQuery == types.ForwardQueryOverride:
+			query[name] = values
+		case opts.ForwardQuery == types.ForwardQueryAppend:
+			query[name] = append(query[name], values...)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// expandTemplate replaces the {code} and {date} placeholders of a stored parameter value.
+func expandTemplate(value, code string, now time.Time) string {
+	return strings.NewReplacer("{code}", code, "{date}", now.UTC().Format(time.DateOnly)).Replace(value)
+}
@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+func TestExpand(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		destination string
+		opts        *types.LinkOptions
+		query       string
+		suffix      string
+		expected    string
+	}{
+		{
+			name:        "No options",
+			destination: "https://example.com/page?a=1",
+			query:       "a=2",
+			expected:    "https://example.com/page?a=1",
+		},
+		{
+			name:        "Keep destination values",
+			destination: "https://example.com/page?a=1",
+			opts:        &types.LinkOptions{ForwardQuery: types.ForwardQueryKeep},
+			query:       "a=2&b=3",
+			expected:    "https://example.com/page?a=1&b=3",
+		},
+		{
+			name:        "Override destination values",
+			destination: "https://example.com/page?a=1",
+			opts:        &types.LinkOptions{ForwardQuery: types.ForwardQueryOverride},
+			query:       "a=2",
+			expected:    "https://example.com/page?a=2",
+		},
+		{
+			name:        "Append values",
+			destination: "https://example.com/page?a=1",
+			opts:        &types.LinkOptions{ForwardQuery: types.ForwardQueryAppend},
+			query:       "a=2",
+			expected:    "https://example.com/page?a=1&a=2",
+		},
+		{
+			name:        "UTM template",
+			destination: "https://example.com/page",
+			opts:        &types.LinkOptions{UTM: map[string]string{"utm_source": "short", "utm_campaign": "{code}-{date}"}},
+			expected:    "https://example.com/page?utm_campaign=abc-2024-06-01&utm_source=short",
+		},
+		{
+			name:        "Path suffix",
+			destination: "https://example.com/docs",
+			opts:        &types.LinkOptions{PathSuffix: true},
+			suffix:      "guide/intro%20page",
+			expected:    "https://example.com/docs/guide/intro%20page",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			urlData := types.URLData{ShortURL: "abc", Options: tt.opts}
+			got, err := Expand(tt.destination, urlData, query, tt.suffix, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidSuffix(t *testing.T) {
+	for suffix, want := range map[string]bool{
+		"a/b":        true,
+		"a/../b":     false,
+		"%2e%2e/etc": false,
+		"./a":        false,
+	} {
+		if got := ValidSuffix(suffix); got != want {
+			t.Errorf("ValidSuffix(%q) = %v, want %v", suffix, got, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package rules chooses and builds the destination of a short link from its redirect options:
+// conditional rules, weighted splits, query forwarding and path suffixes.
+package rules
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+	"github.com/jayjaytrn/URLShortener/internal/useragent"
+)
+
+// Limits of the redirect options of a link.
+const (
+	MaxRules    = 20    // MaxRules is the largest number of rules a link may have
+	MaxVariants = 10    // MaxVariants is the largest number of split variants a link may have
+	maxWeight   = 10000 // maxWeight is the largest weight of a single variant
+)
+
+// Validate checks the structure of a rule list. Destination URLs are checked separately.
+func Validate(rules []types.RedirectRule) error {
+	if len(rules) > MaxRules {
+		return fmt.Errorf("at most %d rules are allowed", MaxRules)
+	}
+	for i, r := range rules {
+		if r.URL == "" {
+			return fmt.Errorf("rule %d: url is required", i)
+		}
+		if r.UserAgent == "" && r.Language == "" && r.Header == "" && r.From == nil && r.Until == nil {
+			return fmt.Errorf("rule %d: at least one condition is required", i)
+		}
+		if r.Value != "" && r.Header == "" {
+			return fmt.Errorf("rule %d: value requires header", i)
+		}
+		if r.From != nil && r.Until != nil && !r.From.Before(*r.Until) {
+			return fmt.Errorf("rule %d: from must be before until", i)
+		}
+	}
+	return nil
+}
+
+// ValidateVariants checks the variants of a weighted split.
+func ValidateVariants(variants []types.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) < 2 || len(variants) > MaxVariants {
+		return fmt.Errorf("a split needs between 2 and %d variants", MaxVariants)
+	}
+	for i, v := range variants {
+		if v.URL == "" {
+			return fmt.Errorf("variant %d: url is required", i)
+		}
+		if v.Weight <= 0 || v.Weight > maxWeight {
+			return fmt.Errorf("variant %d: weight must be between 1 and %d", i, maxWeight)
+		}
+	}
+	return nil
+}
+
+// Destination returns the URL of the first rule matching the request.
+// If no rule matches, it returns the original URL of the link and false.
+func Destination(urlData types.URLData, req *http.Request, now time.Time) (string, bool) {
+	if urlData.Options == nil {
+		return urlData.OriginalURL, false
+	}
+	for _, r := range urlData.Options.Rules {
+		if Match(r, req, now) {
+			return r.URL, true
+		}
+	}
+	return urlData.OriginalURL, false
+}
+
+// ChooseVariant picks a variant by weight. The same key always yields the same variant
+// as long as the variants do not change.
+func ChooseVariant(variants []types.Variant, key string) int {
+	total := 0
+	for _, v := range variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	point := int(h.Sum64() % uint64(total))
+	for i, v := range variants {
+		if point < v.Weight {
+			return i
+		}
+		point -= v.Weight
+	}
+	return len(variants) - 1
+}
+
+// Match reports whether the request satisfies every condition of the rule.
+func Match(r types.RedirectRule, req *http.Request, now time.Time) bool {
+	if r.From != nil && now.Before(*r.From) {
+		return false
+	}
+	if r.Until != nil && !now.Before(*r.Until) {
+		return false
+	}
+	if r.UserAgent != "" && !useragent.Parse(req.UserAgent()).Is(r.UserAgent) {
+		return false
+	}
+	if r.Language != "" && !matchLanguage(PreferredLanguage(req.Header.Get("Accept-Language")), r.Language) {
+		return false
+	}
+	if r.Header != "" {
+		values := req.Header.Values(r.Header)
+		if len(values) == 0 {
+			return false
+		}
+		if r.Value != "" && !strings.EqualFold(strings.TrimSpace(values[0]), r.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Vary returns the request headers the rules depend on, for the Vary response header.
+func Vary(rules []types.RedirectRule) []string {
+	var headers []string
+	seen := map[string]struct{}{}
+	add := func(h string) {
+		h = http.CanonicalHeaderKey(h)
+		if _, ok := seen[h]; !ok {
+			seen[h] = struct{}{}
+			headers = append(headers, h)
+		}
+	}
+	for _, r := range rules {
+		if r.UserAgent != "" {
+			add("User-Agent")
+		}
+		if r.Language != "" {
+			add("Accept-Language")
+		}
+		if r.Header != "" {
+			add(r.Header)
+		}
+	}
+	return headers
+}
+
+// PreferredLanguage returns the language tag with the highest quality from an Accept-Language header.
+func PreferredLanguage(header string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" || name == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			tags = append(tags, tag{name: strings.ToLower(name), q: q})
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	return tags[0].name
+}
+
+// matchLanguage reports whether tag equals want or is a subtag of it, e.g. "de-at" for "de".
+func matchLanguage(tag, want string) bool {
+	want = strings.ToLower(want)
+	return tag == want || strings.HasPrefix(tag, want+"-")
+}
@@ -0,0 +1,123 @@
+package rules
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+func TestDestination(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	start := now.Add(-time.Hour)
+	end := now.Add(time.Hour)
+
+	urlData := types.URLData{
+		OriginalURL: "https://example.com/",
+		Options: &types.LinkOptions{Rules: []types.RedirectRule{
+			{UserAgent: "ios", URL: "https://apps.apple.com/app"},
+			{UserAgent: "android", URL: "https://play.google.com/app"},
+			{Header: "X-Country", Value: "de", URL: "https://example.de/"},
+			{Language: "fr", From: &start, Until: &end, URL: "https://example.fr/sale"},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		now      time.Time
+		expected string
+	}{
+		{
+			name:     "iPhone",
+			headers:  map[string]string{"User-Agent": "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148 Safari/604.1"},
+			expected: "https://apps.apple.com/app",
+		},
+		{
+			name:     "Android",
+			headers:  map[string]string{"User-Agent": "Mozilla/5.0 (Linux; Android 14; Pixel 8) Chrome/120.0.0.0 Mobile Safari/537.36"},
+			expected: "https://play.google.com/app",
+		},
+		{
+			name:     "Country header",
+			headers:  map[string]string{"X-Country": "DE"},
+			expected: "https://example.de/",
+		},
+		{
+			name:     "Language in window",
+			headers:  map[string]string{"Accept-Language": "en;q=0.5, fr-CA"},
+			expected: "https://example.fr/sale",
+		},
+		{
+			name:     "Language after window",
+			headers:  map[string]string{"Accept-Language": "fr"},
+			now:      end,
+			expected: "https://example.com/",
+		},
+		{
+			name:     "Fallback",
+			headers:  map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Firefox/121.0"},
+			expected: "https://example.com/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/abc", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			at := tt.now
+			if at.IsZero() {
+				at = now
+			}
+			if got, _ := Destination(urlData, req, at); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []types.RedirectRule
+		wantErr bool
+	}{
+		{name: "Valid", rules: []types.RedirectRule{{UserAgent: "ios", URL: "https://example.com/"}}},
+		{name: "Missing URL", rules: []types.RedirectRule{{UserAgent: "ios"}}, wantErr: true},
+		{name: "No condition", rules: []types.RedirectRule{{URL: "https://example.com/"}}, wantErr: true},
+		{name: "Value without header", rules: []types.RedirectRule{{Value: "de", URL: "https://example.com/"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rules); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestChooseVariant(t *testing.T) {
+	variants := []types.Variant{
+		{URL: "https://example.com/a", Weight: 70},
+		{URL: "https://example.com/b", Weight: 30},
+	}
+
+	counts := make([]int, len(variants))
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("visitor-%d", i)
+		v := ChooseVariant(variants, key)
+		if again := ChooseVariant(variants, key); again != v {
+			t.Fatalf("visitor %s moved from variant %d to %d", key, v, again)
+		}
+		counts[v]++
+	}
+
+	if counts[0] < 6500 || counts[0] > 7500 {
+		t.Errorf("expected about 7000 visitors on the first variant, got %d", counts[0])
+	}
+}
@@ -0,0 +1,272 @@
+// Package threat checks URLs against a locally stored list of hashed malicious URL expressions.
+//
+// The list follows the Safe Browsing hashing scheme. Every URL is expanded into host suffix and
+// path prefix expressions such as "a.b.example.com/1/2.html?x=1" or "example.com/". Each expression
+// is hashed with SHA-256, and the URL is listed if any hash starts with one of the hex-encoded
+// prefixes in the list file. Prefixes must be at least 8 hex characters (4 bytes) long; full
+// 64-character hashes are matched exactly. Listing "example.com/" therefore blocks the whole host.
+package threat
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// minPrefixLength is the shortest accepted hash prefix in hex characters.
+const minPrefixLength = 8
+
+// List is a reloadable set of hashed URL expressions.
+type List struct {
+	mu       sync.RWMutex
+	path     string
+	modTime  time.Time
+	prefixes map[string]struct{}
+	lengths  []int
+}
+
+// Load creates a list from a file with one hex-encoded hash prefix per line.
+// Empty lines and lines starting with '#' are ignored. If path is empty, the list is empty.
+func Load(path string) (*List, error) {
+	l := &List{path: path, prefixes: map[string]struct{}{}}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload re-reads the list file. On error the previous contents are kept.
+func (l *List) Reload() error {
+	if l.path == "" {
+		return nil
+	}
+
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat threat list: %w", err)
+	}
+
+	prefixes, err := readPrefixes(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read threat list: %w", err)
+	}
+
+	lengthSet := map[int]struct{}{}
+	for p := range prefixes {
+		lengthSet[len(p)] = struct{}{}
+	}
+	lengths := make([]int, 0, len(lengthSet))
+	for n := range lengthSet {
+		lengths = append(lengths, n)
+	}
+
+	l.mu.Lock()
+	l.prefixes = prefixes
+	l.lengths = lengths
+	l.modTime = fi.ModTime()
+	l.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the list whenever the file changes, checking every interval until ctx is done.
+// onReload is called after every successful reload.
+func (l *List) Watch(ctx context.Context, interval time.Duration, onReload func(), onError func(error)) {
+	if l.path == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fi, err := os.Stat(l.path)
+			if err != nil {
+				onError(err)
+				continue
+			}
+
+			l.mu.RLock()
+			changed := !fi.ModTime().Equal(l.modTime)
+			l.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err = l.Reload(); err != nil {
+				onError(err)
+				continue
+			}
+			onReload()
+		}
+	}
+}
+
+// Listed reports whether rawURL matches an entry of the list. A nil list matches nothing.
+func (l *List) Listed(rawURL string) bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if len(l.prefixes) == 0 {
+		return false
+	}
+
+	for _, expr := range Expressions(rawURL) {
+		h := Hash(expr)
+		for _, n := range l.lengths {
+			if _, ok := l.prefixes[h[:n]]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Hash returns the hex-encoded SHA-256 hash of an expression, as stored in list files.
+func Hash(expr string) string {
+	sum := sha256.Sum256([]byte(expr))
+	return hex.EncodeToString(sum[:])
+}
+
+// Expressions returns the host suffix / path prefix combinations checked for rawURL.
+func Expressions(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	var exprs []string
+	for _, host := range hostSuffixes(strings.ToLower(u.Hostname())) {
+		for _, path := range pathPrefixes(u.EscapedPath(), u.RawQuery) {
+			exprs = append(exprs, host+path)
+		}
+	}
+	return exprs
+}
+
+// FlagListed marks every stored link with a destination on the list.
+// It returns the number of newly flagged links.
+func FlagListed(ctx context.Context, storage db.ShortenerStorage, l *List) (int, error) {
+	var listed []string
+	err := storage.ForEachURL(ctx, func(urlData types.URLData) error {
+		if urlData.Flagged {
+			return nil
+		}
+		for _, u := range urlData.Destinations() {
+			if l.Listed(u) {
+				listed = append(listed, urlData.ShortURL)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan stored URLs: %w", err)
+	}
+
+	if len(listed) == 0 {
+		return 0, nil
+	}
+	if err = storage.SetFlagged(ctx, listed); err != nil {
+		return 0, fmt.Errorf("failed to flag URLs: %w", err)
+	}
+	return len(listed), nil
+}
+
+// hostSuffixes returns the exact host and up to four suffixes formed from its last five components.
+// IP addresses are only returned as they are.
+func hostSuffixes(host string) []string {
+	if host == "" {
+		return nil
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+
+	hosts := []string{host}
+	parts := strings.Split(host, ".")
+	if len(parts) > 5 {
+		parts = parts[len(parts)-5:]
+	}
+	for i := 0; i < len(parts)-1; i++ {
+		suffix := strings.Join(parts[i:], ".")
+		if suffix != host {
+			hosts = append(hosts, suffix)
+		}
+	}
+	return hosts
+}
+
+// pathPrefixes returns the exact path with and without query and up to four leading path prefixes.
+func pathPrefixes(path, query string) []string {
+	if path == "" {
+		path = "/"
+	}
+
+	var paths []string
+	if query != "" {
+		paths = append(paths, path+"?"+query)
+	}
+	paths = append(paths, path)
+
+	prefix := "/"
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(segments) && len(paths) < 6; i++ {
+		if prefix != path {
+			paths = append(paths, prefix)
+		}
+		if segments[i] == "" {
+			break
+		}
+		prefix += segments[i] + "/"
+	}
+	return paths
+}
+
+// readPrefixes reads hash prefixes from a list file.
+func readPrefixes(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	prefixes := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if len(line) < minPrefixLength || len(line) > sha256.Size*2 {
+			return nil, fmt.Errorf("invalid hash prefix length: %q", line)
+		}
+		if strings.Trim(line, "0123456789abcdef") != "" {
+			return nil, fmt.Errorf("invalid hash prefix: %q", line)
+		}
+		prefixes[line] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return prefixes, nil
+}
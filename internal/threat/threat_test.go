@@ -0,0 +1,51 @@
+package threat
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpressions(t *testing.T) {
+	got := Expressions("http://a.b.c/1/2.html?param=1")
+	want := []string{
+		"a.b.c/1/2.html?param=1", "a.b.c/1/2.html", "a.b.c/", "a.b.c/1/",
+		"b.c/1/2.html?param=1", "b.c/1/2.html", "b.c/", "b.c/1/",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestList_Listed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "threats.txt")
+	content := "# host-wide entry\n" + Hash("evil.example/") + "\n" +
+		// 4-byte prefix of a single page
+		Hash("good.example/phish.html")[:8] + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load threat list: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://evil.example/", want: true},
+		{url: "https://www.evil.example/any/path?x=1", want: true},
+		{url: "https://good.example/phish.html", want: true},
+		{url: "https://good.example/other.html", want: false},
+		{url: "https://example.org/", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := l.Listed(tt.url); got != tt.want {
+			t.Errorf("Listed(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,15 @@
 package types
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/hll"
+)
+
 // URLData represents the structure of a URL record, containing both the original URL
 // and the shortened version, along with a flag indicating whether it has been deleted.
 type URLData struct {
@@ -7,11 +17,233 @@ type URLData struct {
 	ShortURL    string `json:"short_url,omitempty"`    // ShortURL is the shortened version of the original URL
 	OriginalURL string `json:"original_url,omitempty"` // OriginalURL is the URL that was shortened
 	DeletedFlag bool   `json:"is_deleted"`             // DeletedFlag indicates whether the URL has been deleted
+	Flagged     bool   `json:"is_flagged,omitempty"`   // Flagged indicates that the original URL is on the threat list
+
+	CreatedAt *time.Time `json:"created_at,omitempty"` // CreatedAt is the moment the short URL was created
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // ExpiresAt is the moment after which the short URL stops working
+
+	ClicksLeft   *int64 `json:"remaining_clicks,omitempty"` // ClicksLeft is the number of redirects left, nil for unlimited links
+	PasswordHash string `json:"-"`                          // PasswordHash is the bcrypt hash of the password protecting the link; it is never sent to clients
+
+	Options *LinkOptions `json:"options,omitempty"` // Options holds the redirect settings of the link, nil for server defaults
+
+	Assignments []int64 `json:"variant_assignments,omitempty"` // Assignments counts the visitors assigned to each split variant
+
+	History []Revision `json:"history,omitempty"` // History holds the revisions of the link in the memory and file storages
+}
+
+// UserURL is a short URL as listed to its owner.
+type UserURL struct {
+	ShortURL    string       `json:"short_url"`                     // ShortURL is the full short URL
+	OriginalURL string       `json:"original_url"`                  // OriginalURL is the URL that was shortened
+	DeletedFlag bool         `json:"is_deleted"`                    // DeletedFlag indicates whether the URL has been deleted
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`          // ExpiresAt is the moment after which the short URL stops working
+	ClicksLeft  *int64       `json:"remaining_clicks,omitempty"`    // ClicksLeft is the number of redirects left, nil for unlimited links
+	Protected   bool         `json:"protected,omitempty"`           // Protected indicates that the link asks for a password
+	Options     *LinkOptions `json:"options,omitempty"`             // Options holds the redirect settings of the link
+	Assignments []int64      `json:"variant_assignments,omitempty"` // Assignments counts the visitors assigned to each split variant
+}
+
+// NewUserURL returns the listing of a URL record. The short URL is taken as it is.
+func NewUserURL(d URLData) UserURL {
+	return UserURL{
+		ShortURL:    d.ShortURL,
+		OriginalURL: d.OriginalURL,
+		DeletedFlag: d.DeletedFlag,
+		ExpiresAt:   d.ExpiresAt,
+		ClicksLeft:  d.ClicksLeft,
+		Protected:   d.PasswordHash != "",
+		Options:     d.Options,
+		Assignments: d.Assignments,
+	}
+}
+
+// LinkOptions holds the per-link redirect settings that can be set at creation and changed later.
+type LinkOptions struct {
+	RedirectType int    `json:"redirect_type,omitempty"` // RedirectType is the HTTP status of the redirect: 301, 302, 307 or 308; 0 for the server default
+	CacheTTL     *int64 `json:"cache_ttl,omitempty"`     // CacheTTL is how long the redirect may be cached in seconds; 0 forbids caching, nil for the server default
+
+	Rules    []RedirectRule `json:"rules,omitempty"`    // Rules are checked in order; the first matching rule chooses the destination
+	Variants []Variant      `json:"variants,omitempty"` // Variants split visitors that match no rule across several destinations
+
+	ForwardQuery string            `json:"forward_query,omitempty"` // ForwardQuery passes the incoming query on: "" (off), "keep", "override" or "append"
+	UTM          map[string]string `json:"utm,omitempty"`           // UTM holds query parameters added on redirect; values may contain {code} and {date}
+	PathSuffix   bool              `json:"path_suffix,omitempty"`   // PathSuffix appends the path after the short code to the destination path
+
+	Interstitial bool `json:"interstitial,omitempty"` // Interstitial shows the preview page instead of redirecting
+
+	NotBefore *time.Time `json:"not_before,omitempty"` // NotBefore is the moment the link starts to redirect
+	NotAfter  *time.Time `json:"not_after,omitempty"`  // NotAfter is the moment the link stops to redirect; unlike expiry, the link is kept
+}
+
+// Query forwarding strategies for parameters present both in the destination and in the request.
+const (
+	ForwardQueryKeep     = "keep"     // the destination value is kept
+	ForwardQueryOverride = "override" // the incoming value replaces the destination value
+	ForwardQueryAppend   = "append"   // both values are sent
+)
+
+// IsZero reports whether no option is set.
+func (o LinkOptions) IsZero() bool {
+	return o.RedirectType == 0 && o.CacheTTL == nil && len(o.Rules) == 0 && len(o.Variants) == 0 &&
+		o.ForwardQuery == "" && len(o.UTM) == 0 && !o.PathSuffix && !o.Interstitial &&
+		o.NotBefore == nil && o.NotAfter == nil
+}
+
+// Variant is one destination of a weighted A/B split.
+type Variant struct {
+	URL    string `json:"url"`    // URL is the destination of the variant
+	Weight int    `json:"weight"` // Weight is the relative share of visitors sent to the variant
+}
+
+// RedirectRule sends visitors that match all of its conditions to its own destination.
+// Conditions that are not set always match.
+type RedirectRule struct {
+	UserAgent string     `json:"user_agent,omitempty"` // UserAgent is an OS, browser or device family such as "ios", "android", "chrome" or "mobile"
+	Language  string     `json:"language,omitempty"`   // Language matches the preferred Accept-Language tag, e.g. "de" matches "de-AT"
+	Header    string     `json:"header,omitempty"`     // Header is the name of a request header, e.g. a country header set by the edge proxy
+	Value     string     `json:"value,omitempty"`      // Value is compared case-insensitively with Header; empty means the header only has to be present
+	From      *time.Time `json:"from,omitempty"`       // From is the start of the time window in which the rule applies
+	Until     *time.Time `json:"until,omitempty"`      // Until is the end of the time window in which the rule applies
+	URL       string     `json:"url"`                  // URL is the destination for matching visitors
+}
+
+// Expired reports whether the URL has an expiry time that is not after now.
+func (d URLData) Expired(now time.Time) bool {
+	return d.ExpiresAt != nil && !d.ExpiresAt.After(now)
+}
+
+// Plain reports whether the URL has no settings of its own: no expiry, click limit, password
+// or redirect options. Only plain URLs are shared by everyone who shortens the same original URL,
+// since a link with settings may be unusable for others.
+func (d URLData) Plain() bool {
+	return d.ExpiresAt == nil && d.ClicksLeft == nil && d.PasswordHash == "" && (d.Options == nil || d.Options.IsZero())
+}
+
+// Destinations returns every URL the link can redirect to: the original URL followed by the rule and variant destinations.
+func (d URLData) Destinations() []string {
+	urls := []string{d.OriginalURL}
+	if d.Options != nil {
+		for _, r := range d.Options.Rules {
+			urls = append(urls, r.URL)
+		}
+		for _, v := range d.Options.Variants {
+			urls = append(urls, v.URL)
+		}
+	}
+	return urls
+}
+
+// Exhausted reports whether the URL has a click limit and no clicks left.
+func (d URLData) Exhausted() bool {
+	return d.ClicksLeft != nil && *d.ClicksLeft <= 0
+}
+
+// Available returns an error describing why the URL can no longer be followed, or nil if it can.
+func (d URLData) Available(now time.Time) error {
+	if d.DeletedFlag {
+		return fmt.Errorf("URL has been deleted")
+	}
+	if d.Expired(now) {
+		return fmt.Errorf("URL has expired")
+	}
+	if d.Exhausted() {
+		return fmt.Errorf("URL has no clicks left")
+	}
+	return nil
+}
+
+// Active returns an error if the URL is outside its activation window, or nil if it may redirect.
+func (d URLData) Active(now time.Time) error {
+	if d.Options == nil {
+		return nil
+	}
+	if d.Options.NotBefore != nil && now.Before(*d.Options.NotBefore) {
+		return fmt.Errorf("URL is not active yet")
+	}
+	if d.Options.NotAfter != nil && !now.Before(*d.Options.NotAfter) {
+		return fmt.Errorf("URL is no longer active")
+	}
+	return nil
+}
+
+// Revision is a version of the destination and options of a short URL.
+type Revision struct {
+	Version     int          `json:"version"`           // Version numbers revisions from 1, the state at creation
+	OriginalURL string       `json:"original_url"`      // OriginalURL is the destination of the revision
+	Options     *LinkOptions `json:"options,omitempty"` // Options are the redirect settings of the revision
+	ChangedAt   time.Time    `json:"changed_at"`        // ChangedAt is the moment the revision was made
+	ChangedBy   string       `json:"changed_by"`        // ChangedBy is the ID of the user who made the revision
+}
+
+// FirstRevision returns the revision describing the URL as it was created.
+// now is used when the creation time is unknown.
+func FirstRevision(d URLData, now time.Time) Revision {
+	created := now
+	if d.CreatedAt != nil {
+		created = *d.CreatedAt
+	}
+	return Revision{Version: 1, OriginalURL: d.OriginalURL, Options: d.Options, ChangedAt: created, ChangedBy: d.UserID}
+}
+
+// Revisions returns the revisions to add to the history of a URL changed from before to after by actor.
+// last is the version of the latest recorded revision, 0 for an empty history; the first change then
+// also records the state before it so that it can be rolled back to.
+// Nil is returned when neither the destination nor the options changed.
+func Revisions(before, after URLData, last int, actor string, now time.Time) []Revision {
+	if before.OriginalURL == after.OriginalURL && sameOptions(before.Options, after.Options) {
+		return nil
+	}
+
+	var revisions []Revision
+	if last == 0 {
+		revisions = append(revisions, FirstRevision(before, now))
+		last = 1
+	}
+	return append(revisions, Revision{
+		Version:     last + 1,
+		OriginalURL: after.OriginalURL,
+		Options:     after.Options,
+		ChangedAt:   now,
+		ChangedBy:   actor,
+	})
+}
+
+// sameOptions reports whether a and b describe the same redirect settings.
+func sameOptions(a, b *LinkOptions) bool {
+	// Comparing the encoded form treats empty and missing lists alike
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+// CreateOptions holds the optional settings that can be given when a short URL is created.
+type CreateOptions struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // ExpiresAt is an absolute expiry time
+	TTL       int64      `json:"ttl,omitempty"`        // TTL is the lifetime of the link in seconds
+	MaxClicks int64      `json:"max_clicks,omitempty"` // MaxClicks limits the number of redirects, 0 for unlimited
+	Password  string     `json:"password,omitempty"`   // Password protects the link; visitors must enter it before being redirected
+
+	LinkOptions
+}
+
+// UpdateURLRequest is the body of a request that changes an existing short URL.
+// Fields that are absent keep their current values; null resets an option to the server default.
+type UpdateURLRequest struct {
+	URL string `json:"url,omitempty"` // URL is the new destination of the link
+
+	*LinkOptions
+}
+
+// RollbackRequest is the body of a request that restores the destination of an earlier revision.
+type RollbackRequest struct {
+	Version int `json:"version"` // Version is the revision whose destination is restored
 }
 
 // ShortenRequest represents the incoming request to shorten a URL.
 type ShortenRequest struct {
 	URL string `json:"url"` // URL is the original URL to be shortened
+	CreateOptions
 }
 
 // ShortenResponse represents the response for a URL shortening request, containing the result.
@@ -24,6 +256,7 @@ type ShortenResponse struct {
 type ShortenBatchRequest struct {
 	CorrelationID string `json:"correlation_id"` // CorrelationID is a unique identifier for the batch request
 	OriginalURL   string `json:"original_url"`   // OriginalURL is the URL to be shortened
+	CreateOptions
 }
 
 // ShortenBatchResponse represents the response for a batch URL shortening request, containing
@@ -33,8 +266,129 @@ type ShortenBatchResponse struct {
 	ShortURL      string `json:"short_url"`      // ShortURL is the shortened version of the provided URL
 }
 
+// RejectionResponse is returned when a destination URL is refused by policy.
+type RejectionResponse struct {
+	Error         string `json:"error"`                    // Error is a human-readable message
+	Reason        string `json:"reason"`                   // Reason is a machine-readable rejection code
+	Detail        string `json:"detail,omitempty"`         // Detail describes the rule that matched
+	CorrelationID string `json:"correlation_id,omitempty"` // CorrelationID identifies the rejected item of a batch
+}
+
+// ClickEvent describes one visit of a short URL that was redirected.
+type ClickEvent struct {
+	ShortURL  string    `json:"short_url"`            // ShortURL is the code of the visited link
+	Time      time.Time `json:"time"`                 // Time is the moment of the visit
+	Referrer  string    `json:"referrer,omitempty"`   // Referrer is the Referer header of the visit
+	UserAgent string    `json:"user_agent,omitempty"` // UserAgent is the User-Agent header of the visit
+	IPHash    string    `json:"ip_hash,omitempty"`    // IPHash is the salted hash of the visitor IP
+	Language  string    `json:"language,omitempty"`   // Language is the Accept-Language header of the visit
+	Country   string    `json:"country,omitempty"`    // Country is the visitor country reported by a trusted proxy header
+	Bot       bool      `json:"bot,omitempty"`        // Bot tells that the visit was made by a crawler or a link unfurler
+	Visitor   string    `json:"-"`                    // Visitor is the salted fingerprint counted by visitor sketches; it is never stored
+}
+
+// VisitorSketch estimates the distinct visitors of a short URL during one UTC day.
+// Bots are not counted.
+// The sketch with an empty ShortURL counts the visitors of all links.
+type VisitorSketch struct {
+	ShortURL string      `json:"short_url"`
+	Day      time.Time   `json:"day"` // Day is the start of the day, see VisitorDay
+	Sketch   *hll.Sketch `json:"sketch"`
+}
+
+// VisitorDay returns the start of the UTC day that holds t.
+func VisitorDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Sizes of the lists of Stats.
+const (
+	StatsDays     = 30 // StatsDays is the number of days covered by Stats.CreatedPerDay, today included
+	StatsTopUsers = 10 // StatsTopUsers is the length of Stats.TopUsers
+)
+
 // Stats возвращает количество сокращенных URL и количество пользователей
 type Stats struct {
-	Urls  int `json:"urls"`  // количество сокращённых URL в сервисе
+	Urls  int `json:"urls"`  // количество сокращённых URL в сервисе, не считая удалённых
 	Users int `json:"users"` // количество пользователей в сервисе
+
+	Active        int         `json:"active"`          // Active is the number of links that are neither deleted nor expired
+	Deleted       int         `json:"deleted"`         // Deleted is the number of links deleted by their owners
+	Expired       int         `json:"expired"`         // Expired is the number of expired links not removed by the sweeper yet
+	Redirects     int64       `json:"redirects"`       // Redirects is the number of recorded visits
+	CreatedPerDay []DayCount  `json:"created_per_day"` // CreatedPerDay lists the days of the last StatsDays with new links
+	TopUsers      []UserCount `json:"top_users"`       // TopUsers ranks users by their number of links that are not deleted
+
+	Visitors uint64 `json:"visitors"` // Visitors estimates the distinct visitors of all links over the last 30 days
+}
+
+// DayCount is the number of links created on a UTC day.
+type DayCount struct {
+	Day   time.Time `json:"day"`
+	Links int       `json:"links"`
+}
+
+// UserCount is the number of links of a user.
+type UserCount struct {
+	UserID string `json:"user_id"`
+	Links  int    `json:"links"`
+}
+
+// Webhook events.
+const (
+	EventLinkCreated    = "link.created"         // a link was shortened
+	EventLinkUpdated    = "link.updated"         // the destination or the options of a link changed
+	EventLinkDeleted    = "link.deleted"         // a link was deleted by its owner
+	EventLinkExpired    = "link.expired"         // a link reached its expiry time
+	EventClickThreshold = "link.click_threshold" // the visits of a link reached one of the thresholds of the webhook
+)
+
+// WebhookEvents lists every webhook event.
+var WebhookEvents = []string{EventLinkCreated, EventLinkUpdated, EventLinkDeleted, EventLinkExpired, EventClickThreshold}
+
+// Webhook is a subscription of a user to the events of their links.
+type Webhook struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"user_id,omitempty"`
+	URL             string    `json:"url"`                        // URL receives the events in POST requests
+	Events          []string  `json:"events,omitempty"`           // Events filters the events sent; empty means all of them
+	ClickThresholds []int64   `json:"click_thresholds,omitempty"` // ClickThresholds are the visit counts that raise EventClickThreshold
+	Secret          string    `json:"secret,omitempty"`           // Secret signs the payloads; it is shown once, when the webhook is created
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Wants reports whether the webhook subscribes to the event.
+func (w Webhook) Wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// WebhookRequest is the body of a request that creates a webhook.
+type WebhookRequest struct {
+	URL             string   `json:"url"`
+	Events          []string `json:"events,omitempty"`
+	ClickThresholds []int64  `json:"click_thresholds,omitempty"`
+}
+
+// Delivery states.
+const (
+	DeliveryPending   = "pending"   // the delivery waits for its next attempt
+	DeliveryDelivered = "delivered" // the receiver accepted the payload
+	DeliveryDead      = "dead"      // every attempt failed; the delivery is kept as a dead letter until it is retried
+)
+
+// Delivery is the sending of one event to one webhook.
+type Delivery struct {
+	ID           string          `json:"id"`
+	WebhookID    string          `json:"webhook_id"`
+	UserID       string          `json:"user_id,omitempty"`
+	Event        string          `json:"event"`
+	Payload      json.RawMessage `json:"payload"` // Payload is the signed request body
+	Status       string          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"response_code,omitempty"` // ResponseCode is the HTTP status of the last attempt
+	Error        string          `json:"error,omitempty"`         // Error describes why the last attempt failed
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	NextAttempt  *time.Time      `json:"next_attempt,omitempty"` // NextAttempt is set while the delivery is pending
 }
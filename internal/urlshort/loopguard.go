@@ -0,0 +1,216 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+)
+
+// Self-reference handling modes.
+const (
+	SelfReferenceReject  = "reject"
+	SelfReferenceFlatten = "flatten"
+)
+
+// followTimeout limits a single request to a known shortener.
+const followTimeout = 5 * time.Second
+
+// resolveTimeout limits the time spent following known shorteners for one destination,
+// or for all destinations checked with a context from WithBudget.
+const resolveTimeout = 10 * time.Second
+
+// LoopGuard detects destinations that point back at the service, directly or through
+// other known URL shorteners, to prevent redirect chains and loops.
+type LoopGuard struct {
+	cfg     *config.Config
+	storage db.ShortenerStorage
+	policy  *policy.Engine
+	client  *http.Client
+	own     []*url.URL
+}
+
+// NewLoopGuard creates a guard for the base URL and alias hosts of cfg.
+// Known shorteners are not followed to URLs that pol rejects; pol may be nil.
+func NewLoopGuard(cfg *config.Config, storage db.ShortenerStorage, pol *policy.Engine) (*LoopGuard, error) {
+	g := &LoopGuard{
+		cfg:     cfg,
+		storage: storage,
+		policy:  pol,
+		client: &http.Client{
+			Timeout: followTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	own := []string{cfg.BaseURL}
+	for _, host := range cfg.AliasHosts {
+		own = append(own, base.Scheme+"://"+host+base.Path)
+	}
+	for _, o := range own {
+		normalized, err := NormalizeURL(o, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid own URL %q: %w", o, err)
+		}
+		u, _ := url.Parse(normalized)
+		g.own = append(g.own, u)
+	}
+
+	return g, nil
+}
+
+// Resolve checks a normalised destination and returns the URL that should be stored.
+//
+// Links to the service itself are rejected or, in flatten mode, replaced by the destination
+// of the referenced short link if that link has no settings of its own. Links to known shorteners are followed up to the configured
+// depth; chains that lead back to the service are rejected, and in flatten mode the final
+// destination is stored instead. Rejections are returned as *policy.Violation.
+// A nil guard returns the URL unchanged.
+//
+// Following stops at URLs the destination policy rejects, and after resolveTimeout
+// or the deadline of ctx; the URL reached so far is then kept.
+func (g *LoopGuard) Resolve(ctx context.Context, rawURL string) (string, error) {
+	if g == nil {
+		return rawURL, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	flatten := g.cfg.SelfReferenceMode == SelfReferenceFlatten
+	viaShortener := false
+	visited := map[string]struct{}{}
+	current := rawURL
+
+	for hops := 0; ; hops++ {
+		if _, ok := visited[current]; ok {
+			return "", &policy.Violation{Reason: policy.ReasonRedirectLoop, Detail: current}
+		}
+		visited[current] = struct{}{}
+
+		u, err := url.Parse(current)
+		if err != nil {
+			return "", &policy.Violation{Reason: policy.ReasonInvalidURL, Detail: err.Error()}
+		}
+
+		var next string
+		if code, ok := g.ownCode(u); ok {
+			if !flatten {
+				return "", &policy.Violation{Reason: policy.ReasonSelfReference, Detail: current}
+			}
+			urlData, err := g.storage.Get(code)
+			if err != nil {
+				return "", &policy.Violation{Reason: policy.ReasonSelfReference, Detail: "unknown short link " + code}
+			}
+			// Only links without settings are flattened: copying the destination of a protected,
+			// limited or scheduled link would publish it without its restrictions
+			if !urlData.Plain() || urlData.Flagged || urlData.DeletedFlag {
+				return "", &policy.Violation{Reason: policy.ReasonSelfReference, Detail: "short link " + code + " cannot be flattened"}
+			}
+			next = urlData.OriginalURL
+		} else if g.isKnownShortener(u.Hostname()) && g.policy.Check(current) == nil {
+			next, err = g.follow(ctx, u)
+			if err != nil || next == "" {
+				// The shortener could not be followed or did not redirect: keep what we have
+				break
+			}
+			viaShortener = true
+		} else {
+			break
+		}
+
+		if hops >= g.cfg.MaxRedirectChain {
+			return "", &policy.Violation{
+				Reason: policy.ReasonRedirectChainTooLong,
+				Detail: fmt.Sprintf("more than %d redirects", g.cfg.MaxRedirectChain),
+			}
+		}
+
+		next, err = NormalizeURL(next, g.cfg)
+		if err != nil {
+			return "", &policy.Violation{Reason: policy.ReasonInvalidURL, Detail: err.Error()}
+		}
+		current = next
+	}
+
+	if viaShortener && !flatten {
+		return rawURL, nil
+	}
+	return current, nil
+}
+
+// WithBudget returns a context that shares the time limit of Resolve across every call made with it,
+// so that a request with many destinations does not wait for each of them in turn.
+func (g *LoopGuard) WithBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, resolveTimeout)
+}
+
+// ownCode returns the short code if u points at the service.
+func (g *LoopGuard) ownCode(u *url.URL) (string, bool) {
+	for _, o := range g.own {
+		if !strings.EqualFold(u.Host, o.Host) {
+			continue
+		}
+		prefix := strings.TrimSuffix(o.Path, "/") + "/"
+		if !strings.HasPrefix(u.Path, prefix) {
+			continue
+		}
+		code, _, _ := strings.Cut(strings.TrimPrefix(u.Path, prefix), "/")
+		// A trailing "+" asks for the preview page of the link
+		return strings.TrimSuffix(code, "+"), true
+	}
+	return "", false
+}
+
+// isKnownShortener reports whether host belongs to a configured URL shortener.
+func (g *LoopGuard) isKnownShortener(host string) bool {
+	host = strings.ToLower(host)
+	for _, s := range g.cfg.KnownShorteners {
+		s = strings.ToLower(s)
+		if host == s || strings.HasSuffix(host, "."+s) {
+			return true
+		}
+	}
+	return false
+}
+
+// follow requests u without following redirects and returns the redirect target, if any.
+func (g *LoopGuard) follow(ctx context.Context, u *url.URL) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, followTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 300 || resp.StatusCode > 399 {
+		return "", nil
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("redirect without Location header")
+	}
+	target, err := u.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return target.String(), nil
+}
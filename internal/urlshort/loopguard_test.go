@@ -0,0 +1,148 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/db/memorystorage"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+func TestLoopGuard_Resolve(t *testing.T) {
+	// A fake external shortener: /ext redirects to our own link, /out to an external page
+	// and /chain to itself with a growing path.
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ext":
+			http.Redirect(w, r, "http://sho.rt/abc", http.StatusMovedPermanently)
+		case "/out":
+			http.Redirect(w, r, "https://example.com/final", http.StatusFound)
+		default:
+			http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+		}
+	}))
+	defer shortener.Close()
+	shortenerURL, _ := url.Parse(shortener.URL)
+
+	newGuard := func(mode string) *LoopGuard {
+		cfg := &config.Config{
+			BaseURL:           "http://sho.rt",
+			AliasHosts:        []string{"alias.sho.rt"},
+			SelfReferenceMode: mode,
+			KnownShorteners:   []string{shortenerURL.Hostname()},
+			MaxRedirectChain:  3,
+		}
+		storage, _ := memorystorage.NewManager(cfg)
+		storage.Put(types.URLData{ShortURL: "abc", OriginalURL: "https://example.com/page"})
+		clicks := int64(1)
+		storage.Put(types.URLData{ShortURL: "locked", OriginalURL: "https://example.com/secret", PasswordHash: "$2a$10$hash"})
+		storage.Put(types.URLData{ShortURL: "once", OriginalURL: "https://example.com/once", ClicksLeft: &clicks})
+		g, err := NewLoopGuard(cfg, storage, nil)
+		if err != nil {
+			t.Fatalf("failed to create loop guard: %v", err)
+		}
+		return g
+	}
+
+	tests := []struct {
+		name   string
+		mode   string
+		url    string
+		want   string
+		reason string
+	}{
+		{name: "External URL", mode: SelfReferenceReject, url: "https://example.com/", want: "https://example.com/"},
+		{name: "Own link rejected", mode: SelfReferenceReject, url: "http://sho.rt/abc", reason: policy.ReasonSelfReference},
+		{name: "Alias link rejected", mode: SelfReferenceReject, url: "http://alias.sho.rt/abc", reason: policy.ReasonSelfReference},
+		{name: "Own link flattened", mode: SelfReferenceFlatten, url: "http://sho.rt/abc", want: "https://example.com/page"},
+		{name: "Password link not flattened", mode: SelfReferenceFlatten, url: "http://sho.rt/locked", reason: policy.ReasonSelfReference},
+		{name: "Click-limited link not flattened", mode: SelfReferenceFlatten, url: "http://sho.rt/once", reason: policy.ReasonSelfReference},
+		{name: "Unknown own link", mode: SelfReferenceFlatten, url: "http://sho.rt/zzz", reason: policy.ReasonSelfReference},
+		{name: "Shortener back to us", mode: SelfReferenceReject, url: shortener.URL + "/ext", reason: policy.ReasonSelfReference},
+		{name: "Shortener kept", mode: SelfReferenceReject, url: shortener.URL + "/out", want: shortener.URL + "/out"},
+		{name: "Shortener flattened", mode: SelfReferenceFlatten, url: shortener.URL + "/out", want: "https://example.com/final"},
+		{name: "Chain too long", mode: SelfReferenceFlatten, url: shortener.URL + "/c", reason: policy.ReasonRedirectChainTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newGuard(tt.mode).Resolve(context.Background(), tt.url)
+			if tt.reason != "" {
+				var v *policy.Violation
+				if !errors.As(err, &v) || v.Reason != tt.reason {
+					t.Fatalf("expected %s violation, got %v", tt.reason, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoopGuard_ResolveLimits(t *testing.T) {
+	// A fake external shortener that counts its requests and answers /slow only when the client gives up
+	var requests atomic.Int32
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		http.Redirect(w, r, "http://sho.rt/abc", http.StatusFound)
+	}))
+	defer shortener.Close()
+	shortenerURL, _ := url.Parse(shortener.URL)
+
+	pol, err := policy.New(policy.File{AllowPrivateIPs: true, Deny: policy.Rules{PathRegexes: []string{"^/denied$"}}})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	cfg := &config.Config{
+		BaseURL:           "http://sho.rt",
+		SelfReferenceMode: SelfReferenceReject,
+		KnownShorteners:   []string{shortenerURL.Hostname()},
+		MaxRedirectChain:  3,
+	}
+	storage, _ := memorystorage.NewManager(cfg)
+	g, err := NewLoopGuard(cfg, storage, pol)
+	if err != nil {
+		t.Fatalf("failed to create loop guard: %v", err)
+	}
+
+	// URLs rejected by the policy are not followed
+	if _, err = g.Resolve(context.Background(), shortener.URL+"/denied"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("expected a denied URL not to be requested, got %v requests", n)
+	}
+
+	// Once the budget is used up, the remaining URLs are kept without waiting
+	ctx, cancel := g.WithBudget(context.Background())
+	defer cancel()
+	ctx, cancelSoon := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelSoon()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		got, err := g.Resolve(ctx, shortener.URL+"/slow")
+		if err != nil || got != shortener.URL+"/slow" {
+			t.Fatalf("expected the URL to be kept, got %q, %v", got, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the calls to share the budget, took %v", elapsed)
+	}
+}
@@ -0,0 +1,263 @@
+package urlshort
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// defaultPorts maps supported schemes to the port that is implied when none is given.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// NormalizeURL validates rawURL and returns its canonical form as described in RFC 3986, section 6.
+//
+// The scheme and host are lowercased, internationalised domain names are converted to punycode,
+// default ports are removed, percent-encoding is normalised and dot segments are resolved.
+// Depending on the configuration the fragment is dropped and query parameters are sorted by key.
+// URLs with user information are rejected. A nil cfg applies only the unconditional steps.
+func NormalizeURL(rawURL string, cfg *config.Config) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", fmt.Errorf("URL is empty")
+	}
+	if !utf8.ValidString(rawURL) {
+		return "", fmt.Errorf("URL is not valid UTF-8")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	defaultPort, ok := defaultPorts[scheme]
+	if !ok {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", fmt.Errorf("URL has no host")
+	}
+	// User information makes links such as https://bank.example@evil.example/ look like they lead elsewhere
+	if u.User != nil {
+		return "", fmt.Errorf("URL must not contain user information")
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+
+	port := u.Port()
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("invalid port %q", port)
+		}
+		port = strconv.Itoa(n)
+	}
+	if port == defaultPort {
+		port = ""
+	}
+
+	path, err := normalizeEscapes(u.EscapedPath())
+	if err != nil {
+		return "", err
+	}
+	path = removeDotSegments(path)
+	if path == "" {
+		path = "/"
+	}
+
+	query, err := normalizeQuery(u.RawQuery, cfg != nil && cfg.SortQuery)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if port != "" {
+		b.WriteString(net.JoinHostPort(host, port))
+	} else if strings.Contains(host, ":") {
+		b.WriteString("[" + host + "]")
+	} else {
+		b.WriteString(host)
+	}
+	b.WriteString(path)
+	if query != "" {
+		b.WriteByte('?')
+		b.WriteString(query)
+	}
+	if u.Fragment != "" && (cfg == nil || !cfg.StripFragment) {
+		b.WriteByte('#')
+		b.WriteString(u.EscapedFragment())
+	}
+
+	return b.String(), nil
+}
+
+// NormalizeBatchRequestURLs normalises every URL of a batch in place.
+func NormalizeBatchRequestURLs(batch []types.ShortenBatchRequest, cfg *config.Config) error {
+	for i := range batch {
+		u, err := NormalizeURL(batch[i].OriginalURL, cfg)
+		if err != nil {
+			return fmt.Errorf("invalid URL for correlation ID %s: %w", batch[i].CorrelationID, err)
+		}
+		batch[i].OriginalURL = u
+	}
+	return nil
+}
+
+// normalizeHost lowercases the host and converts it to its ASCII form.
+// IP addresses are returned in their canonical textual representation.
+func normalizeHost(host string) (string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.String(), nil
+		}
+		return ip.String(), nil
+	}
+	if strings.Contains(host, ":") {
+		return "", fmt.Errorf("invalid IPv6 host %q", host)
+	}
+
+	host = strings.TrimSuffix(host, ".")
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	if ascii == "localhost" {
+		return ascii, nil
+	}
+
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 || len(ascii) > 253 {
+		return "", fmt.Errorf("invalid host %q", host)
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 {
+			return "", fmt.Errorf("invalid host %q", host)
+		}
+	}
+	// An all-numeric top-level label means a malformed IP address rather than a domain
+	if _, err = strconv.Atoi(labels[len(labels)-1]); err == nil {
+		return "", fmt.Errorf("invalid host %q", host)
+	}
+
+	return ascii, nil
+}
+
+// normalizeQuery normalises the percent-encoding of a raw query and optionally sorts it by key.
+// The relative order of repeated keys is preserved.
+func normalizeQuery(rawQuery string, sortParams bool) (string, error) {
+	if rawQuery == "" {
+		return "", nil
+	}
+
+	var params []string
+	for _, p := range strings.Split(rawQuery, "&") {
+		if p == "" {
+			continue
+		}
+		p, err := normalizeEscapes(p)
+		if err != nil {
+			return "", err
+		}
+		params = append(params, p)
+	}
+
+	if sortParams {
+		sort.SliceStable(params, func(i, j int) bool {
+			return queryKey(params[i]) < queryKey(params[j])
+		})
+	}
+
+	return strings.Join(params, "&"), nil
+}
+
+// queryKey returns the key part of a key=value query parameter.
+func queryKey(param string) string {
+	key, _, _ := strings.Cut(param, "=")
+	return key
+}
+
+// normalizeEscapes uppercases percent-encoded octets and decodes those that represent unreserved characters.
+func normalizeEscapes(s string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		if isUnreserved(byte(v)) {
+			b.WriteByte(byte(v))
+		} else {
+			b.WriteString("%" + strings.ToUpper(s[i+1:i+3]))
+		}
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// isUnreserved reports whether c is an unreserved character as defined in RFC 3986, section 2.3.
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// removeDotSegments resolves "." and ".." segments as described in RFC 3986, section 5.2.4.
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	var out []string
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+
+	result := strings.Join(out, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
@@ -0,0 +1,115 @@
+package urlshort
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/rules"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxPasswordLength is the longest password bcrypt can hash.
+const maxPasswordLength = 72
+
+// maxTTL is the longest TTL in seconds that fits in a time.Duration, about 292 years.
+const maxTTL = math.MaxInt64 / int64(time.Second)
+
+// ValidateCreateOptions checks that the creation options are consistent at the given moment.
+func ValidateCreateOptions(opts types.CreateOptions, now time.Time) error {
+	if opts.TTL != 0 && opts.ExpiresAt != nil {
+		return fmt.Errorf("ttl and expires_at are mutually exclusive")
+	}
+	if opts.TTL < 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+	if opts.TTL > maxTTL {
+		return fmt.Errorf("ttl must be at most %d seconds", maxTTL)
+	}
+	if opts.MaxClicks < 0 {
+		return fmt.Errorf("max_clicks must be positive")
+	}
+	if len(opts.Password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes long", maxPasswordLength)
+	}
+	if err := ValidateLinkOptions(opts.LinkOptions); err != nil {
+		return err
+	}
+	if opts.ExpiresAt != nil && !opts.ExpiresAt.After(now) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
+
+// ApplyCreateOptions validates the creation options and stores them in urlData.
+// now is used as the creation time and as the reference point for TTL.
+func ApplyCreateOptions(urlData *types.URLData, opts types.CreateOptions, now time.Time) error {
+	if err := ValidateCreateOptions(opts, now); err != nil {
+		return err
+	}
+
+	createdAt := now.UTC()
+	urlData.CreatedAt = &createdAt
+
+	if opts.TTL > 0 {
+		expiresAt := createdAt.Add(time.Duration(opts.TTL) * time.Second)
+		urlData.ExpiresAt = &expiresAt
+	}
+	if opts.ExpiresAt != nil {
+		expiresAt := opts.ExpiresAt.UTC()
+		urlData.ExpiresAt = &expiresAt
+	}
+	if opts.MaxClicks > 0 {
+		clicks := opts.MaxClicks
+		urlData.ClicksLeft = &clicks
+	}
+	if opts.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		urlData.PasswordHash = string(hash)
+	}
+	if !opts.LinkOptions.IsZero() {
+		linkOptions := opts.LinkOptions
+		urlData.Options = &linkOptions
+	}
+	if len(opts.Variants) > 0 {
+		urlData.Assignments = make([]int64, len(opts.Variants))
+	}
+
+	return nil
+}
+
+// ValidateRedirectType checks that status is a redirect status links may answer with.
+// It applies to the server default, which links without their own redirect type use.
+func ValidateRedirectType(status int) error {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	}
+	return fmt.Errorf("redirect type must be one of 301, 302, 307 or 308, got %d", status)
+}
+
+// ValidateLinkOptions checks the redirect settings of a link.
+func ValidateLinkOptions(opts types.LinkOptions) error {
+	// Zero leaves the redirect type to the server default
+	if opts.RedirectType != 0 && ValidateRedirectType(opts.RedirectType) != nil {
+		return fmt.Errorf("redirect_type must be one of 301, 302, 307 or 308")
+	}
+	if opts.CacheTTL != nil && *opts.CacheTTL < 0 {
+		return fmt.Errorf("cache_ttl must not be negative")
+	}
+	if opts.NotBefore != nil && opts.NotAfter != nil && !opts.NotAfter.After(*opts.NotBefore) {
+		return fmt.Errorf("not_after must be after not_before")
+	}
+	if err := rules.Validate(opts.Rules); err != nil {
+		return err
+	}
+	if err := rules.ValidateVariants(opts.Variants); err != nil {
+		return err
+	}
+	return rules.ValidatePassthrough(opts)
+}
@@ -0,0 +1,113 @@
+package urlshort
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+func TestApplyCreateOptions(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(48 * time.Hour)
+	past := now.Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		opts    types.CreateOptions
+		want    *time.Time
+		wantErr bool
+	}{
+		{name: "No expiry", opts: types.CreateOptions{}},
+		{name: "TTL", opts: types.CreateOptions{TTL: 3600}, want: timePtr(now.Add(time.Hour))},
+		{name: "Absolute", opts: types.CreateOptions{ExpiresAt: &future}, want: &future},
+		{name: "Both", opts: types.CreateOptions{TTL: 60, ExpiresAt: &future}, wantErr: true},
+		{name: "Negative TTL", opts: types.CreateOptions{TTL: -1}, wantErr: true},
+		{name: "Longest TTL", opts: types.CreateOptions{TTL: maxTTL}, want: timePtr(now.Add(time.Duration(maxTTL) * time.Second))},
+		{name: "TTL too long", opts: types.CreateOptions{TTL: maxTTL + 1}, wantErr: true},
+		{name: "In the past", opts: types.CreateOptions{ExpiresAt: &past}, wantErr: true},
+		{name: "Activation window", opts: types.CreateOptions{LinkOptions: types.LinkOptions{NotBefore: &now, NotAfter: &future}}},
+		{name: "Reversed window", opts: types.CreateOptions{LinkOptions: types.LinkOptions{NotBefore: &future, NotAfter: &now}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var urlData types.URLData
+			err := ApplyCreateOptions(&urlData, tt.opts, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if urlData.CreatedAt == nil || !urlData.CreatedAt.Equal(now) {
+				t.Errorf("expected created_at %v, got %v", now, urlData.CreatedAt)
+			}
+			if (tt.want == nil) != (urlData.ExpiresAt == nil) || tt.want != nil && !tt.want.Equal(*urlData.ExpiresAt) {
+				t.Errorf("expected expires_at %v, got %v", tt.want, urlData.ExpiresAt)
+			}
+			if tt.want != nil && (urlData.Expired(tt.want.Add(-time.Second)) || !urlData.Expired(*tt.want)) {
+				t.Errorf("unexpected expiry state around %v", tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyCreateOptionsPlain(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		opts  types.CreateOptions
+		plain bool
+	}{
+		{name: "No options", opts: types.CreateOptions{}, plain: true},
+		{name: "Expiry", opts: types.CreateOptions{ExpiresAt: &future}},
+		{name: "Click limit", opts: types.CreateOptions{MaxClicks: 1}},
+		{name: "Password", opts: types.CreateOptions{Password: "letmein"}},
+		{name: "Redirect options", opts: types.CreateOptions{LinkOptions: types.LinkOptions{RedirectType: 301}}},
+	}
+
+	// Links with settings must not be deduplicated with the plain link of the same URL,
+	// which may belong to another user and lack the protection asked for
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var urlData types.URLData
+			if err := ApplyCreateOptions(&urlData, tt.opts, now); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if urlData.Plain() != tt.plain {
+				t.Errorf("expected plain %v, got %v", tt.plain, urlData.Plain())
+			}
+		})
+	}
+}
+
+func TestValidateRedirectType(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr bool
+	}{
+		{status: 301},
+		{status: 302},
+		{status: 307},
+		{status: 308},
+		{status: 0, wantErr: true},
+		{status: 200, wantErr: true},
+		{status: 303, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			if err := ValidateRedirectType(tt.status); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -3,55 +3,65 @@ package urlshort
 import (
 	"fmt"
 	"math/rand"
-	"regexp"
 	"time"
 
 	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/blocklist"
 	"github.com/jayjaytrn/URLShortener/internal/types"
 
 	"github.com/jayjaytrn/URLShortener/internal/db"
 )
 
-var urlRegex = regexp.MustCompile(`^https?://([a-zA-Z0-9-]+\.)*[a-zA-Z0-9-]+\.[a-zA-Z]{2,}(/.*)?$`)
+const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// maxGenerateAttempts is the number of codes tried before GenerateShortURL gives up.
+const maxGenerateAttempts = 100
 
 // GenerateShortURL generates a random short URL that does not already exist in the storage.
 // It uses a random selection from a defined character set and ensures the generated short URL is unique.
-func GenerateShortURL(storage db.ShortenerStorage) (string, error) {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+// Codes containing a word from the blocklist are discarded. An error is returned when
+// no free code is found in maxGenerateAttempts attempts.
+func GenerateShortURL(storage db.ShortenerStorage, bl *blocklist.List) (string, error) {
 	const keyLength = 8
 
 	rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	shortURL := make([]byte, keyLength)
-	for {
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
 		for i := range shortURL {
 			shortURL[i] = charset[rand.Intn(len(charset))]
 		}
 
+		// Skip codes that spell a blocked word
+		if bl.Contains(string(shortURL)) {
+			continue
+		}
+
 		// Check if the generated short URL already exists
 		exists, err := storage.Exists(string(shortURL))
 		if err != nil {
 			return "", fmt.Errorf("failed to check if URL exists: %w", err)
 		}
 
-		// If the short URL does not exist, break the loop
+		// If the short URL does not exist, it can be used
 		if !exists {
-			break
+			return string(shortURL), nil
 		}
 	}
-	return string(shortURL), nil
+	return "", fmt.Errorf("no free short URL found in %d attempts", maxGenerateAttempts)
 }
 
 // GenerateShortBatch generates a batch of short URLs for a list of original URLs.
 // It checks for uniqueness among the newly generated short URLs and ensures no conflicts exist in the storage.
-func GenerateShortBatch(cfg *config.Config, storage db.ShortenerStorage, batch []types.ShortenBatchRequest, userID string) ([]types.ShortenBatchResponse, []types.URLData, error) {
+func GenerateShortBatch(cfg *config.Config, storage db.ShortenerStorage, bl *blocklist.List, batch []types.ShortenBatchRequest, userID string) ([]types.ShortenBatchResponse, []types.URLData, error) {
 	var batchResponse []types.ShortenBatchResponse
 	var urlData []types.URLData
 	newShorts := make(map[string]interface{})
+	now := time.Now()
 
 	for n := 0; n < len(batch); {
 		// Generate a short URL and check if it exists
-		shortURL, err := GenerateShortURL(storage)
+		shortURL, err := GenerateShortURL(storage, bl)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -64,17 +74,21 @@ func GenerateShortBatch(cfg *config.Config, storage db.ShortenerStorage, batch [
 		// Add the new short URL to the list of generated URLs
 		newShorts[shortURL] = batch[n]
 
-		// Append the short URL response for the batch
-		batchResponse = append(batchResponse, types.ShortenBatchResponse{
-			CorrelationID: batch[n].CorrelationID,
-			ShortURL:      cfg.BaseURL + "/" + shortURL,
-		})
-
 		// Prepare the data for database insertion
-		urlData = append(urlData, types.URLData{
+		data := types.URLData{
 			ShortURL:    shortURL,
 			OriginalURL: batch[n].OriginalURL,
 			UserID:      userID,
+		}
+		if err = ApplyCreateOptions(&data, batch[n].CreateOptions, now); err != nil {
+			return nil, nil, fmt.Errorf("invalid options for correlation ID %s: %w", batch[n].CorrelationID, err)
+		}
+		urlData = append(urlData, data)
+
+		// Append the short URL response for the batch
+		batchResponse = append(batchResponse, types.ShortenBatchResponse{
+			CorrelationID: batch[n].CorrelationID,
+			ShortURL:      cfg.BaseURL + "/" + shortURL,
 		})
 
 		// Move to the next item in the batch only if the short URL is unique
@@ -83,17 +97,8 @@ func GenerateShortBatch(cfg *config.Config, storage db.ShortenerStorage, batch [
 	return batchResponse, urlData, nil
 }
 
-// ValidateURL validates if a URL matches the expected structure (HTTP/HTTPS).
+// ValidateURL validates if a URL is an absolute HTTP/HTTPS URL that can be normalised.
 func ValidateURL(url string) bool {
-	return urlRegex.MatchString(url)
-}
-
-// ValidateBatchRequestURLs validates that all URLs in a batch are correctly formatted.
-func ValidateBatchRequestURLs(batch []types.ShortenBatchRequest) bool {
-	for _, b := range batch {
-		if !ValidateURL(b.OriginalURL) {
-			return false
-		}
-	}
-	return true
+	_, err := NormalizeURL(url, nil)
+	return err == nil
 }
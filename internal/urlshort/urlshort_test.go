@@ -0,0 +1,42 @@
+package urlshort
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/blocklist"
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/db/memorystorage"
+)
+
+func TestGenerateShortURLBlocklist(t *testing.T) {
+	storage, _ := memorystorage.NewManager(&config.Config{})
+	// Words shorter than three characters are ignored, so "a" blocks nothing
+	bl := blocklist.New([]string{"a", "abc", "xyz", "q1q"})
+
+	for i := 0; i < 200; i++ {
+		code, err := GenerateShortURL(storage, bl)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bl.Contains(code) {
+			t.Fatalf("expected blocked codes to be skipped, got %q", code)
+		}
+	}
+}
+
+// takenStorage is a storage in which every short URL is taken.
+type takenStorage struct {
+	db.ShortenerStorage
+}
+
+func (takenStorage) Exists(string) (bool, error) {
+	return true, nil
+}
+
+func TestGenerateShortURLAttempts(t *testing.T) {
+	if _, err := GenerateShortURL(takenStorage{}, nil); err == nil || !strings.Contains(err.Error(), "no free short URL") {
+		t.Errorf("expected generation to give up, got %v", err)
+	}
+}
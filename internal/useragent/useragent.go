@@ -0,0 +1,125 @@
+// Package useragent extracts the operating system, browser and device class from User-Agent headers.
+//
+// The parser only looks for well-known tokens; it is meant for routing and statistics,
+// not for exact client identification.
+package useragent
+
+import "strings"
+
+// Operating systems.
+const (
+	OSiOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSChromeOS = "chromeos"
+	OSLinux    = "linux"
+	OSOther    = "other"
+)
+
+// Browsers.
+const (
+	BrowserEdge    = "edge"
+	BrowserOpera   = "opera"
+	BrowserChrome  = "chrome"
+	BrowserFirefox = "firefox"
+	BrowserSafari  = "safari"
+	BrowserOther   = "other"
+)
+
+// Device classes.
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+// botTokens are substrings that identify crawlers and non-browser clients.
+var botTokens = []string{
+	"bot", "crawler", "spider", "slurp", "facebookexternalhit", "embedly", "preview",
+	"curl/", "wget/", "python-requests", "go-http-client", "headlesschrome",
+}
+
+// Agent describes a client.
+type Agent struct {
+	OS      string // OS is one of the OS* constants
+	Browser string // Browser is one of the Browser* constants
+	Device  string // Device is one of the Device* constants
+}
+
+// Parse classifies a User-Agent header value.
+func Parse(ua string) Agent {
+	s := strings.ToLower(ua)
+
+	a := Agent{OS: parseOS(s), Browser: parseBrowser(s)}
+	switch {
+	case ua == "" || containsAny(s, botTokens):
+		a.Device = DeviceBot
+	case strings.Contains(s, "ipad") || strings.Contains(s, "tablet") ||
+		a.OS == OSAndroid && !strings.Contains(s, "mobile"):
+		a.Device = DeviceTablet
+	case strings.Contains(s, "mobi") || strings.Contains(s, "iphone") || strings.Contains(s, "ipod"):
+		a.Device = DeviceMobile
+	default:
+		a.Device = DeviceDesktop
+	}
+	return a
+}
+
+// Bot reports whether the agent is a crawler or another automated client.
+func (a Agent) Bot() bool {
+	return a.Device == DeviceBot
+}
+
+// Is reports whether family names the agent's operating system, browser or device class.
+func (a Agent) Is(family string) bool {
+	family = strings.ToLower(family)
+	return family == a.OS || family == a.Browser || family == a.Device
+}
+
+// parseOS detects the operating system.
+func parseOS(s string) string {
+	switch {
+	case strings.Contains(s, "iphone") || strings.Contains(s, "ipad") || strings.Contains(s, "ipod"):
+		return OSiOS
+	case strings.Contains(s, "android"):
+		return OSAndroid
+	case strings.Contains(s, "windows"):
+		return OSWindows
+	case strings.Contains(s, "cros"):
+		return OSChromeOS
+	case strings.Contains(s, "mac os x") || strings.Contains(s, "macintosh"):
+		return OSMacOS
+	case strings.Contains(s, "linux"):
+		return OSLinux
+	}
+	return OSOther
+}
+
+// parseBrowser detects the browser. Order matters: most browsers also announce Safari or Chrome.
+func parseBrowser(s string) string {
+	switch {
+	case strings.Contains(s, "edg/") || strings.Contains(s, "edga/") || strings.Contains(s, "edgios/"):
+		return BrowserEdge
+	case strings.Contains(s, "opr/") || strings.Contains(s, "opera"):
+		return BrowserOpera
+	case strings.Contains(s, "firefox/") || strings.Contains(s, "fxios/"):
+		return BrowserFirefox
+	case strings.Contains(s, "chrome/") || strings.Contains(s, "crios/"):
+		return BrowserChrome
+	case strings.Contains(s, "safari/"):
+		return BrowserSafari
+	}
+	return BrowserOther
+}
+
+// containsAny reports whether s contains any of the tokens.
+func containsAny(s string, tokens []string) bool {
+	for _, t := range tokens {
+		if strings.Contains(s, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// WatchExpiry reports the links that expire, checking every ExpiryInterval until ctx is done.
+// Links that expired while the service was not running are not reported.
+func (d *Dispatcher) WatchExpiry(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.ExpiryInterval)
+	defer ticker.Stop()
+
+	since := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := d.notifyExpired(ctx, since, now); err != nil {
+				d.logError("failed to check links for expiry", "error", err)
+				continue
+			}
+			since = now
+		}
+	}
+}
+
+// notifyExpired reports the live links that expired in (since, now].
+func (d *Dispatcher) notifyExpired(ctx context.Context, since, now time.Time) error {
+	return d.storage.ForEachURL(ctx, func(urlData types.URLData) error {
+		if !urlData.DeletedFlag && urlData.Expired(now) && !urlData.Expired(since) {
+			d.Notify(urlData.UserID, types.EventLinkExpired, urlData)
+		}
+		return nil
+	})
+}
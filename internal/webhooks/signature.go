@@ -0,0 +1,60 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sign returns the signature header of a payload sent at t: "t=<unix time>,v1=<hex HMAC-SHA256>",
+// where the HMAC of the secret covers the time, a dot and the payload. Signing the time lets
+// receivers reject replayed requests.
+func Sign(secret string, t time.Time, payload []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", t.Unix(), signature(secret, t.Unix(), payload))
+}
+
+// Verify checks a signature header made by Sign and rejects signatures older than tolerance.
+// A zero tolerance accepts signatures of any age.
+func Verify(secret, header string, payload []byte, tolerance time.Duration, now time.Time) error {
+	var unix int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid signature time")
+			}
+			unix = v
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if unix == 0 || len(signatures) == 0 {
+		return fmt.Errorf("malformed signature")
+	}
+	if tolerance > 0 && now.Sub(time.Unix(unix, 0)).Abs() > tolerance {
+		return fmt.Errorf("signature is too old")
+	}
+
+	expected := signature(secret, unix, payload)
+	for _, s := range signatures {
+		if hmac.Equal([]byte(s), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature mismatch")
+}
+
+// signature returns the hex HMAC-SHA256 of the signed content.
+func signature(secret string, unix int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", unix)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// Storage is a storage that notifies the webhooks of the owners of the links changed through it,
+// so that changes made over HTTP and gRPC alike are reported.
+type Storage struct {
+	db.ShortenerStorage
+	dispatcher *Dispatcher
+}
+
+// Wrap returns storage with notifications of the dispatcher.
+func (d *Dispatcher) Wrap(storage db.ShortenerStorage) *Storage {
+	return &Storage{ShortenerStorage: storage, dispatcher: d}
+}
+
+// Put stores a new URL record and reports its creation.
+func (s *Storage) Put(urlData types.URLData) error {
+	if err := s.ShortenerStorage.Put(urlData); err != nil {
+		return err
+	}
+	s.dispatcher.Notify(urlData.UserID, types.EventLinkCreated, urlData)
+	return nil
+}
+
+// PutBatch stores a batch of URL records and reports their creation.
+func (s *Storage) PutBatch(ctx context.Context, batchData []types.URLData) error {
+	if err := s.ShortenerStorage.PutBatch(ctx, batchData); err != nil {
+		return err
+	}
+	for _, urlData := range batchData {
+		s.dispatcher.Notify(urlData.UserID, types.EventLinkCreated, urlData)
+	}
+	return nil
+}
+
+// UpdateURL changes a URL record and reports the change.
+func (s *Storage) UpdateURL(ctx context.Context, shortURL, userID string, update func(*types.URLData) error) (types.URLData, error) {
+	urlData, err := s.ShortenerStorage.UpdateURL(ctx, shortURL, userID, update)
+	if err != nil {
+		return urlData, err
+	}
+	s.dispatcher.Notify(userID, types.EventLinkUpdated, urlData)
+	return urlData, nil
+}
+
+// BatchDelete marks URLs as deleted and reports the deletion of those that were live links
+// of userID before and are deleted afterwards.
+func (s *Storage) BatchDelete(urlChannel chan string, userID string) {
+	ctx := context.Background()
+	relay := make(chan string)
+	var owned []types.URLData
+	go func() {
+		defer close(relay)
+		for shortURL := range urlChannel {
+			if urlData, err := s.ShortenerStorage.GetUserURL(ctx, shortURL, userID); err == nil {
+				owned = append(owned, urlData)
+			}
+			relay <- shortURL
+		}
+	}()
+	s.ShortenerStorage.BatchDelete(relay, userID)
+
+	// BatchDelete returns once relay is closed, so owned is complete
+	for _, urlData := range owned {
+		if _, err := s.ShortenerStorage.Get(urlData.ShortURL); err != nil && strings.Contains(err.Error(), "deleted") {
+			s.dispatcher.Notify(userID, types.EventLinkDeleted, urlData)
+		}
+	}
+}
+
+// PutClicks stores a batch of click events and checks the click thresholds of the visited links.
+func (s *Storage) PutClicks(ctx context.Context, events []types.ClickEvent) error {
+	if err := s.ShortenerStorage.PutClicks(ctx, events); err != nil {
+		return err
+	}
+
+	visits := make(map[string]linkVisits)
+	for _, e := range events {
+		if e.Bot {
+			continue
+		}
+		v := visits[e.ShortURL]
+		v.count++
+		if e.Time.After(v.last) {
+			v.last = e.Time
+		}
+		visits[e.ShortURL] = v
+	}
+	// Counting right after the write keeps the first count, which is read from storage,
+	// free of later batches
+	s.dispatcher.countClicks(ctx, visits)
+	return nil
+}
+
+// linkVisits are the visits of people to a link in a batch of click events.
+type linkVisits struct {
+	count int64
+	last  time.Time // last is the time of the latest visit
+}
+
+// countClicks adds stored visits to the counts of the links and reports the click thresholds
+// that were reached. Links are counted only while their owners have webhooks with thresholds;
+// the first count of a link is read from storage.
+func (d *Dispatcher) countClicks(ctx context.Context, visits map[string]linkVisits) {
+	d.clicksMu.Lock()
+	defer d.clicksMu.Unlock()
+
+	for shortURL, v := range visits {
+		urlData, err := d.storage.Get(shortURL)
+		if err != nil {
+			delete(d.clicks, shortURL)
+			continue
+		}
+		hooks, err := d.storage.GetWebhooks(ctx, urlData.UserID)
+		if err != nil {
+			d.logError("failed to get webhooks", "error", err)
+			continue
+		}
+		var thresholds []int64
+		for _, hook := range hooks {
+			if hook.Wants(types.EventClickThreshold) {
+				thresholds = append(thresholds, hook.ClickThresholds...)
+			}
+		}
+		if len(thresholds) == 0 {
+			delete(d.clicks, shortURL)
+			continue
+		}
+
+		total, ok := d.clicks[shortURL]
+		if ok {
+			total += v.count
+		} else if total, err = d.storedClicks(ctx, shortURL, v.last); err != nil {
+			d.logError("failed to count clicks", "short_url", shortURL, "error", err)
+			continue
+		}
+		previous := total - v.count
+		d.clicks[shortURL] = total
+
+		slices.Sort(thresholds)
+		for _, threshold := range slices.Compact(thresholds) {
+			if previous < threshold && threshold <= total {
+				event := Event{Type: types.EventClickThreshold, Link: d.link(urlData), Threshold: threshold, Clicks: total}
+				d.enqueueEvent(func(ctx context.Context) {
+					d.send(ctx, urlData.UserID, event)
+				})
+			}
+		}
+	}
+}
+
+// storedClicks counts the stored visits of people to a link up to the given time.
+func (d *Dispatcher) storedClicks(ctx context.Context, shortURL string, last time.Time) (int64, error) {
+	var n int64
+	err := d.storage.ForEachClick(ctx, shortURL, time.Unix(0, 0), last.Add(time.Nanosecond), func(e types.ClickEvent) error {
+		if !e.Bot {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,525 @@
+// Package webhooks notifies the systems of users about the lifecycle of their links.
+//
+// Events are turned into deliveries, one per subscribed webhook, that are stored before
+// they are sent. Failed deliveries are retried with exponential backoff until they run
+// out of attempts and are kept as dead letters; pending deliveries survive restarts.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/jayjaytrn/URLShortener/internal/db"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// Request headers of a delivery.
+const (
+	HeaderEvent     = "X-Webhook-Event"     // HeaderEvent names the event
+	HeaderDelivery  = "X-Webhook-Delivery"  // HeaderDelivery identifies the delivery, which is the same across retries
+	HeaderSignature = "X-Webhook-Signature" // HeaderSignature holds the signature, see Sign
+)
+
+// maxBackoff bounds the delay between two attempts of a delivery.
+const maxBackoff = 6 * time.Hour
+
+// maxErrorBody bounds the part of a failed response kept in the delivery log.
+const maxErrorBody = 256
+
+// Default options.
+const (
+	defaultMaxAttempts    = 8
+	defaultRetryBackoff   = 30 * time.Second
+	defaultTimeout        = 10 * time.Second
+	defaultPollInterval   = 5 * time.Second
+	defaultWorkers        = 4
+	defaultQueueSize      = 1000
+	defaultExpiryInterval = time.Minute
+)
+
+// Event is the payload sent to webhooks.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Link      Link      `json:"link"`
+	Threshold int64     `json:"threshold,omitempty"` // Threshold is the click threshold that was reached
+	Clicks    int64     `json:"clicks,omitempty"`    // Clicks is the number of visits of people when the threshold was reached
+}
+
+// Link describes the link an event is about.
+type Link struct {
+	Code        string     `json:"code"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Options configure a dispatcher.
+type Options struct {
+	BaseURL        string         // BaseURL prefixes link codes in payloads
+	MaxAttempts    int            // MaxAttempts is the number of attempts before a delivery becomes a dead letter
+	RetryBackoff   time.Duration  // RetryBackoff is the delay before the first retry; it doubles with every attempt
+	Timeout        time.Duration  // Timeout bounds a single attempt
+	PollInterval   time.Duration  // PollInterval is how often deliveries due for a retry are picked up
+	ExpiryInterval time.Duration  // ExpiryInterval is how often links are checked for expiry
+	Workers        int            // Workers is the number of deliveries sent at once
+	QueueSize      int            // QueueSize is the number of events waiting to be turned into deliveries
+	Policy         *policy.Engine // Policy decides which targets webhooks may have; the default policy, which denies private addresses, is used when it is nil
+	Client         *http.Client   // Client sends the deliveries; a client with Timeout that enforces Policy when dialing is used when it is nil
+}
+
+// Dispatcher turns events into deliveries and sends them in the background.
+type Dispatcher struct {
+	storage db.ShortenerStorage
+	opts    Options
+	policy  *policy.Engine
+	client  *http.Client
+	logger  *zap.SugaredLogger
+
+	mu       sync.Mutex // mu guards closed and stopped against sends on the closed queues, and inFlight
+	closed   bool
+	stopped  bool
+	inFlight map[string]bool
+	events   chan func(context.Context)
+	queue    chan types.Delivery
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	emitted  chan struct{}
+
+	clicksMu sync.Mutex
+	clicks   map[string]int64 // clicks counts the visits of people to links watched for click thresholds
+}
+
+// New starts a dispatcher that keeps webhooks and deliveries in storage.
+// Deliveries left pending by a previous run are resumed.
+func New(storage db.ShortenerStorage, opts Options, logger *zap.SugaredLogger) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = defaultRetryBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.ExpiryInterval <= 0 {
+		opts.ExpiryInterval = defaultExpiryInterval
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	pol := opts.Policy
+	if pol == nil {
+		pol, _ = policy.New(policy.File{})
+	}
+	client := opts.Client
+	if client == nil {
+		client = newClient(pol, opts.Timeout)
+	}
+
+	d := &Dispatcher{
+		storage:  storage,
+		opts:     opts,
+		policy:   pol,
+		client:   client,
+		logger:   logger,
+		inFlight: make(map[string]bool),
+		events:   make(chan func(context.Context), opts.QueueSize),
+		queue:    make(chan types.Delivery, opts.QueueSize),
+		stop:     make(chan struct{}),
+		emitted:  make(chan struct{}),
+		clicks:   make(map[string]int64),
+	}
+
+	go d.emit()
+	d.wg.Add(opts.Workers + 1)
+	for i := 0; i < opts.Workers; i++ {
+		go d.work()
+	}
+	go d.poll()
+	return d
+}
+
+// newClient returns a client that refuses to connect to addresses the policy rejects, which
+// is checked on the addresses names resolve to, and that does not follow redirects.
+func newClient(pol *policy.Engine, timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return pol.CheckAddress(net.ParseIP(host))
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed instead of the target, so none is used
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// NewWebhook validates a subscription request of userID and returns the webhook to store,
+// with a new ID and secret. Targets rejected by the policy of the dispatcher are refused.
+func (d *Dispatcher) NewWebhook(userID string, req types.WebhookRequest, now time.Time) (types.Webhook, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return types.Webhook{}, fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if err = d.policy.Check(u.String()); err != nil {
+		return types.Webhook{}, err
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(types.WebhookEvents, event) {
+			return types.Webhook{}, fmt.Errorf("unknown event %q", event)
+		}
+	}
+	for _, threshold := range req.ClickThresholds {
+		if threshold <= 0 {
+			return types.Webhook{}, fmt.Errorf("click thresholds must be positive")
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return types.Webhook{}, err
+	}
+	events := slices.Clone(req.Events)
+	slices.Sort(events)
+	thresholds := slices.Clone(req.ClickThresholds)
+	slices.Sort(thresholds)
+	return types.Webhook{
+		ID:              uuid.New().String(),
+		UserID:          userID,
+		URL:             u.String(),
+		Events:          slices.Compact(events),
+		ClickThresholds: slices.Compact(thresholds),
+		Secret:          hex.EncodeToString(secret),
+		CreatedAt:       now.UTC(),
+	}, nil
+}
+
+// Notify queues an event about a link of userID. It never blocks: when the queue is full
+// the event is dropped and logged.
+func (d *Dispatcher) Notify(userID, eventType string, urlData types.URLData) {
+	event := Event{Type: eventType, Link: d.link(urlData)}
+	d.enqueueEvent(func(ctx context.Context) {
+		d.send(ctx, userID, event)
+	})
+}
+
+// enqueueEvent queues work that creates deliveries.
+func (d *Dispatcher) enqueueEvent(fn func(context.Context)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+	select {
+	case d.events <- fn:
+	default:
+		d.logError("webhook event queue is full, event dropped")
+	}
+}
+
+// emit runs the queued event work until the event queue is closed and drained.
+func (d *Dispatcher) emit() {
+	defer close(d.emitted)
+	for fn := range d.events {
+		ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+		fn(ctx)
+		cancel()
+	}
+}
+
+// send stores a delivery of the event for every webhook of userID that subscribes to it
+// and queues the deliveries.
+func (d *Dispatcher) send(ctx context.Context, userID string, event Event) {
+	hooks, err := d.storage.GetWebhooks(ctx, userID)
+	if err != nil {
+		d.logError("failed to get webhooks", "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	event.ID = uuid.New().String()
+	event.Time = now
+	payload, err := json.Marshal(event)
+	if err != nil {
+		d.logError("failed to encode webhook event", "error", err)
+		return
+	}
+
+	for _, hook := range hooks {
+		if !hook.Wants(event.Type) {
+			continue
+		}
+		if event.Type == types.EventClickThreshold && !slices.Contains(hook.ClickThresholds, event.Threshold) {
+			continue
+		}
+
+		delivery := types.Delivery{
+			ID:          uuid.New().String(),
+			WebhookID:   hook.ID,
+			UserID:      userID,
+			Event:       event.Type,
+			Payload:     payload,
+			Status:      types.DeliveryPending,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			NextAttempt: &now,
+		}
+		if err = d.storage.PutDelivery(ctx, delivery); err != nil {
+			d.logError("failed to store webhook delivery", "webhook", hook.ID, "error", err)
+			continue
+		}
+		d.enqueue(delivery)
+	}
+}
+
+// enqueue queues a delivery for a worker unless it is already queued. A delivery that does
+// not fit in the queue stays pending and is picked up by the next poll.
+func (d *Dispatcher) enqueue(delivery types.Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || d.inFlight[delivery.ID] {
+		return
+	}
+	select {
+	case d.queue <- delivery:
+		d.inFlight[delivery.ID] = true
+	default:
+	}
+}
+
+// poll queues the pending deliveries that are due, at start and then every PollInterval.
+func (d *Dispatcher) poll() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+		deliveries, err := d.storage.GetDeliveries(ctx, "", types.DeliveryPending)
+		cancel()
+		if err != nil {
+			d.logError("failed to get pending webhook deliveries", "error", err)
+		}
+		now := time.Now()
+		// Oldest first, so that retries keep the order of events
+		for i := len(deliveries) - 1; i >= 0; i-- {
+			if next := deliveries[i].NextAttempt; next == nil || !next.After(now) {
+				d.enqueue(deliveries[i])
+			}
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// work sends queued deliveries until the queue is closed.
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for delivery := range d.queue {
+		d.attempt(delivery)
+
+		d.mu.Lock()
+		delete(d.inFlight, delivery.ID)
+		d.mu.Unlock()
+	}
+}
+
+// attempt sends a delivery once and stores the outcome.
+func (d *Dispatcher) attempt(delivery types.Delivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+
+	hooks, err := d.storage.GetWebhooks(ctx, delivery.UserID)
+	if err != nil {
+		d.logError("failed to get webhooks", "error", err)
+		return
+	}
+	i := slices.IndexFunc(hooks, func(hook types.Webhook) bool { return hook.ID == delivery.WebhookID })
+	if i < 0 {
+		// The webhook was removed together with its deliveries
+		return
+	}
+
+	now := time.Now().UTC()
+	code, err := d.post(ctx, hooks[i], delivery, now)
+	delivery.Attempts++
+	delivery.ResponseCode = code
+	delivery.UpdatedAt = now
+	delivery.NextAttempt = nil
+	switch {
+	case err == nil:
+		delivery.Status = types.DeliveryDelivered
+		delivery.Error = ""
+	case delivery.Attempts >= d.opts.MaxAttempts:
+		delivery.Status = types.DeliveryDead
+		delivery.Error = err.Error()
+	default:
+		next := now.Add(Backoff(d.opts.RetryBackoff, delivery.Attempts))
+		delivery.Status = types.DeliveryPending
+		delivery.Error = err.Error()
+		delivery.NextAttempt = &next
+	}
+
+	if err = d.storage.PutDelivery(ctx, delivery); err != nil {
+		d.logError("failed to store webhook delivery", "delivery", delivery.ID, "error", err)
+	}
+}
+
+// post sends the payload of a delivery to the webhook and returns the response status.
+// Responses other than 2xx are errors.
+func (d *Dispatcher) post(ctx context.Context, hook types.Webhook, delivery types.Delivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "URLShortener-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, now, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the delay after the given number of failed attempts: base, then twice
+// as long after every further failure, up to six hours.
+func Backoff(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// Retry sends a dead letter again with a fresh set of attempts and returns the updated delivery.
+// Deliveries that are not dead letters are reported as "delivery not found".
+func (d *Dispatcher) Retry(ctx context.Context, webhookID, deliveryID string) (types.Delivery, error) {
+	deliveries, err := d.storage.GetDeliveries(ctx, webhookID, types.DeliveryDead)
+	if err != nil {
+		return types.Delivery{}, err
+	}
+	i := slices.IndexFunc(deliveries, func(delivery types.Delivery) bool { return delivery.ID == deliveryID })
+	if i < 0 {
+		return types.Delivery{}, fmt.Errorf("delivery not found")
+	}
+
+	now := time.Now().UTC()
+	delivery := deliveries[i]
+	delivery.Status = types.DeliveryPending
+	delivery.Attempts = 0
+	delivery.UpdatedAt = now
+	delivery.NextAttempt = &now
+	if err = d.storage.PutDelivery(ctx, delivery); err != nil {
+		return types.Delivery{}, err
+	}
+	d.enqueue(delivery)
+	return delivery, nil
+}
+
+// Close stops accepting events and waits until the queued events are turned into deliveries
+// and the deliveries being sent are done, or ctx is done. Queued deliveries that were not
+// sent stay pending and are resumed by the next run.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.events)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.emitted:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	d.mu.Lock()
+	if !d.stopped {
+		d.stopped = true
+		close(d.stop)
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// link describes a link in an event.
+func (d *Dispatcher) link(urlData types.URLData) Link {
+	return Link{
+		Code:        urlData.ShortURL,
+		ShortURL:    d.opts.BaseURL + "/" + urlData.ShortURL,
+		OriginalURL: urlData.OriginalURL,
+		ExpiresAt:   urlData.ExpiresAt,
+	}
+}
+
+// logError logs a problem of the background work when the dispatcher has a logger.
+func (d *Dispatcher) logError(msg string, keysAndValues ...any) {
+	if d.logger != nil {
+		d.logger.Errorw(msg, keysAndValues...)
+	}
+}
@@ -0,0 +1,328 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jayjaytrn/URLShortener/config"
+	"github.com/jayjaytrn/URLShortener/internal/db/memorystorage"
+	"github.com/jayjaytrn/URLShortener/internal/policy"
+	"github.com/jayjaytrn/URLShortener/internal/types"
+)
+
+// receiver is a webhook endpoint that checks signatures and passes on the events it accepts.
+type receiver struct {
+	secret string
+	fail   atomic.Bool
+	events chan Event
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	if r.fail.Load() {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if err := Verify(r.secret, req.Header.Get(HeaderSignature), body, time.Minute, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var event Event
+	json.Unmarshal(body, &event)
+	if req.Header.Get(HeaderEvent) != event.Type || req.Header.Get(HeaderDelivery) == "" {
+		http.Error(w, "missing headers", http.StatusBadRequest)
+		return
+	}
+	r.events <- event
+}
+
+// setup starts a dispatcher over memory storage with a webhook of "owner" pointing at a test receiver.
+func setup(t *testing.T, req types.WebhookRequest) (*Dispatcher, *Storage, *receiver, types.Webhook) {
+	t.Helper()
+	storage, _ := memorystorage.NewManager(&config.Config{StorageType: "memory"})
+
+	r := &receiver{events: make(chan Event, 10)}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	d := New(storage, Options{
+		BaseURL:      "http://localhost:8080",
+		MaxAttempts:  2,
+		RetryBackoff: time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+		Policy:       loopbackPolicy(t),
+	}, nil)
+	t.Cleanup(func() { d.Close(context.Background()) })
+
+	req.URL = server.URL + "/hook"
+	hook, err := d.NewWebhook("owner", req, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.secret = hook.Secret
+	storage.PutWebhook(context.Background(), hook)
+	return d, d.Wrap(storage), r, hook
+}
+
+// loopbackPolicy allows the targets of test servers only.
+func loopbackPolicy(t *testing.T) *policy.Engine {
+	t.Helper()
+	pol, err := policy.New(policy.File{Allow: policy.Rules{CIDRs: []string{"127.0.0.0/8"}}})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	return pol
+}
+
+// deadLetters waits for the dead letters of a webhook.
+func deadLetters(t *testing.T, storage *Storage, hookID string) []types.Delivery {
+	t.Helper()
+	var dead []types.Delivery
+	for deadline := time.Now().Add(5 * time.Second); len(dead) == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		dead, _ = storage.GetDeliveries(context.Background(), hookID, types.DeliveryDead)
+	}
+	return dead
+}
+
+// receive waits for the next event of the receiver.
+func receive(t *testing.T, r *receiver) Event {
+	t.Helper()
+	select {
+	case event := <-r.events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event received")
+		return Event{}
+	}
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	_, storage, r, _ := setup(t, types.WebhookRequest{})
+	ctx := context.Background()
+
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+	storage.Put(types.URLData{ShortURL: "other123", OriginalURL: "https://example.com/", UserID: "someone"})
+	if event := receive(t, r); event.Type != types.EventLinkCreated || event.Link.ShortURL != "http://localhost:8080/abcd1234" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	storage.UpdateURL(ctx, "abcd1234", "owner", func(urlData *types.URLData) error {
+		urlData.OriginalURL = "https://example.org/"
+		return nil
+	})
+	if event := receive(t, r); event.Type != types.EventLinkUpdated || event.Link.OriginalURL != "https://example.org/" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	urls := make(chan string, 3)
+	urls <- "abcd1234"
+	urls <- "other123"
+	urls <- "missing1"
+	close(urls)
+	storage.BatchDelete(urls, "owner")
+	if event := receive(t, r); event.Type != types.EventLinkDeleted || event.Link.Code != "abcd1234" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	select {
+	case event := <-r.events:
+		t.Errorf("unexpected event %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	d, storage, r, _ := setup(t, types.WebhookRequest{Events: []string{types.EventLinkExpired}})
+
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+	expires := time.Now().Add(-time.Second)
+	storage.Put(types.URLData{ShortURL: "efgh5678", OriginalURL: "https://example.com/", UserID: "owner", ExpiresAt: &expires})
+
+	if err := d.notifyExpired(context.Background(), expires.Add(-time.Minute), time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event := receive(t, r); event.Type != types.EventLinkExpired || event.Link.Code != "efgh5678" {
+		t.Errorf("expected only the expiry to be sent, got %+v", event)
+	}
+}
+
+func TestClickThresholds(t *testing.T) {
+	_, storage, r, _ := setup(t, types.WebhookRequest{Events: []string{types.EventClickThreshold}, ClickThresholds: []int64{2, 3, 10}})
+	ctx := context.Background()
+	now := time.Now()
+
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+	storage.PutClicks(ctx, []types.ClickEvent{{ShortURL: "abcd1234", Time: now}})
+	storage.PutClicks(ctx, []types.ClickEvent{
+		{ShortURL: "abcd1234", Time: now},
+		{ShortURL: "abcd1234", Time: now, Bot: true},
+		{ShortURL: "abcd1234", Time: now},
+	})
+
+	// Deliveries are sent in parallel, so the thresholds may arrive in any order
+	reached := make(map[int64]bool)
+	for i := 0; i < 2; i++ {
+		event := receive(t, r)
+		if event.Type != types.EventClickThreshold || event.Clicks != 3 {
+			t.Errorf("unexpected event %+v", event)
+		}
+		reached[event.Threshold] = true
+	}
+	if !reached[2] || !reached[3] {
+		t.Errorf("expected thresholds 2 and 3, got %v", reached)
+	}
+	select {
+	case event := <-r.events:
+		t.Errorf("unexpected event %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	d, storage, r, hook := setup(t, types.WebhookRequest{})
+	ctx := context.Background()
+	r.fail.Store(true)
+
+	storage.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+
+	dead := deadLetters(t, storage, hook.ID)
+	if len(dead) != 1 || dead[0].Attempts != 2 || dead[0].ResponseCode != http.StatusServiceUnavailable || dead[0].Error == "" {
+		t.Fatalf("expected a dead letter after two attempts, got %+v", dead)
+	}
+
+	r.fail.Store(false)
+	if _, err := d.Retry(ctx, hook.ID, dead[0].ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event := receive(t, r); event.Type != types.EventLinkCreated {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if _, err := d.Retry(ctx, hook.ID, dead[0].ID); err == nil {
+		t.Errorf("expected a delivery that is no longer dead to be refused")
+	}
+}
+
+func TestTargetPolicy(t *testing.T) {
+	// The redirect target accepts every delivery, so following the redirect would deliver it
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer target.Close()
+	redirector := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirector.Close()
+
+	tests := []struct {
+		name     string
+		policy   *policy.Engine
+		url      string
+		wantCode int
+		wantErr  string
+	}{
+		{name: "Name resolving to a private address", url: strings.Replace(target.URL, "127.0.0.1", "localhost", 1), wantErr: policy.ReasonPrivateAddress},
+		{name: "Redirect", policy: loopbackPolicy(t), url: redirector.URL, wantCode: http.StatusTemporaryRedirect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, _ := memorystorage.NewManager(&config.Config{StorageType: "memory"})
+			d := New(storage, Options{MaxAttempts: 1, PollInterval: 5 * time.Millisecond, Policy: tt.policy}, nil)
+			defer d.Close(context.Background())
+
+			// The webhook is stored directly, as if the name had resolved to a public address at creation
+			hook := types.Webhook{ID: "hook", UserID: "owner", URL: tt.url, Secret: "secret"}
+			storage.PutWebhook(context.Background(), hook)
+			wrapped := d.Wrap(storage)
+			wrapped.Put(types.URLData{ShortURL: "abcd1234", OriginalURL: "https://practicum.yandex.ru/", UserID: "owner"})
+
+			dead := deadLetters(t, wrapped, hook.ID)
+			if len(dead) != 1 || dead[0].ResponseCode != tt.wantCode || !strings.Contains(dead[0].Error, tt.wantErr) {
+				t.Errorf("expected a dead letter with status %d and error %q, got %+v", tt.wantCode, tt.wantErr, dead)
+			}
+		})
+	}
+}
+
+func TestNewWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     types.WebhookRequest
+		wantErr bool
+	}{
+		{name: "Valid", req: types.WebhookRequest{URL: "https://example.com/hook", Events: []string{types.EventLinkCreated}, ClickThresholds: []int64{100}}},
+		{name: "Relative URL", req: types.WebhookRequest{URL: "/hook"}, wantErr: true},
+		{name: "Other scheme", req: types.WebhookRequest{URL: "ftp://example.com/"}, wantErr: true},
+		{name: "Unknown event", req: types.WebhookRequest{URL: "https://example.com/hook", Events: []string{"link.visited"}}, wantErr: true},
+		{name: "Negative threshold", req: types.WebhookRequest{URL: "https://example.com/hook", ClickThresholds: []int64{-1}}, wantErr: true},
+		{name: "Loopback", req: types.WebhookRequest{URL: "http://127.0.0.1:6379/"}, wantErr: true},
+		{name: "Metadata service", req: types.WebhookRequest{URL: "http://169.254.169.254/latest/meta-data/"}, wantErr: true},
+	}
+
+	storage, _ := memorystorage.NewManager(&config.Config{StorageType: "memory"})
+	d := New(storage, Options{}, nil)
+	defer d.Close(context.Background())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, err := d.NewWebhook("owner", tt.req, time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && (hook.ID == "" || len(hook.Secret) != 64) {
+				t.Errorf("expected an ID and a secret, got %+v", hook)
+			}
+		})
+	}
+}
+
+func TestSignature(t *testing.T) {
+	now := time.Now()
+	payload := []byte(`{"type":"link.created"}`)
+	header := Sign("secret", now, payload)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "Valid", secret: "secret", header: header, payload: payload, now: now},
+		{name: "Wrong secret", secret: "other", header: header, payload: payload, now: now, wantErr: true},
+		{name: "Changed payload", secret: "secret", header: header, payload: []byte(`{}`), now: now, wantErr: true},
+		{name: "Replayed", secret: "secret", header: header, payload: payload, now: now.Add(time.Hour), wantErr: true},
+		{name: "Malformed", secret: "secret", header: "v1=abc", payload: payload, now: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, tt.payload, 5*time.Minute, tt.now); (err != nil) != tt.wantErr {
+				t.Errorf("unexpected result: %v", err)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 30 * time.Second},
+		{attempts: 2, expected: time.Minute},
+		{attempts: 4, expected: 4 * time.Minute},
+		{attempts: 20, expected: maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if delay := Backoff(30*time.Second, tt.attempts); delay != tt.expected {
+			t.Errorf("expected %v after %d attempts, got %v", tt.expected, tt.attempts, delay)
+		}
+	}
+}
@@ -25,6 +25,7 @@ const (
 type ShortenRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Options       *CreateOptions         `protobuf:"bytes,2,opt,name=options,proto3" json:"options,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -66,6 +67,114 @@ func (x *ShortenRequest) GetUrl() string {
 	return ""
 }
 
+func (x *ShortenRequest) GetOptions() *CreateOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+// Optional settings for a new short URL.
+type CreateOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ttl           int64                  `protobuf:"varint,1,opt,name=ttl,proto3" json:"ttl,omitempty"`                                       // lifetime in seconds
+	ExpiresAt     string                 `protobuf:"bytes,2,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`           // absolute expiry time, RFC 3339
+	MaxClicks     int64                  `protobuf:"varint,3,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`          // number of redirects before the link stops working, 0 for unlimited
+	Password      string                 `protobuf:"bytes,4,opt,name=password,proto3" json:"password,omitempty"`                              // password visitors must enter before being redirected
+	RedirectType  int32                  `protobuf:"varint,5,opt,name=redirect_type,json=redirectType,proto3" json:"redirect_type,omitempty"` // redirect status: 301, 302, 307 or 308; 0 for the server default
+	CacheTtl      *int64                 `protobuf:"varint,6,opt,name=cache_ttl,json=cacheTtl,proto3,oneof" json:"cache_ttl,omitempty"`       // redirect cache lifetime in seconds, 0 for no-store; unset for the server default
+	NotBefore     string                 `protobuf:"bytes,7,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`           // start of the activation window, RFC 3339
+	NotAfter      string                 `protobuf:"bytes,8,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`              // end of the activation window, RFC 3339
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateOptions) Reset() {
+	*x = CreateOptions{}
+	mi := &file_shortener_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateOptions) ProtoMessage() {}
+
+func (x *CreateOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateOptions.ProtoReflect.Descriptor instead.
+func (*CreateOptions) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateOptions) GetTtl() int64 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *CreateOptions) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *CreateOptions) GetMaxClicks() int64 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *CreateOptions) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *CreateOptions) GetRedirectType() int32 {
+	if x != nil {
+		return x.RedirectType
+	}
+	return 0
+}
+
+func (x *CreateOptions) GetCacheTtl() int64 {
+	if x != nil && x.CacheTtl != nil {
+		return *x.CacheTtl
+	}
+	return 0
+}
+
+func (x *CreateOptions) GetNotBefore() string {
+	if x != nil {
+		return x.NotBefore
+	}
+	return ""
+}
+
+func (x *CreateOptions) GetNotAfter() string {
+	if x != nil {
+		return x.NotAfter
+	}
+	return ""
+}
+
 type ShortenResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Result        string                 `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
@@ -75,7 +184,7 @@ type ShortenResponse struct {
 
 func (x *ShortenResponse) Reset() {
 	*x = ShortenResponse{}
-	mi := &file_shortener_proto_msgTypes[1]
+	mi := &file_shortener_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -87,7 +196,7 @@ func (x *ShortenResponse) String() string {
 func (*ShortenResponse) ProtoMessage() {}
 
 func (x *ShortenResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[1]
+	mi := &file_shortener_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -100,7 +209,7 @@ func (x *ShortenResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShortenResponse.ProtoReflect.Descriptor instead.
 func (*ShortenResponse) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{1}
+	return file_shortener_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *ShortenResponse) GetResult() string {
@@ -113,13 +222,14 @@ func (x *ShortenResponse) GetResult() string {
 type URLReturnerRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ShortUrl      string                 `protobuf:"bytes,1,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"` // required for password-protected links
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *URLReturnerRequest) Reset() {
 	*x = URLReturnerRequest{}
-	mi := &file_shortener_proto_msgTypes[2]
+	mi := &file_shortener_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -131,7 +241,7 @@ func (x *URLReturnerRequest) String() string {
 func (*URLReturnerRequest) ProtoMessage() {}
 
 func (x *URLReturnerRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[2]
+	mi := &file_shortener_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -144,7 +254,7 @@ func (x *URLReturnerRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use URLReturnerRequest.ProtoReflect.Descriptor instead.
 func (*URLReturnerRequest) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{2}
+	return file_shortener_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *URLReturnerRequest) GetShortUrl() string {
@@ -154,6 +264,13 @@ func (x *URLReturnerRequest) GetShortUrl() string {
 	return ""
 }
 
+func (x *URLReturnerRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
 type URLReturnerResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	OriginalUrl   string                 `protobuf:"bytes,1,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
@@ -163,7 +280,7 @@ type URLReturnerResponse struct {
 
 func (x *URLReturnerResponse) Reset() {
 	*x = URLReturnerResponse{}
-	mi := &file_shortener_proto_msgTypes[3]
+	mi := &file_shortener_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -175,7 +292,7 @@ func (x *URLReturnerResponse) String() string {
 func (*URLReturnerResponse) ProtoMessage() {}
 
 func (x *URLReturnerResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[3]
+	mi := &file_shortener_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -188,7 +305,7 @@ func (x *URLReturnerResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use URLReturnerResponse.ProtoReflect.Descriptor instead.
 func (*URLReturnerResponse) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{3}
+	return file_shortener_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *URLReturnerResponse) GetOriginalUrl() string {
@@ -207,7 +324,7 @@ type ShortenBatchListRequest struct {
 
 func (x *ShortenBatchListRequest) Reset() {
 	*x = ShortenBatchListRequest{}
-	mi := &file_shortener_proto_msgTypes[4]
+	mi := &file_shortener_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -219,7 +336,7 @@ func (x *ShortenBatchListRequest) String() string {
 func (*ShortenBatchListRequest) ProtoMessage() {}
 
 func (x *ShortenBatchListRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[4]
+	mi := &file_shortener_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -232,7 +349,7 @@ func (x *ShortenBatchListRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShortenBatchListRequest.ProtoReflect.Descriptor instead.
 func (*ShortenBatchListRequest) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{4}
+	return file_shortener_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ShortenBatchListRequest) GetUrls() []*ShortenBatchRequest {
@@ -246,13 +363,14 @@ type ShortenBatchRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	CorrelationId string                 `protobuf:"bytes,1,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
 	OriginalUrl   string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	Options       *CreateOptions         `protobuf:"bytes,3,opt,name=options,proto3" json:"options,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ShortenBatchRequest) Reset() {
 	*x = ShortenBatchRequest{}
-	mi := &file_shortener_proto_msgTypes[5]
+	mi := &file_shortener_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -264,7 +382,7 @@ func (x *ShortenBatchRequest) String() string {
 func (*ShortenBatchRequest) ProtoMessage() {}
 
 func (x *ShortenBatchRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[5]
+	mi := &file_shortener_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -277,7 +395,7 @@ func (x *ShortenBatchRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShortenBatchRequest.ProtoReflect.Descriptor instead.
 func (*ShortenBatchRequest) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{5}
+	return file_shortener_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ShortenBatchRequest) GetCorrelationId() string {
@@ -294,6 +412,13 @@ func (x *ShortenBatchRequest) GetOriginalUrl() string {
 	return ""
 }
 
+func (x *ShortenBatchRequest) GetOptions() *CreateOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
 type ShortenBatchListResponse struct {
 	state         protoimpl.MessageState  `protogen:"open.v1"`
 	Urls          []*ShortenBatchResponse `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`
@@ -303,7 +428,7 @@ type ShortenBatchListResponse struct {
 
 func (x *ShortenBatchListResponse) Reset() {
 	*x = ShortenBatchListResponse{}
-	mi := &file_shortener_proto_msgTypes[6]
+	mi := &file_shortener_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -315,7 +440,7 @@ func (x *ShortenBatchListResponse) String() string {
 func (*ShortenBatchListResponse) ProtoMessage() {}
 
 func (x *ShortenBatchListResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[6]
+	mi := &file_shortener_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -328,7 +453,7 @@ func (x *ShortenBatchListResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShortenBatchListResponse.ProtoReflect.Descriptor instead.
 func (*ShortenBatchListResponse) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{6}
+	return file_shortener_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *ShortenBatchListResponse) GetUrls() []*ShortenBatchResponse {
@@ -348,7 +473,7 @@ type ShortenBatchResponse struct {
 
 func (x *ShortenBatchResponse) Reset() {
 	*x = ShortenBatchResponse{}
-	mi := &file_shortener_proto_msgTypes[7]
+	mi := &file_shortener_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -360,7 +485,7 @@ func (x *ShortenBatchResponse) String() string {
 func (*ShortenBatchResponse) ProtoMessage() {}
 
 func (x *ShortenBatchResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[7]
+	mi := &file_shortener_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -373,7 +498,7 @@ func (x *ShortenBatchResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShortenBatchResponse.ProtoReflect.Descriptor instead.
 func (*ShortenBatchResponse) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{7}
+	return file_shortener_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *ShortenBatchResponse) GetCorrelationId() string {
@@ -399,7 +524,7 @@ type UrlsRequest struct {
 
 func (x *UrlsRequest) Reset() {
 	*x = UrlsRequest{}
-	mi := &file_shortener_proto_msgTypes[8]
+	mi := &file_shortener_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -411,7 +536,7 @@ func (x *UrlsRequest) String() string {
 func (*UrlsRequest) ProtoMessage() {}
 
 func (x *UrlsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[8]
+	mi := &file_shortener_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -424,7 +549,7 @@ func (x *UrlsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UrlsRequest.ProtoReflect.Descriptor instead.
 func (*UrlsRequest) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{8}
+	return file_shortener_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *UrlsRequest) GetUserId() string {
@@ -443,7 +568,7 @@ type UrlsResponse struct {
 
 func (x *UrlsResponse) Reset() {
 	*x = UrlsResponse{}
-	mi := &file_shortener_proto_msgTypes[9]
+	mi := &file_shortener_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -455,7 +580,7 @@ func (x *UrlsResponse) String() string {
 func (*UrlsResponse) ProtoMessage() {}
 
 func (x *UrlsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[9]
+	mi := &file_shortener_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -468,7 +593,7 @@ func (x *UrlsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UrlsResponse.ProtoReflect.Descriptor instead.
 func (*UrlsResponse) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{9}
+	return file_shortener_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *UrlsResponse) GetUrls() []*UserURL {
@@ -488,7 +613,7 @@ type UserURL struct {
 
 func (x *UserURL) Reset() {
 	*x = UserURL{}
-	mi := &file_shortener_proto_msgTypes[10]
+	mi := &file_shortener_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -500,7 +625,7 @@ func (x *UserURL) String() string {
 func (*UserURL) ProtoMessage() {}
 
 func (x *UserURL) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[10]
+	mi := &file_shortener_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -513,7 +638,7 @@ func (x *UserURL) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UserURL.ProtoReflect.Descriptor instead.
 func (*UserURL) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{10}
+	return file_shortener_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *UserURL) GetShortUrl() string {
@@ -540,7 +665,7 @@ type DeleteUrlsAsyncRequest struct {
 
 func (x *DeleteUrlsAsyncRequest) Reset() {
 	*x = DeleteUrlsAsyncRequest{}
-	mi := &file_shortener_proto_msgTypes[11]
+	mi := &file_shortener_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -552,7 +677,7 @@ func (x *DeleteUrlsAsyncRequest) String() string {
 func (*DeleteUrlsAsyncRequest) ProtoMessage() {}
 
 func (x *DeleteUrlsAsyncRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[11]
+	mi := &file_shortener_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -565,7 +690,7 @@ func (x *DeleteUrlsAsyncRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteUrlsAsyncRequest.ProtoReflect.Descriptor instead.
 func (*DeleteUrlsAsyncRequest) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{11}
+	return file_shortener_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *DeleteUrlsAsyncRequest) GetUserId() string {
@@ -591,7 +716,7 @@ type DeleteUrlsAsyncResponse struct {
 
 func (x *DeleteUrlsAsyncResponse) Reset() {
 	*x = DeleteUrlsAsyncResponse{}
-	mi := &file_shortener_proto_msgTypes[12]
+	mi := &file_shortener_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -603,7 +728,7 @@ func (x *DeleteUrlsAsyncResponse) String() string {
 func (*DeleteUrlsAsyncResponse) ProtoMessage() {}
 
 func (x *DeleteUrlsAsyncResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[12]
+	mi := &file_shortener_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -616,7 +741,7 @@ func (x *DeleteUrlsAsyncResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteUrlsAsyncResponse.ProtoReflect.Descriptor instead.
 func (*DeleteUrlsAsyncResponse) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{12}
+	return file_shortener_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *DeleteUrlsAsyncResponse) GetSuccess() bool {
@@ -634,7 +759,7 @@ type StatsRequest struct {
 
 func (x *StatsRequest) Reset() {
 	*x = StatsRequest{}
-	mi := &file_shortener_proto_msgTypes[13]
+	mi := &file_shortener_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -646,7 +771,7 @@ func (x *StatsRequest) String() string {
 func (*StatsRequest) ProtoMessage() {}
 
 func (x *StatsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[13]
+	mi := &file_shortener_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -659,20 +784,29 @@ func (x *StatsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
 func (*StatsRequest) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{13}
+	return file_shortener_proto_rawDescGZIP(), []int{14}
 }
 
 type StatsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	UrlsCount     int32                  `protobuf:"varint,1,opt,name=urls_count,json=urlsCount,proto3" json:"urls_count,omitempty"`
+	UrlsCount     int32                  `protobuf:"varint,1,opt,name=urls_count,json=urlsCount,proto3" json:"urls_count,omitempty"` // links that are not deleted
 	UsersCount    int32                  `protobuf:"varint,2,opt,name=users_count,json=usersCount,proto3" json:"users_count,omitempty"`
+	Visitors      int64                  `protobuf:"varint,3,opt,name=visitors,proto3" json:"visitors,omitempty"`                          // approximate distinct visitors of all links over the last 30 days
+	ActiveCount   int32                  `protobuf:"varint,4,opt,name=active_count,json=activeCount,proto3" json:"active_count,omitempty"` // links that are neither deleted nor expired
+	DeletedCount  int32                  `protobuf:"varint,5,opt,name=deleted_count,json=deletedCount,proto3" json:"deleted_count,omitempty"`
+	ExpiredCount  int32                  `protobuf:"varint,6,opt,name=expired_count,json=expiredCount,proto3" json:"expired_count,omitempty"`     // expired links not removed by the sweeper yet
+	Redirects     int64                  `protobuf:"varint,7,opt,name=redirects,proto3" json:"redirects,omitempty"`                               // recorded visits
+	CreatedPerDay []*DayCount            `protobuf:"bytes,8,rep,name=created_per_day,json=createdPerDay,proto3" json:"created_per_day,omitempty"` // days of the last 30 with new links
+	TopUsers      []*UserCount           `protobuf:"bytes,9,rep,name=top_users,json=topUsers,proto3" json:"top_users,omitempty"`                  // users with the most links that are not deleted
+	Backend       *BackendHealth         `protobuf:"bytes,10,opt,name=backend,proto3" json:"backend,omitempty"`
+	ClickQueue    *ClickQueue            `protobuf:"bytes,11,opt,name=click_queue,json=clickQueue,proto3" json:"click_queue,omitempty"` // unset when click tracking is off
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *StatsResponse) Reset() {
 	*x = StatsResponse{}
-	mi := &file_shortener_proto_msgTypes[14]
+	mi := &file_shortener_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -684,7 +818,7 @@ func (x *StatsResponse) String() string {
 func (*StatsResponse) ProtoMessage() {}
 
 func (x *StatsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[14]
+	mi := &file_shortener_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -697,7 +831,7 @@ func (x *StatsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatsResponse.ProtoReflect.Descriptor instead.
 func (*StatsResponse) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{14}
+	return file_shortener_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *StatsResponse) GetUrlsCount() int32 {
@@ -714,55 +848,1291 @@ func (x *StatsResponse) GetUsersCount() int32 {
 	return 0
 }
 
-var File_shortener_proto protoreflect.FileDescriptor
+func (x *StatsResponse) GetVisitors() int64 {
+	if x != nil {
+		return x.Visitors
+	}
+	return 0
+}
 
-const file_shortener_proto_rawDesc = "" +
-	"\n" +
-	"\x0fshortener.proto\x12\furlshortener\"\"\n" +
-	"\x0eShortenRequest\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url\")\n" +
-	"\x0fShortenResponse\x12\x16\n" +
-	"\x06result\x18\x01 \x01(\tR\x06result\"1\n" +
-	"\x12URLReturnerRequest\x12\x1b\n" +
-	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\"8\n" +
-	"\x13URLReturnerResponse\x12!\n" +
-	"\foriginal_url\x18\x01 \x01(\tR\voriginalUrl\"P\n" +
-	"\x17ShortenBatchListRequest\x125\n" +
-	"\x04urls\x18\x01 \x03(\v2!.urlshortener.ShortenBatchRequestR\x04urls\"_\n" +
-	"\x13ShortenBatchRequest\x12%\n" +
-	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12!\n" +
-	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\"R\n" +
-	"\x18ShortenBatchListResponse\x126\n" +
-	"\x04urls\x18\x01 \x03(\v2\".urlshortener.ShortenBatchResponseR\x04urls\"Z\n" +
-	"\x14ShortenBatchResponse\x12%\n" +
-	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12\x1b\n" +
-	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\"&\n" +
-	"\vUrlsRequest\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\tR\x06userId\"9\n" +
-	"\fUrlsResponse\x12)\n" +
-	"\x04urls\x18\x01 \x03(\v2\x15.urlshortener.UserURLR\x04urls\"I\n" +
-	"\aUserURL\x12\x1b\n" +
-	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\x12!\n" +
-	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\"P\n" +
-	"\x16DeleteUrlsAsyncRequest\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1d\n" +
-	"\n" +
-	"short_urls\x18\x02 \x03(\tR\tshortUrls\"3\n" +
-	"\x17DeleteUrlsAsyncResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\"\x0e\n" +
-	"\fStatsRequest\"O\n" +
-	"\rStatsResponse\x12\x1d\n" +
-	"\n" +
-	"urls_count\x18\x01 \x01(\x05R\turlsCount\x12\x1f\n" +
-	"\vusers_count\x18\x02 \x01(\x05R\n" +
-	"usersCount2\xf6\x03\n" +
-	"\fURLShortener\x12T\n" +
-	"\vURLReturner\x12 .urlshortener.URLReturnerRequest\x1a!.urlshortener.URLReturnerResponse\"\x00\x12H\n" +
-	"\aShorten\x12\x1c.urlshortener.ShortenRequest\x1a\x1d.urlshortener.ShortenResponse\"\x00\x12_\n" +
-	"\fShortenBatch\x12%.urlshortener.ShortenBatchListRequest\x1a&.urlshortener.ShortenBatchListResponse\"\x00\x12?\n" +
-	"\x04Urls\x12\x19.urlshortener.UrlsRequest\x1a\x1a.urlshortener.UrlsResponse\"\x00\x12`\n" +
-	"\x0fDeleteUrlsAsync\x12$.urlshortener.DeleteUrlsAsyncRequest\x1a%.urlshortener.DeleteUrlsAsyncResponse\"\x00\x12B\n" +
-	"\x05Stats\x12\x1a.urlshortener.StatsRequest\x1a\x1b.urlshortener.StatsResponse\"\x00B/Z-github.com/jayjaytrn/URLShortener/proto;protob\x06proto3"
+func (x *StatsResponse) GetActiveCount() int32 {
+	if x != nil {
+		return x.ActiveCount
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetDeletedCount() int32 {
+	if x != nil {
+		return x.DeletedCount
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetExpiredCount() int32 {
+	if x != nil {
+		return x.ExpiredCount
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetRedirects() int64 {
+	if x != nil {
+		return x.Redirects
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetCreatedPerDay() []*DayCount {
+	if x != nil {
+		return x.CreatedPerDay
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetTopUsers() []*UserCount {
+	if x != nil {
+		return x.TopUsers
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetBackend() *BackendHealth {
+	if x != nil {
+		return x.Backend
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetClickQueue() *ClickQueue {
+	if x != nil {
+		return x.ClickQueue
+	}
+	return nil
+}
+
+type DayCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Day           string                 `protobuf:"bytes,1,opt,name=day,proto3" json:"day,omitempty"` // YYYY-MM-DD, UTC
+	Links         int32                  `protobuf:"varint,2,opt,name=links,proto3" json:"links,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DayCount) Reset() {
+	*x = DayCount{}
+	mi := &file_shortener_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DayCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DayCount) ProtoMessage() {}
+
+func (x *DayCount) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DayCount.ProtoReflect.Descriptor instead.
+func (*DayCount) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DayCount) GetDay() string {
+	if x != nil {
+		return x.Day
+	}
+	return ""
+}
+
+func (x *DayCount) GetLinks() int32 {
+	if x != nil {
+		return x.Links
+	}
+	return 0
+}
+
+type UserCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Links         int32                  `protobuf:"varint,2,opt,name=links,proto3" json:"links,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserCount) Reset() {
+	*x = UserCount{}
+	mi := &file_shortener_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserCount) ProtoMessage() {}
+
+func (x *UserCount) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserCount.ProtoReflect.Descriptor instead.
+func (*UserCount) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *UserCount) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UserCount) GetLinks() int32 {
+	if x != nil {
+		return x.Links
+	}
+	return 0
+}
+
+type BackendHealth struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Healthy       bool                   `protobuf:"varint,2,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	LatencyMs     float64                `protobuf:"fixed64,3,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BackendHealth) Reset() {
+	*x = BackendHealth{}
+	mi := &file_shortener_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BackendHealth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BackendHealth) ProtoMessage() {}
+
+func (x *BackendHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BackendHealth.ProtoReflect.Descriptor instead.
+func (*BackendHealth) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *BackendHealth) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *BackendHealth) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *BackendHealth) GetLatencyMs() float64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *BackendHealth) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type ClickQueue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Queued        int32                  `protobuf:"varint,1,opt,name=queued,proto3" json:"queued,omitempty"`
+	Written       int64                  `protobuf:"varint,2,opt,name=written,proto3" json:"written,omitempty"`
+	Dropped       int64                  `protobuf:"varint,3,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	Failed        int64                  `protobuf:"varint,4,opt,name=failed,proto3" json:"failed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClickQueue) Reset() {
+	*x = ClickQueue{}
+	mi := &file_shortener_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClickQueue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClickQueue) ProtoMessage() {}
+
+func (x *ClickQueue) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClickQueue.ProtoReflect.Descriptor instead.
+func (*ClickQueue) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ClickQueue) GetQueued() int32 {
+	if x != nil {
+		return x.Queued
+	}
+	return 0
+}
+
+func (x *ClickQueue) GetWritten() int64 {
+	if x != nil {
+		return x.Written
+	}
+	return 0
+}
+
+func (x *ClickQueue) GetDropped() int64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+func (x *ClickQueue) GetFailed() int64 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+type QRCodeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortUrl      string                 `protobuf:"bytes,1,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	Format        string                 `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`         // png or svg; png when empty
+	Size          int32                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`            // width and height in pixels; the default when 0
+	Ecc           string                 `protobuf:"bytes,4,opt,name=ecc,proto3" json:"ecc,omitempty"`               // error correction level: L, M, Q or H; M when empty
+	Margin        *int32                 `protobuf:"varint,5,opt,name=margin,proto3,oneof" json:"margin,omitempty"`  // quiet zone in modules; the default when unset
+	Foreground    string                 `protobuf:"bytes,6,opt,name=foreground,proto3" json:"foreground,omitempty"` // hex RGB colour of the dark modules
+	Background    string                 `protobuf:"bytes,7,opt,name=background,proto3" json:"background,omitempty"` // hex RGB colour of the light modules
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QRCodeRequest) Reset() {
+	*x = QRCodeRequest{}
+	mi := &file_shortener_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QRCodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QRCodeRequest) ProtoMessage() {}
+
+func (x *QRCodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QRCodeRequest.ProtoReflect.Descriptor instead.
+func (*QRCodeRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *QRCodeRequest) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *QRCodeRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *QRCodeRequest) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *QRCodeRequest) GetEcc() string {
+	if x != nil {
+		return x.Ecc
+	}
+	return ""
+}
+
+func (x *QRCodeRequest) GetMargin() int32 {
+	if x != nil && x.Margin != nil {
+		return *x.Margin
+	}
+	return 0
+}
+
+func (x *QRCodeRequest) GetForeground() string {
+	if x != nil {
+		return x.Foreground
+	}
+	return ""
+}
+
+func (x *QRCodeRequest) GetBackground() string {
+	if x != nil {
+		return x.Background
+	}
+	return ""
+}
+
+type QRCodeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Image         []byte                 `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	ContentType   string                 `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QRCodeResponse) Reset() {
+	*x = QRCodeResponse{}
+	mi := &file_shortener_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QRCodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QRCodeResponse) ProtoMessage() {}
+
+func (x *QRCodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QRCodeResponse.ProtoReflect.Descriptor instead.
+func (*QRCodeResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *QRCodeResponse) GetImage() []byte {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *QRCodeResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+// Changes a short URL of the user; unset fields keep their current values.
+// The owner is taken from the user token in the "authorization" metadata.
+type UpdateURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // ignored
+	ShortUrl      string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	Url           *string                `protobuf:"bytes,3,opt,name=url,proto3,oneof" json:"url,omitempty"`                                        // new destination
+	RedirectType  *int32                 `protobuf:"varint,4,opt,name=redirect_type,json=redirectType,proto3,oneof" json:"redirect_type,omitempty"` // redirect status: 301, 302, 307 or 308; 0 for the server default
+	CacheTtl      *int64                 `protobuf:"varint,5,opt,name=cache_ttl,json=cacheTtl,proto3,oneof" json:"cache_ttl,omitempty"`             // redirect cache lifetime in seconds, 0 for no-store; negative for the server default
+	Interstitial  *bool                  `protobuf:"varint,6,opt,name=interstitial,proto3,oneof" json:"interstitial,omitempty"`                     // show the preview page instead of redirecting
+	NotBefore     *string                `protobuf:"bytes,7,opt,name=not_before,json=notBefore,proto3,oneof" json:"not_before,omitempty"`           // start of the activation window, RFC 3339; empty to remove
+	NotAfter      *string                `protobuf:"bytes,8,opt,name=not_after,json=notAfter,proto3,oneof" json:"not_after,omitempty"`              // end of the activation window, RFC 3339; empty to remove
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateURLRequest) Reset() {
+	*x = UpdateURLRequest{}
+	mi := &file_shortener_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateURLRequest) ProtoMessage() {}
+
+func (x *UpdateURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateURLRequest.ProtoReflect.Descriptor instead.
+func (*UpdateURLRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *UpdateURLRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetUrl() string {
+	if x != nil && x.Url != nil {
+		return *x.Url
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetRedirectType() int32 {
+	if x != nil && x.RedirectType != nil {
+		return *x.RedirectType
+	}
+	return 0
+}
+
+func (x *UpdateURLRequest) GetCacheTtl() int64 {
+	if x != nil && x.CacheTtl != nil {
+		return *x.CacheTtl
+	}
+	return 0
+}
+
+func (x *UpdateURLRequest) GetInterstitial() bool {
+	if x != nil && x.Interstitial != nil {
+		return *x.Interstitial
+	}
+	return false
+}
+
+func (x *UpdateURLRequest) GetNotBefore() string {
+	if x != nil && x.NotBefore != nil {
+		return *x.NotBefore
+	}
+	return ""
+}
+
+func (x *UpdateURLRequest) GetNotAfter() string {
+	if x != nil && x.NotAfter != nil {
+		return *x.NotAfter
+	}
+	return ""
+}
+
+type UpdateURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShortUrl      string                 `protobuf:"bytes,1,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	OriginalUrl   string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateURLResponse) Reset() {
+	*x = UpdateURLResponse{}
+	mi := &file_shortener_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateURLResponse) ProtoMessage() {}
+
+func (x *UpdateURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateURLResponse.ProtoReflect.Descriptor instead.
+func (*UpdateURLResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *UpdateURLResponse) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *UpdateURLResponse) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+// The owner is taken from the user token in the "authorization" metadata.
+type URLHistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // ignored
+	ShortUrl      string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *URLHistoryRequest) Reset() {
+	*x = URLHistoryRequest{}
+	mi := &file_shortener_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URLHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URLHistoryRequest) ProtoMessage() {}
+
+func (x *URLHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URLHistoryRequest.ProtoReflect.Descriptor instead.
+func (*URLHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *URLHistoryRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *URLHistoryRequest) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+type URLHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Revisions     []*Revision            `protobuf:"bytes,1,rep,name=revisions,proto3" json:"revisions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *URLHistoryResponse) Reset() {
+	*x = URLHistoryResponse{}
+	mi := &file_shortener_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URLHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URLHistoryResponse) ProtoMessage() {}
+
+func (x *URLHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URLHistoryResponse.ProtoReflect.Descriptor instead.
+func (*URLHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *URLHistoryResponse) GetRevisions() []*Revision {
+	if x != nil {
+		return x.Revisions
+	}
+	return nil
+}
+
+type Revision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	OriginalUrl   string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	ChangedAt     string                 `protobuf:"bytes,3,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"` // RFC 3339
+	ChangedBy     string                 `protobuf:"bytes,4,opt,name=changed_by,json=changedBy,proto3" json:"changed_by,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Revision) Reset() {
+	*x = Revision{}
+	mi := &file_shortener_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Revision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Revision) ProtoMessage() {}
+
+func (x *Revision) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Revision.ProtoReflect.Descriptor instead.
+func (*Revision) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *Revision) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Revision) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *Revision) GetChangedAt() string {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return ""
+}
+
+func (x *Revision) GetChangedBy() string {
+	if x != nil {
+		return x.ChangedBy
+	}
+	return ""
+}
+
+// The owner is taken from the user token in the "authorization" metadata.
+type RollbackURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // ignored
+	ShortUrl      string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	Version       int32                  `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RollbackURLRequest) Reset() {
+	*x = RollbackURLRequest{}
+	mi := &file_shortener_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RollbackURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollbackURLRequest) ProtoMessage() {}
+
+func (x *RollbackURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RollbackURLRequest.ProtoReflect.Descriptor instead.
+func (*RollbackURLRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *RollbackURLRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *RollbackURLRequest) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *RollbackURLRequest) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+// The owner is taken from the user token in the "authorization" metadata.
+type URLStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // ignored
+	ShortUrl      string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	From          string                 `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`                                   // start of the range, RFC 3339; 30 days before to when empty
+	To            string                 `protobuf:"bytes,4,opt,name=to,proto3" json:"to,omitempty"`                                       // end of the range, RFC 3339; now when empty
+	Interval      string                 `protobuf:"bytes,5,opt,name=interval,proto3" json:"interval,omitempty"`                           // bucket size of the series: hour, day or week; day when empty
+	IncludeBots   bool                   `protobuf:"varint,6,opt,name=include_bots,json=includeBots,proto3" json:"include_bots,omitempty"` // count the visits of crawlers and link unfurlers
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *URLStatsRequest) Reset() {
+	*x = URLStatsRequest{}
+	mi := &file_shortener_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URLStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URLStatsRequest) ProtoMessage() {}
+
+func (x *URLStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URLStatsRequest.ProtoReflect.Descriptor instead.
+func (*URLStatsRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *URLStatsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *URLStatsRequest) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *URLStatsRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *URLStatsRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *URLStatsRequest) GetInterval() string {
+	if x != nil {
+		return x.Interval
+	}
+	return ""
+}
+
+func (x *URLStatsRequest) GetIncludeBots() bool {
+	if x != nil {
+		return x.IncludeBots
+	}
+	return false
+}
+
+type URLStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TotalClicks   int64                  `protobuf:"varint,1,opt,name=total_clicks,json=totalClicks,proto3" json:"total_clicks,omitempty"`
+	UniqueClicks  int64                  `protobuf:"varint,2,opt,name=unique_clicks,json=uniqueClicks,proto3" json:"unique_clicks,omitempty"` // approximate distinct visitors of the days overlapping the range
+	Series        []*StatsBucket         `protobuf:"bytes,3,rep,name=series,proto3" json:"series,omitempty"`
+	TopReferrers  []*StatsCount          `protobuf:"bytes,4,rep,name=top_referrers,json=topReferrers,proto3" json:"top_referrers,omitempty"`
+	TopUserAgents []*StatsCount          `protobuf:"bytes,5,rep,name=top_user_agents,json=topUserAgents,proto3" json:"top_user_agents,omitempty"`
+	TopCountries  []*StatsCount          `protobuf:"bytes,6,rep,name=top_countries,json=topCountries,proto3" json:"top_countries,omitempty"`
+	BotClicks     int64                  `protobuf:"varint,7,opt,name=bot_clicks,json=botClicks,proto3" json:"bot_clicks,omitempty"` // visits of bots in the range, counted in the other fields only with include_bots
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *URLStatsResponse) Reset() {
+	*x = URLStatsResponse{}
+	mi := &file_shortener_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URLStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URLStatsResponse) ProtoMessage() {}
+
+func (x *URLStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URLStatsResponse.ProtoReflect.Descriptor instead.
+func (*URLStatsResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *URLStatsResponse) GetTotalClicks() int64 {
+	if x != nil {
+		return x.TotalClicks
+	}
+	return 0
+}
+
+func (x *URLStatsResponse) GetUniqueClicks() int64 {
+	if x != nil {
+		return x.UniqueClicks
+	}
+	return 0
+}
+
+func (x *URLStatsResponse) GetSeries() []*StatsBucket {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+func (x *URLStatsResponse) GetTopReferrers() []*StatsCount {
+	if x != nil {
+		return x.TopReferrers
+	}
+	return nil
+}
+
+func (x *URLStatsResponse) GetTopUserAgents() []*StatsCount {
+	if x != nil {
+		return x.TopUserAgents
+	}
+	return nil
+}
+
+func (x *URLStatsResponse) GetTopCountries() []*StatsCount {
+	if x != nil {
+		return x.TopCountries
+	}
+	return nil
+}
+
+func (x *URLStatsResponse) GetBotClicks() int64 {
+	if x != nil {
+		return x.BotClicks
+	}
+	return 0
+}
+
+type StatsBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         string                 `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"` // RFC 3339
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsBucket) Reset() {
+	*x = StatsBucket{}
+	mi := &file_shortener_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsBucket) ProtoMessage() {}
+
+func (x *StatsBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsBucket.ProtoReflect.Descriptor instead.
+func (*StatsBucket) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *StatsBucket) GetStart() string {
+	if x != nil {
+		return x.Start
+	}
+	return ""
+}
+
+func (x *StatsBucket) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+type StatsCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsCount) Reset() {
+	*x = StatsCount{}
+	mi := &file_shortener_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsCount) ProtoMessage() {}
+
+func (x *StatsCount) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsCount.ProtoReflect.Descriptor instead.
+func (*StatsCount) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *StatsCount) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *StatsCount) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+var File_shortener_proto protoreflect.FileDescriptor
+
+const file_shortener_proto_rawDesc = "" +
+	"\n" +
+	"\x0fshortener.proto\x12\furlshortener\"Y\n" +
+	"\x0eShortenRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x125\n" +
+	"\aoptions\x18\x02 \x01(\v2\x1b.urlshortener.CreateOptionsR\aoptions\"\x8c\x02\n" +
+	"\rCreateOptions\x12\x10\n" +
+	"\x03ttl\x18\x01 \x01(\x03R\x03ttl\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x02 \x01(\tR\texpiresAt\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\x03 \x01(\x03R\tmaxClicks\x12\x1a\n" +
+	"\bpassword\x18\x04 \x01(\tR\bpassword\x12#\n" +
+	"\rredirect_type\x18\x05 \x01(\x05R\fredirectType\x12 \n" +
+	"\tcache_ttl\x18\x06 \x01(\x03H\x00R\bcacheTtl\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"not_before\x18\a \x01(\tR\tnotBefore\x12\x1b\n" +
+	"\tnot_after\x18\b \x01(\tR\bnotAfterB\f\n" +
+	"\n" +
+	"_cache_ttl\")\n" +
+	"\x0fShortenResponse\x12\x16\n" +
+	"\x06result\x18\x01 \x01(\tR\x06result\"M\n" +
+	"\x12URLReturnerRequest\x12\x1b\n" +
+	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"8\n" +
+	"\x13URLReturnerResponse\x12!\n" +
+	"\foriginal_url\x18\x01 \x01(\tR\voriginalUrl\"P\n" +
+	"\x17ShortenBatchListRequest\x125\n" +
+	"\x04urls\x18\x01 \x03(\v2!.urlshortener.ShortenBatchRequestR\x04urls\"\x96\x01\n" +
+	"\x13ShortenBatchRequest\x12%\n" +
+	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x125\n" +
+	"\aoptions\x18\x03 \x01(\v2\x1b.urlshortener.CreateOptionsR\aoptions\"R\n" +
+	"\x18ShortenBatchListResponse\x126\n" +
+	"\x04urls\x18\x01 \x03(\v2\".urlshortener.ShortenBatchResponseR\x04urls\"Z\n" +
+	"\x14ShortenBatchResponse\x12%\n" +
+	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\"&\n" +
+	"\vUrlsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\"9\n" +
+	"\fUrlsResponse\x12)\n" +
+	"\x04urls\x18\x01 \x03(\v2\x15.urlshortener.UserURLR\x04urls\"I\n" +
+	"\aUserURL\x12\x1b\n" +
+	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\"P\n" +
+	"\x16DeleteUrlsAsyncRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"short_urls\x18\x02 \x03(\tR\tshortUrls\"3\n" +
+	"\x17DeleteUrlsAsyncResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"\x0e\n" +
+	"\fStatsRequest\"\xde\x03\n" +
+	"\rStatsResponse\x12\x1d\n" +
+	"\n" +
+	"urls_count\x18\x01 \x01(\x05R\turlsCount\x12\x1f\n" +
+	"\vusers_count\x18\x02 \x01(\x05R\n" +
+	"usersCount\x12\x1a\n" +
+	"\bvisitors\x18\x03 \x01(\x03R\bvisitors\x12!\n" +
+	"\factive_count\x18\x04 \x01(\x05R\vactiveCount\x12#\n" +
+	"\rdeleted_count\x18\x05 \x01(\x05R\fdeletedCount\x12#\n" +
+	"\rexpired_count\x18\x06 \x01(\x05R\fexpiredCount\x12\x1c\n" +
+	"\tredirects\x18\a \x01(\x03R\tredirects\x12>\n" +
+	"\x0fcreated_per_day\x18\b \x03(\v2\x16.urlshortener.DayCountR\rcreatedPerDay\x124\n" +
+	"\ttop_users\x18\t \x03(\v2\x17.urlshortener.UserCountR\btopUsers\x125\n" +
+	"\abackend\x18\n" +
+	" \x01(\v2\x1b.urlshortener.BackendHealthR\abackend\x129\n" +
+	"\vclick_queue\x18\v \x01(\v2\x18.urlshortener.ClickQueueR\n" +
+	"clickQueue\"2\n" +
+	"\bDayCount\x12\x10\n" +
+	"\x03day\x18\x01 \x01(\tR\x03day\x12\x14\n" +
+	"\x05links\x18\x02 \x01(\x05R\x05links\":\n" +
+	"\tUserCount\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x14\n" +
+	"\x05links\x18\x02 \x01(\x05R\x05links\"r\n" +
+	"\rBackendHealth\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x18\n" +
+	"\ahealthy\x18\x02 \x01(\bR\ahealthy\x12\x1d\n" +
+	"\n" +
+	"latency_ms\x18\x03 \x01(\x01R\tlatencyMs\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"p\n" +
+	"\n" +
+	"ClickQueue\x12\x16\n" +
+	"\x06queued\x18\x01 \x01(\x05R\x06queued\x12\x18\n" +
+	"\awritten\x18\x02 \x01(\x03R\awritten\x12\x18\n" +
+	"\adropped\x18\x03 \x01(\x03R\adropped\x12\x16\n" +
+	"\x06failed\x18\x04 \x01(\x03R\x06failed\"\xd2\x01\n" +
+	"\rQRCodeRequest\x12\x1b\n" +
+	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x05R\x04size\x12\x10\n" +
+	"\x03ecc\x18\x04 \x01(\tR\x03ecc\x12\x1b\n" +
+	"\x06margin\x18\x05 \x01(\x05H\x00R\x06margin\x88\x01\x01\x12\x1e\n" +
+	"\n" +
+	"foreground\x18\x06 \x01(\tR\n" +
+	"foreground\x12\x1e\n" +
+	"\n" +
+	"background\x18\a \x01(\tR\n" +
+	"backgroundB\t\n" +
+	"\a_margin\"I\n" +
+	"\x0eQRCodeResponse\x12\x14\n" +
+	"\x05image\x18\x01 \x01(\fR\x05image\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\"\xf0\x02\n" +
+	"\x10UpdateURLRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\x12\x15\n" +
+	"\x03url\x18\x03 \x01(\tH\x00R\x03url\x88\x01\x01\x12(\n" +
+	"\rredirect_type\x18\x04 \x01(\x05H\x01R\fredirectType\x88\x01\x01\x12 \n" +
+	"\tcache_ttl\x18\x05 \x01(\x03H\x02R\bcacheTtl\x88\x01\x01\x12'\n" +
+	"\finterstitial\x18\x06 \x01(\bH\x03R\finterstitial\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"not_before\x18\a \x01(\tH\x04R\tnotBefore\x88\x01\x01\x12 \n" +
+	"\tnot_after\x18\b \x01(\tH\x05R\bnotAfter\x88\x01\x01B\x06\n" +
+	"\x04_urlB\x10\n" +
+	"\x0e_redirect_typeB\f\n" +
+	"\n" +
+	"_cache_ttlB\x0f\n" +
+	"\r_interstitialB\r\n" +
+	"\v_not_beforeB\f\n" +
+	"\n" +
+	"_not_after\"S\n" +
+	"\x11UpdateURLResponse\x12\x1b\n" +
+	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\"I\n" +
+	"\x11URLHistoryRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\"J\n" +
+	"\x12URLHistoryResponse\x124\n" +
+	"\trevisions\x18\x01 \x03(\v2\x16.urlshortener.RevisionR\trevisions\"\x85\x01\n" +
+	"\bRevision\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x12\x1d\n" +
+	"\n" +
+	"changed_at\x18\x03 \x01(\tR\tchangedAt\x12\x1d\n" +
+	"\n" +
+	"changed_by\x18\x04 \x01(\tR\tchangedBy\"d\n" +
+	"\x12RollbackURLRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x05R\aversion\"\xaa\x01\n" +
+	"\x0fURLStatsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\x12\x12\n" +
+	"\x04from\x18\x03 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x04 \x01(\tR\x02to\x12\x1a\n" +
+	"\binterval\x18\x05 \x01(\tR\binterval\x12!\n" +
+	"\finclude_bots\x18\x06 \x01(\bR\vincludeBots\"\xec\x02\n" +
+	"\x10URLStatsResponse\x12!\n" +
+	"\ftotal_clicks\x18\x01 \x01(\x03R\vtotalClicks\x12#\n" +
+	"\runique_clicks\x18\x02 \x01(\x03R\funiqueClicks\x121\n" +
+	"\x06series\x18\x03 \x03(\v2\x19.urlshortener.StatsBucketR\x06series\x12=\n" +
+	"\rtop_referrers\x18\x04 \x03(\v2\x18.urlshortener.StatsCountR\ftopReferrers\x12@\n" +
+	"\x0ftop_user_agents\x18\x05 \x03(\v2\x18.urlshortener.StatsCountR\rtopUserAgents\x12=\n" +
+	"\rtop_countries\x18\x06 \x03(\v2\x18.urlshortener.StatsCountR\ftopCountries\x12\x1d\n" +
+	"\n" +
+	"bot_clicks\x18\a \x01(\x03R\tbotClicks\";\n" +
+	"\vStatsBucket\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\tR\x05start\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\":\n" +
+	"\n" +
+	"StatsCount\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks2\x81\a\n" +
+	"\fURLShortener\x12T\n" +
+	"\vURLReturner\x12 .urlshortener.URLReturnerRequest\x1a!.urlshortener.URLReturnerResponse\"\x00\x12H\n" +
+	"\aShorten\x12\x1c.urlshortener.ShortenRequest\x1a\x1d.urlshortener.ShortenResponse\"\x00\x12_\n" +
+	"\fShortenBatch\x12%.urlshortener.ShortenBatchListRequest\x1a&.urlshortener.ShortenBatchListResponse\"\x00\x12?\n" +
+	"\x04Urls\x12\x19.urlshortener.UrlsRequest\x1a\x1a.urlshortener.UrlsResponse\"\x00\x12`\n" +
+	"\x0fDeleteUrlsAsync\x12$.urlshortener.DeleteUrlsAsyncRequest\x1a%.urlshortener.DeleteUrlsAsyncResponse\"\x00\x12B\n" +
+	"\x05Stats\x12\x1a.urlshortener.StatsRequest\x1a\x1b.urlshortener.StatsResponse\"\x00\x12E\n" +
+	"\x06QRCode\x12\x1b.urlshortener.QRCodeRequest\x1a\x1c.urlshortener.QRCodeResponse\"\x00\x12N\n" +
+	"\tUpdateURL\x12\x1e.urlshortener.UpdateURLRequest\x1a\x1f.urlshortener.UpdateURLResponse\"\x00\x12Q\n" +
+	"\n" +
+	"URLHistory\x12\x1f.urlshortener.URLHistoryRequest\x1a .urlshortener.URLHistoryResponse\"\x00\x12R\n" +
+	"\vRollbackURL\x12 .urlshortener.RollbackURLRequest\x1a\x1f.urlshortener.UpdateURLResponse\"\x00\x12K\n" +
+	"\bURLStats\x12\x1d.urlshortener.URLStatsRequest\x1a\x1e.urlshortener.URLStatsResponse\"\x00B/Z-github.com/jayjaytrn/URLShortener/proto;protob\x06proto3"
 
 var (
 	file_shortener_proto_rawDescOnce sync.Once
@@ -776,45 +2146,83 @@ func file_shortener_proto_rawDescGZIP() []byte {
 	return file_shortener_proto_rawDescData
 }
 
-var file_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 32)
 var file_shortener_proto_goTypes = []any{
 	(*ShortenRequest)(nil),           // 0: urlshortener.ShortenRequest
-	(*ShortenResponse)(nil),          // 1: urlshortener.ShortenResponse
-	(*URLReturnerRequest)(nil),       // 2: urlshortener.URLReturnerRequest
-	(*URLReturnerResponse)(nil),      // 3: urlshortener.URLReturnerResponse
-	(*ShortenBatchListRequest)(nil),  // 4: urlshortener.ShortenBatchListRequest
-	(*ShortenBatchRequest)(nil),      // 5: urlshortener.ShortenBatchRequest
-	(*ShortenBatchListResponse)(nil), // 6: urlshortener.ShortenBatchListResponse
-	(*ShortenBatchResponse)(nil),     // 7: urlshortener.ShortenBatchResponse
-	(*UrlsRequest)(nil),              // 8: urlshortener.UrlsRequest
-	(*UrlsResponse)(nil),             // 9: urlshortener.UrlsResponse
-	(*UserURL)(nil),                  // 10: urlshortener.UserURL
-	(*DeleteUrlsAsyncRequest)(nil),   // 11: urlshortener.DeleteUrlsAsyncRequest
-	(*DeleteUrlsAsyncResponse)(nil),  // 12: urlshortener.DeleteUrlsAsyncResponse
-	(*StatsRequest)(nil),             // 13: urlshortener.StatsRequest
-	(*StatsResponse)(nil),            // 14: urlshortener.StatsResponse
+	(*CreateOptions)(nil),            // 1: urlshortener.CreateOptions
+	(*ShortenResponse)(nil),          // 2: urlshortener.ShortenResponse
+	(*URLReturnerRequest)(nil),       // 3: urlshortener.URLReturnerRequest
+	(*URLReturnerResponse)(nil),      // 4: urlshortener.URLReturnerResponse
+	(*ShortenBatchListRequest)(nil),  // 5: urlshortener.ShortenBatchListRequest
+	(*ShortenBatchRequest)(nil),      // 6: urlshortener.ShortenBatchRequest
+	(*ShortenBatchListResponse)(nil), // 7: urlshortener.ShortenBatchListResponse
+	(*ShortenBatchResponse)(nil),     // 8: urlshortener.ShortenBatchResponse
+	(*UrlsRequest)(nil),              // 9: urlshortener.UrlsRequest
+	(*UrlsResponse)(nil),             // 10: urlshortener.UrlsResponse
+	(*UserURL)(nil),                  // 11: urlshortener.UserURL
+	(*DeleteUrlsAsyncRequest)(nil),   // 12: urlshortener.DeleteUrlsAsyncRequest
+	(*DeleteUrlsAsyncResponse)(nil),  // 13: urlshortener.DeleteUrlsAsyncResponse
+	(*StatsRequest)(nil),             // 14: urlshortener.StatsRequest
+	(*StatsResponse)(nil),            // 15: urlshortener.StatsResponse
+	(*DayCount)(nil),                 // 16: urlshortener.DayCount
+	(*UserCount)(nil),                // 17: urlshortener.UserCount
+	(*BackendHealth)(nil),            // 18: urlshortener.BackendHealth
+	(*ClickQueue)(nil),               // 19: urlshortener.ClickQueue
+	(*QRCodeRequest)(nil),            // 20: urlshortener.QRCodeRequest
+	(*QRCodeResponse)(nil),           // 21: urlshortener.QRCodeResponse
+	(*UpdateURLRequest)(nil),         // 22: urlshortener.UpdateURLRequest
+	(*UpdateURLResponse)(nil),        // 23: urlshortener.UpdateURLResponse
+	(*URLHistoryRequest)(nil),        // 24: urlshortener.URLHistoryRequest
+	(*URLHistoryResponse)(nil),       // 25: urlshortener.URLHistoryResponse
+	(*Revision)(nil),                 // 26: urlshortener.Revision
+	(*RollbackURLRequest)(nil),       // 27: urlshortener.RollbackURLRequest
+	(*URLStatsRequest)(nil),          // 28: urlshortener.URLStatsRequest
+	(*URLStatsResponse)(nil),         // 29: urlshortener.URLStatsResponse
+	(*StatsBucket)(nil),              // 30: urlshortener.StatsBucket
+	(*StatsCount)(nil),               // 31: urlshortener.StatsCount
 }
 var file_shortener_proto_depIdxs = []int32{
-	5,  // 0: urlshortener.ShortenBatchListRequest.urls:type_name -> urlshortener.ShortenBatchRequest
-	7,  // 1: urlshortener.ShortenBatchListResponse.urls:type_name -> urlshortener.ShortenBatchResponse
-	10, // 2: urlshortener.UrlsResponse.urls:type_name -> urlshortener.UserURL
-	2,  // 3: urlshortener.URLShortener.URLReturner:input_type -> urlshortener.URLReturnerRequest
-	0,  // 4: urlshortener.URLShortener.Shorten:input_type -> urlshortener.ShortenRequest
-	4,  // 5: urlshortener.URLShortener.ShortenBatch:input_type -> urlshortener.ShortenBatchListRequest
-	8,  // 6: urlshortener.URLShortener.Urls:input_type -> urlshortener.UrlsRequest
-	11, // 7: urlshortener.URLShortener.DeleteUrlsAsync:input_type -> urlshortener.DeleteUrlsAsyncRequest
-	13, // 8: urlshortener.URLShortener.Stats:input_type -> urlshortener.StatsRequest
-	3,  // 9: urlshortener.URLShortener.URLReturner:output_type -> urlshortener.URLReturnerResponse
-	1,  // 10: urlshortener.URLShortener.Shorten:output_type -> urlshortener.ShortenResponse
-	6,  // 11: urlshortener.URLShortener.ShortenBatch:output_type -> urlshortener.ShortenBatchListResponse
-	9,  // 12: urlshortener.URLShortener.Urls:output_type -> urlshortener.UrlsResponse
-	12, // 13: urlshortener.URLShortener.DeleteUrlsAsync:output_type -> urlshortener.DeleteUrlsAsyncResponse
-	14, // 14: urlshortener.URLShortener.Stats:output_type -> urlshortener.StatsResponse
-	9,  // [9:15] is the sub-list for method output_type
-	3,  // [3:9] is the sub-list for method input_type
-	3,  // [3:3] is the sub-list for extension type_name
-	3,  // [3:3] is the sub-list for extension extendee
-	0,  // [0:3] is the sub-list for field type_name
+	1,  // 0: urlshortener.ShortenRequest.options:type_name -> urlshortener.CreateOptions
+	6,  // 1: urlshortener.ShortenBatchListRequest.urls:type_name -> urlshortener.ShortenBatchRequest
+	1,  // 2: urlshortener.ShortenBatchRequest.options:type_name -> urlshortener.CreateOptions
+	8,  // 3: urlshortener.ShortenBatchListResponse.urls:type_name -> urlshortener.ShortenBatchResponse
+	11, // 4: urlshortener.UrlsResponse.urls:type_name -> urlshortener.UserURL
+	16, // 5: urlshortener.StatsResponse.created_per_day:type_name -> urlshortener.DayCount
+	17, // 6: urlshortener.StatsResponse.top_users:type_name -> urlshortener.UserCount
+	18, // 7: urlshortener.StatsResponse.backend:type_name -> urlshortener.BackendHealth
+	19, // 8: urlshortener.StatsResponse.click_queue:type_name -> urlshortener.ClickQueue
+	26, // 9: urlshortener.URLHistoryResponse.revisions:type_name -> urlshortener.Revision
+	30, // 10: urlshortener.URLStatsResponse.series:type_name -> urlshortener.StatsBucket
+	31, // 11: urlshortener.URLStatsResponse.top_referrers:type_name -> urlshortener.StatsCount
+	31, // 12: urlshortener.URLStatsResponse.top_user_agents:type_name -> urlshortener.StatsCount
+	31, // 13: urlshortener.URLStatsResponse.top_countries:type_name -> urlshortener.StatsCount
+	3,  // 14: urlshortener.URLShortener.URLReturner:input_type -> urlshortener.URLReturnerRequest
+	0,  // 15: urlshortener.URLShortener.Shorten:input_type -> urlshortener.ShortenRequest
+	5,  // 16: urlshortener.URLShortener.ShortenBatch:input_type -> urlshortener.ShortenBatchListRequest
+	9,  // 17: urlshortener.URLShortener.Urls:input_type -> urlshortener.UrlsRequest
+	12, // 18: urlshortener.URLShortener.DeleteUrlsAsync:input_type -> urlshortener.DeleteUrlsAsyncRequest
+	14, // 19: urlshortener.URLShortener.Stats:input_type -> urlshortener.StatsRequest
+	20, // 20: urlshortener.URLShortener.QRCode:input_type -> urlshortener.QRCodeRequest
+	22, // 21: urlshortener.URLShortener.UpdateURL:input_type -> urlshortener.UpdateURLRequest
+	24, // 22: urlshortener.URLShortener.URLHistory:input_type -> urlshortener.URLHistoryRequest
+	27, // 23: urlshortener.URLShortener.RollbackURL:input_type -> urlshortener.RollbackURLRequest
+	28, // 24: urlshortener.URLShortener.URLStats:input_type -> urlshortener.URLStatsRequest
+	4,  // 25: urlshortener.URLShortener.URLReturner:output_type -> urlshortener.URLReturnerResponse
+	2,  // 26: urlshortener.URLShortener.Shorten:output_type -> urlshortener.ShortenResponse
+	7,  // 27: urlshortener.URLShortener.ShortenBatch:output_type -> urlshortener.ShortenBatchListResponse
+	10, // 28: urlshortener.URLShortener.Urls:output_type -> urlshortener.UrlsResponse
+	13, // 29: urlshortener.URLShortener.DeleteUrlsAsync:output_type -> urlshortener.DeleteUrlsAsyncResponse
+	15, // 30: urlshortener.URLShortener.Stats:output_type -> urlshortener.StatsResponse
+	21, // 31: urlshortener.URLShortener.QRCode:output_type -> urlshortener.QRCodeResponse
+	23, // 32: urlshortener.URLShortener.UpdateURL:output_type -> urlshortener.UpdateURLResponse
+	25, // 33: urlshortener.URLShortener.URLHistory:output_type -> urlshortener.URLHistoryResponse
+	23, // 34: urlshortener.URLShortener.RollbackURL:output_type -> urlshortener.UpdateURLResponse
+	29, // 35: urlshortener.URLShortener.URLStats:output_type -> urlshortener.URLStatsResponse
+	25, // [25:36] is the sub-list for method output_type
+	14, // [14:25] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
 }
 
 func init() { file_shortener_proto_init() }
@@ -822,13 +2230,16 @@ func file_shortener_proto_init() {
 	if File_shortener_proto != nil {
 		return
 	}
+	file_shortener_proto_msgTypes[1].OneofWrappers = []any{}
+	file_shortener_proto_msgTypes[20].OneofWrappers = []any{}
+	file_shortener_proto_msgTypes[22].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_shortener_proto_rawDesc), len(file_shortener_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   15,
+			NumMessages:   32,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -25,6 +25,11 @@ const (
 	URLShortener_Urls_FullMethodName            = "/urlshortener.URLShortener/Urls"
 	URLShortener_DeleteUrlsAsync_FullMethodName = "/urlshortener.URLShortener/DeleteUrlsAsync"
 	URLShortener_Stats_FullMethodName           = "/urlshortener.URLShortener/Stats"
+	URLShortener_QRCode_FullMethodName          = "/urlshortener.URLShortener/QRCode"
+	URLShortener_UpdateURL_FullMethodName       = "/urlshortener.URLShortener/UpdateURL"
+	URLShortener_URLHistory_FullMethodName      = "/urlshortener.URLShortener/URLHistory"
+	URLShortener_RollbackURL_FullMethodName     = "/urlshortener.URLShortener/RollbackURL"
+	URLShortener_URLStats_FullMethodName        = "/urlshortener.URLShortener/URLStats"
 )
 
 // URLShortenerClient is the client API for URLShortener service.
@@ -37,6 +42,11 @@ type URLShortenerClient interface {
 	Urls(ctx context.Context, in *UrlsRequest, opts ...grpc.CallOption) (*UrlsResponse, error)
 	DeleteUrlsAsync(ctx context.Context, in *DeleteUrlsAsyncRequest, opts ...grpc.CallOption) (*DeleteUrlsAsyncResponse, error)
 	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	QRCode(ctx context.Context, in *QRCodeRequest, opts ...grpc.CallOption) (*QRCodeResponse, error)
+	UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error)
+	URLHistory(ctx context.Context, in *URLHistoryRequest, opts ...grpc.CallOption) (*URLHistoryResponse, error)
+	RollbackURL(ctx context.Context, in *RollbackURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error)
+	URLStats(ctx context.Context, in *URLStatsRequest, opts ...grpc.CallOption) (*URLStatsResponse, error)
 }
 
 type uRLShortenerClient struct {
@@ -107,6 +117,56 @@ func (c *uRLShortenerClient) Stats(ctx context.Context, in *StatsRequest, opts .
 	return out, nil
 }
 
+func (c *uRLShortenerClient) QRCode(ctx context.Context, in *QRCodeRequest, opts ...grpc.CallOption) (*QRCodeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QRCodeResponse)
+	err := c.cc.Invoke(ctx, URLShortener_QRCode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateURLResponse)
+	err := c.cc.Invoke(ctx, URLShortener_UpdateURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) URLHistory(ctx context.Context, in *URLHistoryRequest, opts ...grpc.CallOption) (*URLHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(URLHistoryResponse)
+	err := c.cc.Invoke(ctx, URLShortener_URLHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) RollbackURL(ctx context.Context, in *RollbackURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateURLResponse)
+	err := c.cc.Invoke(ctx, URLShortener_RollbackURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) URLStats(ctx context.Context, in *URLStatsRequest, opts ...grpc.CallOption) (*URLStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(URLStatsResponse)
+	err := c.cc.Invoke(ctx, URLShortener_URLStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // URLShortenerServer is the server API for URLShortener service.
 // All implementations must embed UnimplementedURLShortenerServer
 // for forward compatibility.
@@ -117,6 +177,11 @@ type URLShortenerServer interface {
 	Urls(context.Context, *UrlsRequest) (*UrlsResponse, error)
 	DeleteUrlsAsync(context.Context, *DeleteUrlsAsyncRequest) (*DeleteUrlsAsyncResponse, error)
 	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	QRCode(context.Context, *QRCodeRequest) (*QRCodeResponse, error)
+	UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error)
+	URLHistory(context.Context, *URLHistoryRequest) (*URLHistoryResponse, error)
+	RollbackURL(context.Context, *RollbackURLRequest) (*UpdateURLResponse, error)
+	URLStats(context.Context, *URLStatsRequest) (*URLStatsResponse, error)
 	mustEmbedUnimplementedURLShortenerServer()
 }
 
@@ -145,6 +210,21 @@ func (UnimplementedURLShortenerServer) DeleteUrlsAsync(context.Context, *DeleteU
 func (UnimplementedURLShortenerServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
 }
+func (UnimplementedURLShortenerServer) QRCode(context.Context, *QRCodeRequest) (*QRCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QRCode not implemented")
+}
+func (UnimplementedURLShortenerServer) UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateURL not implemented")
+}
+func (UnimplementedURLShortenerServer) URLHistory(context.Context, *URLHistoryRequest) (*URLHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method URLHistory not implemented")
+}
+func (UnimplementedURLShortenerServer) RollbackURL(context.Context, *RollbackURLRequest) (*UpdateURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RollbackURL not implemented")
+}
+func (UnimplementedURLShortenerServer) URLStats(context.Context, *URLStatsRequest) (*URLStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method URLStats not implemented")
+}
 func (UnimplementedURLShortenerServer) mustEmbedUnimplementedURLShortenerServer() {}
 func (UnimplementedURLShortenerServer) testEmbeddedByValue()                      {}
 
@@ -274,6 +354,96 @@ func _URLShortener_Stats_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_QRCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QRCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).QRCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_QRCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).QRCode(ctx, req.(*QRCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).UpdateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_UpdateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).UpdateURL(ctx, req.(*UpdateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_URLHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(URLHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).URLHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_URLHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).URLHistory(ctx, req.(*URLHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_RollbackURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).RollbackURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_RollbackURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).RollbackURL(ctx, req.(*RollbackURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_URLStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(URLStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).URLStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_URLStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).URLStats(ctx, req.(*URLStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // URLShortener_ServiceDesc is the grpc.ServiceDesc for URLShortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -305,6 +475,26 @@ var URLShortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Stats",
 			Handler:    _URLShortener_Stats_Handler,
 		},
+		{
+			MethodName: "QRCode",
+			Handler:    _URLShortener_QRCode_Handler,
+		},
+		{
+			MethodName: "UpdateURL",
+			Handler:    _URLShortener_UpdateURL_Handler,
+		},
+		{
+			MethodName: "URLHistory",
+			Handler:    _URLShortener_URLHistory_Handler,
+		},
+		{
+			MethodName: "RollbackURL",
+			Handler:    _URLShortener_RollbackURL_Handler,
+		},
+		{
+			MethodName: "URLStats",
+			Handler:    _URLShortener_URLStats_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "shortener.proto",